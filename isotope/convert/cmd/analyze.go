@@ -0,0 +1,102 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/tools/isotope/convert/pkg/analysis"
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+)
+
+// analyzeCmd represents the analyze command
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze [service-graph.yaml]",
+	Short: "Report cycles, unreachable services and the cost of each entrypoint",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inPath := args[0]
+
+		format, err := cmd.PersistentFlags().GetString("format")
+		exitIfError(err)
+
+		serviceGraph, err := graph.ReadServiceGraphFile(inPath)
+		exitIfError(err)
+
+		report := analysis.Analyze(serviceGraph)
+
+		switch format {
+		case "text":
+			exitIfError(writeReport(os.Stdout, report))
+		case "json":
+			out, err := json.MarshalIndent(report, "", "  ")
+			exitIfError(err)
+			fmt.Println(string(out))
+		default:
+			exitIfError(fmt.Errorf(`unknown format %q (must be "text" or "json")`, format))
+		}
+
+		if len(report.Cycles) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(analyzeCmd)
+	analyzeCmd.PersistentFlags().String(
+		"format", "text", `the output format: "text" or "json"`)
+}
+
+func writeReport(out io.Writer, report analysis.Report) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENTRYPOINT\tDEPTH\tREQUESTS\tBEST\tEXPECTED\tWORST\tBYTES")
+	for _, e := range report.Entrypoints {
+		if e.Cyclic {
+			// The cost of a request which can loop forever is unbounded.
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\t-\n", e.Service)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f (max %d)\t%s\t%s\t%s\t%s (max %s)\n",
+			e.Service, e.MaxDepth, e.ExpectedRequests, e.MaxRequests,
+			roundLatency(e.Latency.Best), roundLatency(e.Latency.Expected),
+			roundLatency(e.Latency.Worst),
+			e.ExpectedBytes, e.MaxBytes)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, cycle := range report.Cycles {
+		fmt.Fprintf(out, "error: cycle between %s\n", strings.Join(cycle, ", "))
+	}
+	for _, name := range report.Unreachable {
+		fmt.Fprintf(out, "warning: %s is unreachable from any entrypoint\n", name)
+	}
+	return nil
+}
+
+func roundLatency(d duration.Duration) time.Duration {
+	return time.Duration(d).Round(time.Microsecond)
+}
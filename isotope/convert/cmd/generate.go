@@ -0,0 +1,154 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/tools/isotope/convert/pkg/generate"
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+	"istio.io/tools/isotope/convert/pkg/graph/size"
+)
+
+// generateCmd represents the generate command
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a synthetic service graph YAML",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.PersistentFlags()
+
+		shape, err := flags.GetString("shape")
+		exitIfError(err)
+
+		services, err := flags.GetInt("services")
+		exitIfError(err)
+
+		depth, err := flags.GetInt("depth")
+		exitIfError(err)
+
+		fanOut, err := flags.GetInt("fan-out")
+		exitIfError(err)
+
+		density, err := flags.GetFloat64("density")
+		exitIfError(err)
+
+		namespaces, err := flags.GetInt("namespaces")
+		exitIfError(err)
+
+		clusters, err := flags.GetInt("clusters")
+		exitIfError(err)
+
+		requestSizeStr, err := flags.GetString("request-size")
+		exitIfError(err)
+		requestSize, err := parseSize(requestSizeStr)
+		exitIfError(err)
+
+		responseSizeStr, err := flags.GetString("response-size")
+		exitIfError(err)
+		responseSize, err := parseSize(responseSizeStr)
+		exitIfError(err)
+
+		sleepStr, err := flags.GetString("sleep")
+		exitIfError(err)
+		sleep, err := parseSleep(sleepStr)
+		exitIfError(err)
+
+		seed, err := flags.GetInt64("seed")
+		exitIfError(err)
+
+		serviceGraph, err := generate.Generate(generate.Params{
+			Shape:        generate.Shape(shape),
+			Services:     services,
+			Depth:        depth,
+			FanOut:       fanOut,
+			Density:      density,
+			Namespaces:   namespaces,
+			Clusters:     clusters,
+			RequestSize:  requestSize,
+			ResponseSize: responseSize,
+			Sleep:        sleep,
+			Seed:         seed,
+		})
+		exitIfError(err)
+
+		serviceGraphYAML, err := yaml.Marshal(serviceGraph)
+		exitIfError(err)
+
+		fmt.Print(string(serviceGraphYAML))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+	generateCmd.PersistentFlags().String(
+		"shape", string(generate.ShapeTree),
+		`the shape of the graph: "tree", "chain", "dag", "scale-free" or "realistic"`)
+	generateCmd.PersistentFlags().Int(
+		"services", 10, "the number of services (all shapes but tree)")
+	generateCmd.PersistentFlags().Int(
+		"depth", 2, fmt.Sprintf("the number of levels below the root (tree); trees have at most %d services", generate.MaxServices))
+	generateCmd.PersistentFlags().Int(
+		"fan-out", 3, "the number of services each service calls (tree, scale-free)")
+	generateCmd.PersistentFlags().Float64(
+		"density", 0.2, "the probability of a call between two services (dag)")
+	generateCmd.PersistentFlags().Int(
+		"namespaces", 0, "the number of namespaces to spread services over")
+	generateCmd.PersistentFlags().Int(
+		"clusters", 0, "the number of clusters to spread services over")
+	generateCmd.PersistentFlags().String(
+		"request-size", "", "the size of every request (e.g. 1KB)")
+	generateCmd.PersistentFlags().String(
+		"response-size", "", "the size of every response (e.g. 1KB)")
+	generateCmd.PersistentFlags().String(
+		"sleep", "",
+		`how long every service sleeps: a duration (e.g. 10ms) or a distribution `+
+			`(e.g. "{distribution: lognormal, median: 5ms, p99: 50ms}")`)
+	generateCmd.PersistentFlags().Int64(
+		"seed", 0, "the seed of the random choices")
+}
+
+func parseSize(s string) (size.ByteSize, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return size.FromString(s)
+}
+
+// parseSleep parses s like the value of a sleep command in a script.
+func parseSleep(s string) (script.Command, error) {
+	if s == "" {
+		return nil, nil
+	}
+	sleepJSON, err := yaml.YAMLToJSON([]byte(s))
+	if err != nil {
+		return nil, err
+	}
+	scriptJSON, err := json.Marshal([]map[string]json.RawMessage{
+		{"sleep": sleepJSON},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var s2 script.Script
+	if err := json.Unmarshal(scriptJSON, &s2); err != nil {
+		return nil, err
+	}
+	return s2[0], nil
+}
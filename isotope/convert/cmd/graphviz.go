@@ -0,0 +1,97 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graphviz"
+)
+
+// graphvizCmd represents the graphviz command
+var graphvizCmd = &cobra.Command{
+	Use:   "graphviz [service-graph.yaml]",
+	Short: "Convert service graph YAML to a Graphviz DOT graph or an image",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inPath := args[0]
+
+		format, err := cmd.PersistentFlags().GetString("format")
+		exitIfError(err)
+
+		outPath, err := cmd.PersistentFlags().GetString("output")
+		exitIfError(err)
+
+		collapseReplicas, err := cmd.PersistentFlags().GetBool("collapse-replicas")
+		exitIfError(err)
+
+		colorBy, err := cmd.PersistentFlags().GetString("color-by")
+		exitIfError(err)
+
+		edgeLabels, err := cmd.PersistentFlags().GetBool("edge-labels")
+		exitIfError(err)
+
+		serviceGraph, err := graph.ReadServiceGraphFile(inPath)
+		exitIfError(err)
+
+		g, err := graphviz.ServiceGraphToGraphWithOptions(serviceGraph, graphviz.Options{
+			CollapseReplicas: collapseReplicas,
+			ColorBy:          graphviz.ColorBy(colorBy),
+			EdgeLabels:       edgeLabels,
+		})
+		exitIfError(err)
+
+		var out []byte
+		switch format {
+		case "dot":
+			dotLang, err := graphviz.GraphToDotLanguage(g)
+			exitIfError(err)
+			out = []byte(dotLang)
+		case "svg":
+			out, err = graphviz.GraphToSVG(g)
+			exitIfError(err)
+		case "png":
+			out, err = graphviz.GraphToPNG(g)
+			exitIfError(err)
+		default:
+			exitIfError(fmt.Errorf(`unknown format %q (must be "dot", "svg" or "png")`, format))
+		}
+
+		if outPath == "" {
+			_, err = os.Stdout.Write(out)
+		} else {
+			err = os.WriteFile(outPath, out, 0o644)
+		}
+		exitIfError(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(graphvizCmd)
+	graphvizCmd.PersistentFlags().String(
+		"format", "dot", `the output format: "dot", or "svg" or "png" rendered without Graphviz`)
+	graphvizCmd.PersistentFlags().StringP(
+		"output", "o", "", "the file to write to instead of stdout")
+	graphvizCmd.PersistentFlags().Bool(
+		"collapse-replicas", false, "draw services which only differ by name as one node")
+	graphvizCmd.PersistentFlags().String(
+		"color-by", "", `colour nodes by "namespace" or "cluster"`)
+	graphvizCmd.PersistentFlags().Bool(
+		"edge-labels", false, "label edges with the size and probability of their requests")
+}
@@ -15,12 +15,13 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
-	"sigs.k8s.io/yaml"
 
 	"istio.io/tools/isotope/convert/pkg/graph"
 	"istio.io/tools/isotope/convert/pkg/kubernetes"
@@ -67,15 +68,50 @@ var kubernetesCmd = &cobra.Command{
 		clientDisabled, err := cmd.PersistentFlags().GetBool("client-disabled")
 		exitIfError(err)
 
-		yamlContents, err := os.ReadFile(inPath)
+		istio, err := cmd.PersistentFlags().GetBool("istio")
 		exitIfError(err)
 
-		var serviceGraph graph.ServiceGraph
-		exitIfError(yaml.Unmarshal(yamlContents, &serviceGraph))
+		loadClient, err := cmd.PersistentFlags().GetBool("client-load")
+		exitIfError(err)
+
+		loadClientArgsStr, err := cmd.PersistentFlags().GetString("client-load-args")
+		exitIfError(err)
+		loadClientArgs := strings.Fields(loadClientArgsStr)
+		if loadClient && !cmd.PersistentFlags().Changed("client-image") {
+			// The default client image is fortio, which has no load command.
+			exitIfError(errors.New(
+				"--client-load requires --client-image, an image of the converter (see convert/Dockerfile)"))
+		}
+
+		outputDir, err := cmd.PersistentFlags().GetString("output-dir")
+		exitIfError(err)
+
+		clusterGatewaysStr, err := cmd.PersistentFlags().GetString("cluster-gateways")
+		exitIfError(err)
+		clusterGateways, err := extractClusterGateways(clusterGatewaysStr)
+		exitIfError(err)
+
+		serviceGraph, err := graph.ReadServiceGraphFile(inPath)
+		exitIfError(err)
+
+		if outputDir != "" {
+			clusterManifests, err := kubernetes.ServiceGraphToClusterManifests(
+				serviceGraph, serviceNodeSelector, serviceImage,
+				serviceMaxIdleConnectionsPerHost, clientNodeSelector, clientImage, clientNamespace, environmentName, clientDisabled,
+				istio, loadClient, loadClientArgs, clusterGateways)
+			exitIfError(err)
+			exitIfError(os.MkdirAll(outputDir, 0o755))
+			for cluster, manifests := range clusterManifests {
+				exitIfError(os.WriteFile(
+					filepath.Join(outputDir, cluster+".yaml"), manifests, 0o644))
+			}
+			return
+		}
 
 		manifests, err := kubernetes.ServiceGraphToKubernetesManifests(
 			serviceGraph, serviceNodeSelector, serviceImage,
-			serviceMaxIdleConnectionsPerHost, clientNodeSelector, clientImage, clientNamespace, environmentName, clusterName, clientDisabled)
+			serviceMaxIdleConnectionsPerHost, clientNodeSelector, clientImage, clientNamespace, environmentName, clusterName, clientDisabled,
+			istio, loadClient, loadClientArgs)
 		exitIfError(err)
 
 		fmt.Println(string(manifests))
@@ -100,11 +136,21 @@ func init() {
 		"service-node-selector", "", "the node selector for service workloads")
 	kubernetesCmd.PersistentFlags().Bool(
 		"client-disabled", false, "disabling client service (fortio) as part of the output")
+	kubernetesCmd.PersistentFlags().Bool(
+		"istio", false, "generate VirtualServices, DestinationRules, Sidecars and AuthorizationPolicies for the services")
+	kubernetesCmd.PersistentFlags().Bool(
+		"client-load", false, "run the load command of --client-image, which must then be set to an image of the converter, as a client Job instead of fortio")
+	kubernetesCmd.PersistentFlags().String(
+		"client-load-args", "", `the arguments of the load command of the client Job, e.g. "--qps 100 --duration 5m"`)
 	kubernetesCmd.PersistentFlags().String(
 		"client-namespace", "default", "namespace where to deploy the client")
 	kubernetesCmd.PersistentFlags().String(
 		"cluster", "", "the cluster name to generate related workloads. It needs to match the cluster attribute for the services")
 	kubernetesCmd.MarkPersistentFlagRequired("cluster-name")
+	kubernetesCmd.PersistentFlags().String(
+		"output-dir", "", "write the manifests of each cluster in the graph to <output-dir>/<cluster>.yaml instead of stdout, ignoring --cluster")
+	kubernetesCmd.PersistentFlags().String(
+		"cluster-gateways", "", "the gateway address of each cluster called from other clusters, e.g. cluster1=10.0.0.1,cluster2=10.0.0.2")
 }
 
 func splitByEquals(s string) (k string, v string, err error) {
@@ -130,3 +176,24 @@ func extractNodeSelector(s string) (map[string]string, error) {
 	nodeSelector[k] = v
 	return nodeSelector, nil
 }
+
+func extractClusterGateways(s string) (map[string]string, error) {
+	return extractPairs(s, "cluster gateway")
+}
+
+// extractPairs parses comma-separated key=value pairs, describing each as
+// what in errors.
+func extractPairs(s string, what string) (map[string]string, error) {
+	pairs := map[string]string{}
+	if len(s) == 0 {
+		return pairs, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.Split(pair, "=")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%s is not a valid %s", pair, what)
+		}
+		pairs[parts[0]] = parts[1]
+	}
+	return pairs, nil
+}
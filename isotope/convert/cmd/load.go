@@ -0,0 +1,190 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/load"
+)
+
+// loadCmd represents the load command
+var loadCmd = &cobra.Command{
+	Use:   "load [service-graph.yaml]",
+	Short: "Send requests to the entrypoints of a service graph and report their latency as JSON",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inPath := args[0]
+
+		qps, err := cmd.PersistentFlags().GetFloat64("qps")
+		exitIfError(err)
+		connections, err := cmd.PersistentFlags().GetInt("connections")
+		exitIfError(err)
+		duration, err := cmd.PersistentFlags().GetDuration("duration")
+		exitIfError(err)
+		rampUp, err := cmd.PersistentFlags().GetDuration("ramp-up")
+		exitIfError(err)
+		scheduleStr, err := cmd.PersistentFlags().GetString("schedule")
+		exitIfError(err)
+		timeout, err := cmd.PersistentFlags().GetDuration("timeout")
+		exitIfError(err)
+		weightsStr, err := cmd.PersistentFlags().GetString("weights")
+		exitIfError(err)
+		weights, err := extractWeights(weightsStr)
+		exitIfError(err)
+		addressesStr, err := cmd.PersistentFlags().GetString("addresses")
+		exitIfError(err)
+		addresses, err := extractPairs(addressesStr, "address")
+		exitIfError(err)
+		recordPath, err := cmd.PersistentFlags().GetString("record")
+		exitIfError(err)
+		replayPath, err := cmd.PersistentFlags().GetString("replay")
+		exitIfError(err)
+		replayDecisions, err := cmd.PersistentFlags().GetBool("replay-decisions")
+		exitIfError(err)
+
+		serviceGraph, err := graph.ReadServiceGraphFile(inPath)
+		exitIfError(err)
+
+		var replay []load.Arrival
+		if replayPath != "" {
+			f, err := os.Open(replayPath)
+			exitIfError(err)
+			replay, err = load.ReadArrivals(f)
+			f.Close()
+			exitIfError(err)
+			// The replayed requests pick their targets.
+			weights = make(map[string]float64)
+			for _, arrival := range replay {
+				weights[arrival.Service]++
+			}
+		}
+
+		targets, err := load.Targets(serviceGraph, weights, addresses)
+		exitIfError(err)
+
+		closedLoop := qps == 0
+		target := qps
+		if closedLoop {
+			target = float64(connections)
+		}
+		schedule := load.ConstantSchedule(target, rampUp, duration)
+		if scheduleStr != "" {
+			schedule, err = load.ParseSchedule(scheduleStr)
+			exitIfError(err)
+		}
+
+		// The record is flushed and closed explicitly, as exitIfError skips
+		// deferred calls.
+		var recordFile *os.File
+		var recordWriter *bufio.Writer
+		var record io.Writer
+		if recordPath != "" {
+			recordFile, err = os.Create(recordPath)
+			exitIfError(err)
+			recordWriter = bufio.NewWriter(recordFile)
+			record = recordWriter
+		}
+
+		// Interrupting the test still reports the requests sent so far.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		report, err := load.Run(ctx, targets, load.Options{
+			Schedule:    schedule,
+			ClosedLoop:  closedLoop,
+			Connections: connections,
+			Timeout:     timeout,
+			// Recorded requests are seeded, so that they can be replayed
+			// with the same decisions.
+			Seeds:           record != nil,
+			Record:          record,
+			Replay:          replay,
+			ReplayDecisions: replayDecisions,
+		})
+		if recordFile != nil {
+			if closeErr := closeRecord(recordWriter, recordFile); err == nil {
+				err = closeErr
+			}
+		}
+		exitIfError(err)
+
+		out, err := json.MarshalIndent(report, "", "  ")
+		exitIfError(err)
+		fmt.Println(string(out))
+	},
+}
+
+// closeRecord flushes w, writing the record to f, and closes f.
+func closeRecord(w *bufio.Writer, f *os.File) error {
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func init() {
+	rootCmd.AddCommand(loadCmd)
+	loadCmd.PersistentFlags().Float64(
+		"qps", 0, "requests per second to send; 0 sends requests back to back from each connection")
+	loadCmd.PersistentFlags().Int(
+		"connections", 8, "number of connections sending requests")
+	loadCmd.PersistentFlags().Duration(
+		"duration", time.Minute, "how long to send requests for after the ramp-up")
+	loadCmd.PersistentFlags().Duration(
+		"ramp-up", 0, "how long to ramp the rate, or the connections, up from 0")
+	loadCmd.PersistentFlags().String(
+		"schedule", "", "stages ramping the rate, or the connections, to a target, e.g. 30s:100,5m:100,30s:0; overrides --qps or --connections, --ramp-up and --duration")
+	loadCmd.PersistentFlags().Duration(
+		"timeout", 0, "timeout of each request (0 for none)")
+	loadCmd.PersistentFlags().String(
+		"weights", "", "the share of requests for each service, e.g. a=3,b=1 (default: an even split between the entrypoints)")
+	loadCmd.PersistentFlags().String(
+		"addresses", "", "the host:port of services not reachable at <name>[.<namespace>]:8080, e.g. a=127.0.0.1:8080")
+	loadCmd.PersistentFlags().String(
+		"record", "", "file to record each request to as a JSON line, sending it with a seed for its random decisions")
+	loadCmd.PersistentFlags().String(
+		"replay", "", "file recorded by --record to send the same requests at the same times from, instead of following the schedule")
+	loadCmd.PersistentFlags().Bool(
+		"replay-decisions", false, "send the replayed requests with their recorded seeds, so that services make the same random decisions")
+}
+
+func extractWeights(s string) (map[string]float64, error) {
+	pairs, err := extractPairs(s, "weight")
+	if err != nil {
+		return nil, err
+	}
+	weights := make(map[string]float64, len(pairs))
+	for name, weight := range pairs {
+		w, err := strconv.ParseFloat(weight, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s=%s is not a valid weight", name, weight)
+		}
+		weights[name] = w
+	}
+	return weights, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/histogram"
+	"istio.io/tools/isotope/convert/pkg/simulate"
+)
+
+// simulateCmd represents the simulate command
+var simulateCmd = &cobra.Command{
+	Use:   "simulate [service-graph.yaml]",
+	Short: "Predict latency percentiles and request rates with a discrete-event simulation",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inPath := args[0]
+
+		format, err := cmd.PersistentFlags().GetString("format")
+		exitIfError(err)
+		rate, err := cmd.PersistentFlags().GetFloat64("rate")
+		exitIfError(err)
+		duration, err := cmd.PersistentFlags().GetDuration("duration")
+		exitIfError(err)
+		proxyOverhead, err := cmd.PersistentFlags().GetDuration("proxy-overhead")
+		exitIfError(err)
+		seed, err := cmd.PersistentFlags().GetInt64("seed")
+		exitIfError(err)
+
+		serviceGraph, err := graph.ReadServiceGraphFile(inPath)
+		exitIfError(err)
+
+		result, err := simulate.Simulate(serviceGraph, simulate.Options{
+			Rate:          rate,
+			Duration:      duration,
+			ProxyOverhead: proxyOverhead,
+			Seed:          seed,
+		})
+		exitIfError(err)
+
+		switch format {
+		case "text":
+			exitIfError(writeSimulation(os.Stdout, result))
+		case "json":
+			out, err := json.MarshalIndent(result, "", "  ")
+			exitIfError(err)
+			fmt.Println(string(out))
+		default:
+			exitIfError(fmt.Errorf(`unknown format %q (must be "text" or "json")`, format))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(simulateCmd)
+	simulateCmd.PersistentFlags().String(
+		"format", "text", `the output format: "text" or "json", which includes latency histograms`)
+	simulateCmd.PersistentFlags().Float64(
+		"rate", 100, "requests per second sent to the entrypoints")
+	simulateCmd.PersistentFlags().Duration(
+		"duration", time.Minute, "simulated time during which requests are sent")
+	simulateCmd.PersistentFlags().Duration(
+		"proxy-overhead", 0, "latency added to each hop, e.g. by sidecars (0 models no mesh)")
+	simulateCmd.PersistentFlags().Int64(
+		"seed", 0, "seed of the random decisions")
+}
+
+func writeSimulation(out io.Writer, result simulate.Result) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, "ENTRYPOINT\tREQUESTS\tERRORS\tMEAN")
+	for _, p := range histogram.Percentiles {
+		fmt.Fprintf(w, "\tP%s", strconv.FormatFloat(p, 'f', -1, 64))
+	}
+	fmt.Fprintln(w, "\tMAX")
+	for _, e := range result.Entrypoints {
+		l := e.Latency
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s", e.Service, e.Requests, e.Errors, roundLatency(l.Mean))
+		for _, p := range histogram.Percentiles {
+			fmt.Fprintf(w, "\t%s", roundLatency(l.Percentile(p)))
+		}
+		fmt.Fprintf(w, "\t%s\n", roundLatency(l.Max))
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "SERVICE\tRATE\tERRORS\tCPU")
+	for _, s := range result.Services {
+		cpu := "-"
+		if s.CPUUtilization > 0 {
+			cpu = fmt.Sprintf("%.1f%%", 100*s.CPUUtilization)
+		}
+		fmt.Fprintf(w, "%s\t%.1f/s\t%.1f%%\t%s\n",
+			s.Service, s.Rate, 100*float64(s.ErrorRate), cpu)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "FROM\tTO\tRATE\tERRORS")
+	for _, e := range result.Edges {
+		fmt.Fprintf(w, "%s\t%s\t%.1f/s\t%.1f%%\n",
+			e.From, e.To, e.Rate, 100*float64(e.ErrorRate))
+	}
+	return w.Flush()
+}
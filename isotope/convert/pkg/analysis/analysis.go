@@ -0,0 +1,503 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analysis statically analyses service graphs: it finds call cycles
+// and unreachable services, and estimates the cost of a request to each
+// entrypoint.
+package analysis
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+	"istio.io/tools/isotope/convert/pkg/graph/size"
+	"istio.io/tools/isotope/convert/pkg/graph/svc"
+)
+
+// Quantiles of sleep distributions used for the best and worst cases.
+const (
+	bestQuantile  = 0.01
+	worstQuantile = 0.99
+)
+
+// Report is the result of analysing a service graph.
+type Report struct {
+	Entrypoints []EntrypointReport `json:"entrypoints"`
+	// Cycles lists the sets of services which call each other in a cycle,
+	// each sorted by name.
+	Cycles [][]string `json:"cycles,omitempty"`
+	// Unreachable lists the services no entrypoint calls, even indirectly.
+	Unreachable []string `json:"unreachable,omitempty"`
+}
+
+// EntrypointReport estimates the cost of a "GET /" request to an entrypoint.
+//
+// The best case skips every call with a probability; the expected case makes
+// them with their probability; the worst case makes every call, and every
+// attempt fails after its timeout (or the worst latency of the callee), is
+// retried and finally falls back. Sleeps drawn from a distribution count as
+// their 1st percentile, mean and 99th percentile respectively, and so do
+// random repeat counts. A choice takes its best branch, the average of its
+// branches weighted by probability, and its worst branch respectively.
+// Concurrent calls take as long as the slowest of them, and fan-outs as long
+// as their batches of concurrent calls. Network time is ignored.
+type EntrypointReport struct {
+	Service string `json:"service"`
+	// Cyclic is set if the request can reach a cycle, in which case its cost
+	// is unbounded and left unset.
+	Cyclic bool `json:"cyclic,omitempty"`
+	// MaxDepth is the length of the longest chain of calls.
+	MaxDepth int `json:"maxDepth"`
+	// ExpectedRequests and MaxRequests count the calls made between services,
+	// that is the fan-out amplification of the request.
+	ExpectedRequests float64 `json:"expectedRequests"`
+	MaxRequests      int     `json:"maxRequests"`
+	Latency          Latency `json:"latency"`
+	// ExpectedBytes and MaxBytes count the bytes of every request and
+	// response, including the response of the entrypoint.
+	ExpectedBytes size.ByteSize `json:"expectedBytes"`
+	MaxBytes      size.ByteSize `json:"maxBytes"`
+}
+
+// Latency is an estimate of the time to respond to a request.
+type Latency struct {
+	Best     duration.Duration `json:"best"`
+	Expected duration.Duration `json:"expected"`
+	Worst    duration.Duration `json:"worst"`
+}
+
+// Analyze analyses g, which must be valid. Requests are sent to the
+// services marked as entrypoints or, if there are none, to the services no
+// other service calls.
+func Analyze(g graph.ServiceGraph) Report {
+	a := analyzer{
+		services: make(map[string]svc.Service, len(g.Services)),
+		costs:    make(map[behaviourKey]cost),
+		visiting: make(map[behaviourKey]bool),
+	}
+	for _, service := range g.Services {
+		a.services[service.Name] = service
+	}
+	calls := g.Calls()
+
+	var report Report
+	entrypoints := g.Entrypoints()
+	for _, name := range entrypoints {
+		report.Entrypoints = append(report.Entrypoints, a.entrypointReport(a.services[name]))
+	}
+	report.Cycles = cycles(g, calls)
+	report.Unreachable = unreachable(g, calls, entrypoints)
+	return report
+}
+
+// behaviourKey identifies the script run for a request: the script of a
+// route of a service, or of the service itself if route is "".
+type behaviourKey struct {
+	service string
+	route   string
+}
+
+// cost is the estimated cost of a request to a service, excluding the
+// request and response themselves.
+type cost struct {
+	depth                 int
+	expectedRequests      float64
+	maxRequests           int
+	best, expected, worst time.Duration
+	expectedBytes         float64
+	maxBytes              float64
+}
+
+type analyzer struct {
+	services map[string]svc.Service
+	costs    map[behaviourKey]cost
+	visiting map[behaviourKey]bool
+}
+
+func (a *analyzer) entrypointReport(service svc.Service) EntrypointReport {
+	report := EntrypointReport{Service: service.Name}
+	c, ok := a.request(service, http.MethodGet, "/")
+	if !ok {
+		report.Cyclic = true
+		return report
+	}
+	responseSize := float64(responseSize(service, http.MethodGet, "/"))
+	report.MaxDepth = c.depth
+	report.ExpectedRequests = c.expectedRequests
+	report.MaxRequests = c.maxRequests
+	report.Latency = Latency{
+		Best:     duration.Duration(c.best),
+		Expected: duration.Duration(c.expected),
+		Worst:    duration.Duration(c.worst),
+	}
+	report.ExpectedBytes = size.ByteSize(math.Round(c.expectedBytes + responseSize))
+	report.MaxBytes = size.ByteSize(math.Round(c.maxBytes + responseSize))
+	return report
+}
+
+// request returns the cost of a request to service, or false if the request
+// can reach a cycle.
+func (a *analyzer) request(service svc.Service, method string, path string) (cost, bool) {
+	route, _ := service.MatchRoute(method, path)
+	key := behaviourKey{service.Name, route}
+	if c, ok := a.costs[key]; ok {
+		return c, true
+	}
+	if a.visiting[key] {
+		return cost{}, false
+	}
+	a.visiting[key] = true
+	defer delete(a.visiting, key)
+
+	s := service.Script
+	if route != "" {
+		s = service.Routes[route].Script
+	} else if len(service.Versions) > 0 {
+		s = script.Script{versionChoice(service)}
+	}
+	total, ok := a.script(s)
+	if !ok {
+		return cost{}, false
+	}
+	a.costs[key] = total
+	return total, true
+}
+
+// versionChoice returns the choice between the scripts of the versions of
+// service, weighted by its traffic split or, without one, by their replicas.
+func versionChoice(service svc.Service) script.ChooseCommand {
+	choice := make(script.ChooseCommand, 0, len(service.Versions))
+	for _, version := range service.Versions {
+		weight := float64(version.NumReplicas)
+		if service.Istio != nil && len(service.Istio.TrafficSplit) > 0 {
+			weight = float64(service.Istio.TrafficSplit[version.Name])
+		}
+		choice = append(choice, script.Branch{Weight: weight, Script: version.Script})
+	}
+	return choice
+}
+
+// script returns the cost of running cmds one after the other.
+func (a *analyzer) script(cmds []script.Command) (cost, bool) {
+	var total cost
+	for _, cmd := range cmds {
+		c, ok := a.command(cmd)
+		if !ok {
+			return cost{}, false
+		}
+		total = sequence(total, c)
+	}
+	return total, true
+}
+
+func (a *analyzer) command(cmd script.Command) (cost, bool) {
+	switch cmd := cmd.(type) {
+	case script.SleepCommand:
+		d := time.Duration(cmd)
+		return cost{best: d, expected: d, worst: d}, true
+	case script.SleepDistributionCommand:
+		return cost{
+			best:     cmd.Distribution.Quantile(bestQuantile),
+			expected: cmd.Distribution.Mean(),
+			worst:    cmd.Distribution.Quantile(worstQuantile),
+		}, true
+	case script.ComputeCommand:
+		// CPU time is only as long as wall time on an idle CPU.
+		d := time.Duration(cmd.CPU)
+		return cost{best: d, expected: d, worst: d}, true
+	case script.RequestCommand:
+		return a.call(cmd)
+	case script.ConcurrentCommand:
+		var total cost
+		for _, sub := range cmd {
+			c, ok := a.command(sub)
+			if !ok {
+				return cost{}, false
+			}
+			total = concurrently(total, c)
+		}
+		return total, true
+	case script.SequenceCommand:
+		return a.script(cmd)
+	case script.RepeatCommand:
+		body, ok := a.script(cmd.Body)
+		if !ok {
+			return cost{}, false
+		}
+		if cmd.Distribution == nil {
+			return repeated(body, cmd.Count, float64(cmd.Count), cmd.Count), true
+		}
+		return repeated(body,
+			cmd.Distribution.Quantile(bestQuantile),
+			cmd.Distribution.Mean(),
+			cmd.Distribution.Quantile(worstQuantile)), true
+	case script.ChooseCommand:
+		return a.choose(cmd)
+	case script.FanoutCommand:
+		c, ok := a.call(cmd.Call)
+		if !ok {
+			return cost{}, false
+		}
+		batches := cmd.Batches()
+		total := repeated(c, batches, float64(batches), batches)
+		total.expectedRequests = float64(cmd.Count) * c.expectedRequests
+		total.maxRequests = cmd.Count * c.maxRequests
+		total.expectedBytes = float64(cmd.Count) * c.expectedBytes
+		total.maxBytes = float64(cmd.Count) * c.maxBytes
+		return total, true
+	default:
+		return cost{}, true
+	}
+}
+
+// choose returns the cost of running one of the branches of cmd: the best
+// and worst of them, and their average weighted by probability.
+func (a *analyzer) choose(cmd script.ChooseCommand) (cost, bool) {
+	var total cost
+	first := true
+	for i, branch := range cmd {
+		if branch.Weight == 0 {
+			continue
+		}
+		c, ok := a.script(branch.Script)
+		if !ok {
+			return cost{}, false
+		}
+		p := cmd.Probability(i)
+		total.depth = max(total.depth, c.depth)
+		total.expectedRequests += p * c.expectedRequests
+		total.maxRequests = max(total.maxRequests, c.maxRequests)
+		if first || c.best < total.best {
+			total.best = c.best
+		}
+		total.expected += time.Duration(p * float64(c.expected))
+		total.worst = max(total.worst, c.worst)
+		total.expectedBytes += p * c.expectedBytes
+		total.maxBytes = max(total.maxBytes, c.maxBytes)
+		first = false
+	}
+	return total, true
+}
+
+// call returns the cost of sending cmd, including the request and response.
+func (a *analyzer) call(cmd script.RequestCommand) (cost, bool) {
+	attempt, ok := a.attempt(cmd)
+	if !ok {
+		return cost{}, false
+	}
+
+	probability := 1.0
+	if cmd.Probability > 0 {
+		probability = float64(cmd.Probability) / 100
+	}
+	c := cost{
+		depth:            attempt.depth,
+		expectedRequests: probability * attempt.expectedRequests,
+		maxRequests:      attempt.maxRequests,
+		best:             attempt.best,
+		expected:         time.Duration(probability * float64(attempt.expected)),
+		worst:            attempt.worst,
+		expectedBytes:    probability * attempt.expectedBytes,
+		maxBytes:         attempt.maxBytes,
+	}
+	if probability < 1 {
+		c.best = 0
+	}
+
+	// In the worst case, every attempt fails and the call falls back.
+	if cmd.Retries != nil && cmd.Retries.Attempts > 0 {
+		retries := cmd.Retries.Attempts
+		c.maxRequests += retries * attempt.maxRequests
+		c.maxBytes += float64(retries) * attempt.maxBytes
+		c.worst += time.Duration(retries) * attempt.worst
+		c.worst += time.Duration(cmd.Retries.Backoff) * time.Duration(1<<retries-1)
+	}
+	if fallback, ok := cmd.FallbackCommand(); ok {
+		f, ok := a.call(fallback)
+		if !ok {
+			return cost{}, false
+		}
+		c.depth = max(c.depth, f.depth)
+		c.maxRequests += f.maxRequests
+		c.maxBytes += f.maxBytes
+		c.worst += f.worst
+	}
+	return c, true
+}
+
+// attempt returns the cost of a single attempt to send cmd.
+func (a *analyzer) attempt(cmd script.RequestCommand) (cost, bool) {
+	callee, ok := a.services[cmd.ServiceName]
+	if !ok {
+		return cost{depth: 1, expectedRequests: 1, maxRequests: 1}, true
+	}
+	path := cmd.Path.Render(func(int) int { return 0 })
+	if path == "" {
+		path = "/"
+	}
+	c, ok := a.request(callee, cmd.HTTPMethod(), path)
+	if !ok {
+		return cost{}, false
+	}
+	bytes := float64(cmd.Size + responseSize(callee, cmd.HTTPMethod(), path))
+	c.depth++
+	c.expectedRequests++
+	c.maxRequests++
+	c.expectedBytes += bytes
+	c.maxBytes += bytes
+	if timeout := time.Duration(cmd.Timeout); timeout > 0 {
+		c.best = min(c.best, timeout)
+		c.expected = min(c.expected, timeout)
+		c.worst = min(c.worst, timeout)
+	}
+	return c, true
+}
+
+func responseSize(service svc.Service, method string, path string) size.ByteSize {
+	if route, ok := service.MatchRoute(method, path); ok {
+		return service.Routes[route].ResponseSize
+	}
+	return service.ResponseSize
+}
+
+// sequence returns the cost of x followed by y.
+func sequence(x cost, y cost) cost {
+	return cost{
+		depth:            max(x.depth, y.depth),
+		expectedRequests: x.expectedRequests + y.expectedRequests,
+		maxRequests:      x.maxRequests + y.maxRequests,
+		best:             x.best + y.best,
+		expected:         x.expected + y.expected,
+		worst:            x.worst + y.worst,
+		expectedBytes:    x.expectedBytes + y.expectedBytes,
+		maxBytes:         x.maxBytes + y.maxBytes,
+	}
+}
+
+// repeated returns the cost of c run several times in a row: best times in
+// the best case, expected times on average and worst times in the worst case.
+func repeated(c cost, best int, expected float64, worst int) cost {
+	r := cost{
+		expectedRequests: expected * c.expectedRequests,
+		maxRequests:      worst * c.maxRequests,
+		best:             time.Duration(best) * c.best,
+		expected:         time.Duration(expected * float64(c.expected)),
+		worst:            time.Duration(worst) * c.worst,
+		expectedBytes:    expected * c.expectedBytes,
+		maxBytes:         float64(worst) * c.maxBytes,
+	}
+	if worst > 0 {
+		r.depth = c.depth
+	}
+	return r
+}
+
+// concurrently returns the cost of x and y at the same time.
+func concurrently(x cost, y cost) cost {
+	c := sequence(x, y)
+	c.best = max(x.best, y.best)
+	c.expected = max(x.expected, y.expected)
+	c.worst = max(x.worst, y.worst)
+	return c
+}
+
+// cycles finds the strongly connected components of the call graph with
+// Tarjan's algorithm, and returns those which form a cycle.
+func cycles(g graph.ServiceGraph, calls map[string][]string) [][]string {
+	index := make(map[string]int)
+	lowLink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+
+	var connect func(v string)
+	connect = func(v string) {
+		index[v] = len(index)
+		lowLink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+
+		selfLoop := false
+		for _, w := range calls[v] {
+			if w == v {
+				selfLoop = true
+			}
+			if _, ok := index[w]; !ok {
+				connect(w)
+				lowLink[v] = min(lowLink[v], lowLink[w])
+			} else if onStack[w] {
+				lowLink[v] = min(lowLink[v], index[w])
+			}
+		}
+
+		if lowLink[v] != index[v] {
+			return
+		}
+		var component []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		if len(component) > 1 || selfLoop {
+			sort.Strings(component)
+			components = append(components, component)
+		}
+	}
+
+	for _, service := range g.Services {
+		if _, ok := index[service.Name]; !ok {
+			connect(service.Name)
+		}
+	}
+	sort.Slice(components, func(i, j int) bool {
+		return components[i][0] < components[j][0]
+	})
+	return components
+}
+
+func unreachable(g graph.ServiceGraph, calls map[string][]string, entrypoints []string) []string {
+	reached := make(map[string]bool, len(g.Services))
+	queue := append([]string(nil), entrypoints...)
+	for _, name := range entrypoints {
+		reached[name] = true
+	}
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		for _, to := range calls[from] {
+			if !reached[to] {
+				reached[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+
+	var names []string
+	for _, service := range g.Services {
+		if !reached[service.Name] {
+			names = append(names, service.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,318 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+)
+
+func analyzeYAML(t *testing.T, graphYAML string) Report {
+	t.Helper()
+	var g graph.ServiceGraph
+	if err := yaml.Unmarshal([]byte(graphYAML), &g); err != nil {
+		t.Fatal(err)
+	}
+	return Analyze(g)
+}
+
+func TestAnalyze(t *testing.T) {
+	t.Parallel()
+
+	report := analyzeYAML(t, `
+defaults:
+  requestSize: 100
+  responseSize: 1000
+services:
+- name: a
+  isEntrypoint: true
+  script:
+  - sleep: 10ms
+  - - call: b
+    - call:
+        service: c
+        probability: 50
+- name: b
+  script:
+  - sleep: 20ms
+  - call: d
+- name: c
+  script:
+  - compute:
+      cpu: 100ms
+  - allocate:
+      bytes: 1MB
+- name: d
+- name: orphan
+`)
+
+	expected := []EntrypointReport{{
+		Service:          "a",
+		MaxDepth:         2,
+		ExpectedRequests: 2.5,
+		MaxRequests:      3,
+		Latency: Latency{
+			Best:     duration.Duration(30 * time.Millisecond),
+			Expected: duration.Duration(60 * time.Millisecond),
+			Worst:    duration.Duration(110 * time.Millisecond),
+		},
+		ExpectedBytes: 3750,
+		MaxBytes:      4300,
+	}}
+	if !reflect.DeepEqual(expected, report.Entrypoints) {
+		t.Errorf("expected %+v; actual %+v", expected, report.Entrypoints)
+	}
+	if !reflect.DeepEqual([]string{"orphan"}, report.Unreachable) {
+		t.Errorf("expected [orphan]; actual %v", report.Unreachable)
+	}
+	if len(report.Cycles) != 0 {
+		t.Errorf("expected no cycles; actual %v", report.Cycles)
+	}
+}
+
+func TestAnalyze_RetriesAndTimeouts(t *testing.T) {
+	t.Parallel()
+
+	report := analyzeYAML(t, `
+services:
+- name: a
+  script:
+  - call:
+      service: b
+      timeout: 50ms
+      retries:
+        attempts: 2
+        backoff: 10ms
+      onError: fallback:c
+- name: b
+  script:
+  - sleep: 200ms
+- name: c
+  script:
+  - sleep: 5ms
+`)
+
+	if len(report.Entrypoints) != 1 {
+		t.Fatalf("expected 1 entrypoint; actual %v", report.Entrypoints)
+	}
+	actual := report.Entrypoints[0]
+	// Three attempts which time out and 10ms + 20ms of backoff, then the same
+	// again for the fallback, which keeps the retry policy.
+	expectedLatency := Latency{
+		Best:     duration.Duration(50 * time.Millisecond),
+		Expected: duration.Duration(50 * time.Millisecond),
+		Worst:    duration.Duration(225 * time.Millisecond),
+	}
+	if expectedLatency != actual.Latency {
+		t.Errorf("expected %v; actual %v", expectedLatency, actual.Latency)
+	}
+	if actual.ExpectedRequests != 1 || actual.MaxRequests != 6 {
+		t.Errorf("expected 1 and 6 requests; actual %v and %d",
+			actual.ExpectedRequests, actual.MaxRequests)
+	}
+}
+
+func TestAnalyze_Cycles(t *testing.T) {
+	t.Parallel()
+
+	report := analyzeYAML(t, `
+services:
+- name: a
+  isEntrypoint: true
+  script:
+  - call: b
+- name: b
+  script:
+  - call: c
+- name: c
+  script:
+  - call: b
+- name: d
+  isEntrypoint: true
+  script:
+  - call: d
+- name: e
+  isEntrypoint: true
+`)
+
+	expectedCycles := [][]string{{"b", "c"}, {"d"}}
+	if !reflect.DeepEqual(expectedCycles, report.Cycles) {
+		t.Errorf("expected %v; actual %v", expectedCycles, report.Cycles)
+	}
+	for _, entrypoint := range report.Entrypoints {
+		expected := entrypoint.Service != "e"
+		if entrypoint.Cyclic != expected {
+			t.Errorf("expected %s to be cyclic: %v; actual %v",
+				entrypoint.Service, expected, entrypoint.Cyclic)
+		}
+	}
+}
+
+func TestAnalyze_Routes(t *testing.T) {
+	t.Parallel()
+
+	report := analyzeYAML(t, `
+services:
+- name: a
+  script:
+  - call:
+      service: b
+      path: /slow
+- name: b
+  script:
+  - sleep: 1ms
+  routes:
+    /slow:
+      script:
+      - sleep: 1s
+`)
+
+	expected := duration.Duration(time.Second)
+	if actual := report.Entrypoints[0].Latency.Expected; actual != expected {
+		t.Errorf("expected %v; actual %v", expected, actual)
+	}
+}
+
+func TestAnalyze_NestedSequence(t *testing.T) {
+	t.Parallel()
+
+	report := analyzeYAML(t, `
+services:
+- name: a
+  script:
+  - - call: b
+    - sequence:
+      - sleep: 30ms
+      - call: b
+- name: b
+  script:
+  - sleep: 20ms
+`)
+
+	// The sequence takes 30ms + 20ms, longer than the concurrent call.
+	expected := duration.Duration(50 * time.Millisecond)
+	if actual := report.Entrypoints[0].Latency.Expected; actual != expected {
+		t.Errorf("expected %v; actual %v", expected, actual)
+	}
+}
+
+func TestAnalyze_LoopsAndBranches(t *testing.T) {
+	t.Parallel()
+
+	report := analyzeYAML(t, `
+defaults:
+  requestSize: 10
+services:
+- name: a
+  script:
+  - repeat:
+      count: 2
+      body:
+      - call: b
+  - choose:
+    - weight: 3
+      script:
+      - sleep: 40ms
+    - weight: 1
+      script:
+      - call: b
+  - fanout:
+      service: b
+      count: 5
+      concurrency: 2
+- name: b
+  script:
+  - sleep: 10ms
+`)
+
+	// 2 repeated calls, 0 or 1 chosen call and 5 fanned out calls in 3
+	// batches, each call taking 10ms.
+	expected := EntrypointReport{
+		Service:          "a",
+		MaxDepth:         1,
+		ExpectedRequests: 7.25,
+		MaxRequests:      8,
+		Latency: Latency{
+			Best:     duration.Duration(60 * time.Millisecond),
+			Expected: duration.Duration(82500 * time.Microsecond),
+			Worst:    duration.Duration(90 * time.Millisecond),
+		},
+		ExpectedBytes: 73,
+		MaxBytes:      80,
+	}
+	if actual := report.Entrypoints[0]; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v; actual %+v", expected, actual)
+	}
+}
+
+func TestAnalyze_Versions(t *testing.T) {
+	t.Parallel()
+
+	report := analyzeYAML(t, `
+services:
+- name: a
+  isEntrypoint: true
+  script:
+  - call: b
+  - call: c
+- name: b
+  versions:
+  - name: v1
+    numReplicas: 3
+    script:
+    - sleep: 10ms
+  - name: v2
+    script:
+    - call: d
+- name: c
+  script:
+  - sleep: 10ms
+  versions:
+  - name: v1
+  - name: v2
+    script:
+    - call: d
+  istio:
+    trafficSplit:
+      v1: 50
+      v2: 50
+- name: d
+  script:
+  - sleep: 40ms
+`)
+
+	// b runs v1 for 3 of 4 requests, by replicas, and c runs either version
+	// for half of the requests, by traffic split.
+	expected := EntrypointReport{
+		Service:          "a",
+		MaxDepth:         2,
+		ExpectedRequests: 2.75,
+		MaxRequests:      4,
+		Latency: Latency{
+			Best:     duration.Duration(20 * time.Millisecond),
+			Expected: duration.Duration(42500 * time.Microsecond),
+			Worst:    duration.Duration(80 * time.Millisecond),
+		},
+	}
+	if actual := report.Entrypoints[0]; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v; actual %+v", expected, actual)
+	}
+}
@@ -22,9 +22,30 @@ const (
 	ServicePort = 8080
 	// ServicePortName is the name of the service port.
 	ServicePortName = "http-web"
+	// ServiceGRPCPortName is the name of the service port for gRPC services,
+	// which lets Istio detect the protocol.
+	ServiceGRPCPortName = "grpc-web"
+	// ServiceHealthPath is the path on the service port which responds 200 OK
+	// while the service is up, without running its script.
+	ServiceHealthPath = "/healthz"
+	// ServiceGRPCName is the fully-qualified name of the generic gRPC service
+	// exposed by every service.
+	ServiceGRPCName = "isotope.MockService"
+	// ServiceGRPCMethod is the full name of the unary method of the gRPC
+	// service which runs the script of the service.
+	ServiceGRPCMethod = "/" + ServiceGRPCName + "/Call"
+	// ServiceSeedHeader is the header, or gRPC metadata, carrying the seed
+	// services draw the random decisions of a request from.
+	ServiceSeedHeader = "X-Isotope-Seed"
+	// ServiceRequestIDHeader is the header carrying the ID of a request, which
+	// seeded services derive the seed of requests without one from.
+	ServiceRequestIDHeader = "X-Request-Id"
 
 	// ConfigPath is the parent directory of all service configuration files.
 	ConfigPath = "/etc/config"
+	// TLSPath is the directory of the certificate and private key, tls.crt and
+	// tls.key, services calling over TLS also serve TLS with.
+	TLSPath = "/etc/tls"
 	// ServiceGraphYAMLFileName is the name of the file which contains the
 	// YAML-unmarshallable ServiceGraph.
 	ServiceGraphYAMLFileName = "service-graph.yaml"
@@ -37,6 +58,10 @@ const (
 	// the name of the service.
 	ServiceNameEnvKey = "SERVICE_NAME"
 
+	// ServiceVersionEnvKey is the key of the environment variable whose value
+	// is the version of the service, if it has versions.
+	ServiceVersionEnvKey = "SERVICE_VERSION"
+
 	// ConfigPathEnvKey is the key of the environment variable whose value is
 	// the path to the yaml file.
 	ConfigPathEnvKey = "CONFIG_PATH"
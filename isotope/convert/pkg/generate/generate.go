@@ -0,0 +1,408 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package generate builds synthetic service graphs of common shapes.
+package generate
+
+import (
+	"fmt"
+	"math/rand"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+	"istio.io/tools/isotope/convert/pkg/graph/size"
+	"istio.io/tools/isotope/convert/pkg/graph/svc"
+	"istio.io/tools/isotope/convert/pkg/graph/svctype"
+)
+
+// Shape is the shape of a generated service graph.
+type Shape string
+
+const (
+	// ShapeTree is a tree in which every service calls FanOut children
+	// concurrently, Depth levels deep.
+	ShapeTree Shape = "tree"
+	// ShapeChain is a chain of Services services, each calling the next.
+	ShapeChain Shape = "chain"
+	// ShapeDAG is a random directed acyclic graph of Services services in
+	// which each service calls each later one with probability Density.
+	ShapeDAG Shape = "dag"
+	// ShapeScaleFree is a graph of Services services grown by preferential
+	// attachment: each new service calls FanOut existing services, picked in
+	// proportion to how often they are already called.
+	ShapeScaleFree Shape = "scale-free"
+	// ShapeRealistic is a graph of Services services layered like a typical
+	// microservice application: a gateway, frontends, business services and
+	// backends, with varied sizes, latencies and optional calls.
+	ShapeRealistic Shape = "realistic"
+)
+
+// MaxServices is the largest number of services Generate generates, beyond
+// which the graph could not be deployed or even held in memory.
+const MaxServices = 100000
+
+// Params describe the service graph to generate.
+type Params struct {
+	Shape Shape
+
+	// Services is the number of services of every shape but trees.
+	Services int
+	// Depth is the number of levels below the root of a tree.
+	Depth int
+	// FanOut is the number of children of each service of a tree, and the
+	// number of services each new service of a scale-free graph calls.
+	FanOut int
+	// Density is the probability of a call between two services of a DAG.
+	Density float64
+
+	// Namespaces spreads the services over this many namespaces, if set.
+	Namespaces int
+	// Clusters spreads the services, or their namespaces, over this many
+	// clusters, if set.
+	Clusters int
+
+	// RequestSize is the size of every request. If unset, requests are empty,
+	// except in realistic graphs which pick varied sizes.
+	RequestSize size.ByteSize
+	// ResponseSize is the size of every response. If unset, responses are
+	// empty, except in realistic graphs which pick varied sizes.
+	ResponseSize size.ByteSize
+	// Sleep, a SleepCommand or SleepDistributionCommand, starts the script of
+	// every service. If unset, services do not sleep, except in realistic
+	// graphs which sleep for a distribution depending on the layer.
+	Sleep script.Command
+
+	// Seed seeds the random choices. The same Params always generate the same
+	// service graph.
+	Seed int64
+}
+
+// topology is the shape of a service graph before it is turned into services.
+type topology struct {
+	names []string
+	// steps of each service, each calling one service or, if it lists
+	// several, calling them concurrently.
+	steps [][][]call
+	// entrypoints are the services exposed publicly.
+	entrypoints map[int]bool
+	// responseSizes and sleeps override those of Params, if set.
+	responseSizes []size.ByteSize
+	sleeps        []script.Command
+}
+
+type call struct {
+	to          int
+	size        size.ByteSize
+	probability int
+}
+
+// Generate builds the service graph described by p.
+func Generate(p Params) (graph.ServiceGraph, error) {
+	if err := p.validate(); err != nil {
+		return graph.ServiceGraph{}, err
+	}
+	r := rand.New(rand.NewSource(p.Seed))
+
+	var t topology
+	switch p.Shape {
+	case ShapeTree:
+		t = tree(p)
+	case ShapeChain:
+		t = chain(p)
+	case ShapeDAG:
+		t = randomDAG(p, r)
+	case ShapeScaleFree:
+		t = scaleFree(p, r)
+	case ShapeRealistic:
+		t = realistic(p, r)
+	}
+	return t.serviceGraph(p), nil
+}
+
+func (p Params) validate() error {
+	switch p.Shape {
+	case ShapeTree:
+		if p.Depth < 0 || p.FanOut < 1 {
+			return InvalidParamsError{p.Shape, "depth must be non-negative and fan-out positive"}
+		}
+		if treeSize(p.Depth, p.FanOut) > MaxServices {
+			return InvalidParamsError{p.Shape, fmt.Sprintf(
+				"depth %d and fan-out %d make more than %d services",
+				p.Depth, p.FanOut, MaxServices)}
+		}
+	case ShapeChain, ShapeDAG:
+		if p.Services < 1 {
+			return InvalidParamsError{p.Shape, "services must be positive"}
+		}
+		if p.Shape == ShapeDAG && (p.Density < 0 || p.Density > 1) {
+			return InvalidParamsError{p.Shape, "density must be between 0 and 1"}
+		}
+	case ShapeScaleFree:
+		if p.Services < 1 || p.FanOut < 1 {
+			return InvalidParamsError{p.Shape, "services and fan-out must be positive"}
+		}
+		if p.FanOut > p.Services {
+			return InvalidParamsError{p.Shape, "fan-out must not exceed services"}
+		}
+	case ShapeRealistic:
+		if p.Services < 3 {
+			return InvalidParamsError{p.Shape, "services must be at least 3"}
+		}
+	default:
+		return UnknownShapeError{p.Shape}
+	}
+	if p.Shape != ShapeTree && p.Services > MaxServices {
+		return InvalidParamsError{p.Shape, fmt.Sprintf(
+			"services must be at most %d", MaxServices)}
+	}
+	if p.Namespaces < 0 || p.Clusters < 0 {
+		return InvalidParamsError{p.Shape, "namespaces and clusters must be non-negative"}
+	}
+	switch p.Sleep.(type) {
+	case nil, script.SleepCommand, script.SleepDistributionCommand:
+	default:
+		return InvalidParamsError{p.Shape, fmt.Sprintf("sleep must be a sleep command, not %T", p.Sleep)}
+	}
+	return nil
+}
+
+func newTopology(n int) topology {
+	return topology{
+		names:         make([]string, n),
+		steps:         make([][][]call, n),
+		entrypoints:   map[int]bool{0: true},
+		responseSizes: make([]size.ByteSize, n),
+		sleeps:        make([]script.Command, n),
+	}
+}
+
+// concurrently calls every service of tos, with a request of p.RequestSize,
+// in a single step.
+func concurrently(p Params, tos []int) [][]call {
+	if len(tos) == 0 {
+		return nil
+	}
+	calls := make([]call, len(tos))
+	for i, to := range tos {
+		calls[i] = call{to: to, size: p.RequestSize}
+	}
+	return [][]call{calls}
+}
+
+// treeSize returns the number of services of a tree of depth and fanOut, or
+// MaxServices+1 if there are more.
+func treeSize(depth int, fanOut int) int {
+	n, level := 1, 1
+	for d := 0; d < depth; d++ {
+		if level > MaxServices/fanOut {
+			return MaxServices + 1
+		}
+		level *= fanOut
+		n += level
+		if n > MaxServices {
+			return MaxServices + 1
+		}
+	}
+	return n
+}
+
+// tree names services after their path from the root, like svc-0-3-1.
+func tree(p Params) topology {
+	n := treeSize(p.Depth, p.FanOut)
+	t := newTopology(n)
+	t.names[0] = "svc-0"
+	// Services are numbered breadth first, so the children of i are the
+	// FanOut services starting at i*FanOut+1.
+	for i := 0; i < n; i++ {
+		first := i*p.FanOut + 1
+		if first >= n {
+			continue
+		}
+		children := make([]int, p.FanOut)
+		for c := range children {
+			children[c] = first + c
+			t.names[first+c] = fmt.Sprintf("%s-%d", t.names[i], c)
+		}
+		t.steps[i] = concurrently(p, children)
+	}
+	return t
+}
+
+func chain(p Params) topology {
+	t := newTopology(p.Services)
+	for i := range t.names {
+		t.names[i] = fmt.Sprintf("svc-%d", i)
+		if i+1 < p.Services {
+			t.steps[i] = concurrently(p, []int{i + 1})
+		}
+	}
+	return t
+}
+
+// randomDAG only adds calls from earlier to later services, so it never makes
+// cycles. Services left without a caller are called by a random earlier
+// service, so every service is reachable from svc-0.
+func randomDAG(p Params, r *rand.Rand) topology {
+	t := newTopology(p.Services)
+	callees := make([][]int, p.Services)
+	called := make([]bool, p.Services)
+	for j := 0; j < p.Services; j++ {
+		t.names[j] = fmt.Sprintf("svc-%d", j)
+		for i := 0; i < j; i++ {
+			if r.Float64() < p.Density {
+				callees[i] = append(callees[i], j)
+				called[j] = true
+			}
+		}
+		if j > 0 && !called[j] {
+			i := r.Intn(j)
+			callees[i] = append(callees[i], j)
+		}
+	}
+	for i := range callees {
+		t.steps[i] = concurrently(p, callees[i])
+	}
+	return t
+}
+
+// scaleFree grows the graph one service at a time. The newest service is
+// svc-0, so services only call services with higher numbers; services which
+// end up without a caller are entrypoints.
+func scaleFree(p Params, r *rand.Rand) topology {
+	n := p.Services
+	t := newTopology(n)
+	callees := make([][]int, n)
+	called := make([]bool, n)
+	// weights holds a service once, plus once per caller, so drawing from it
+	// prefers services which are already called often.
+	weights := make([]int, 0, n*(p.FanOut+1))
+	for added := 0; added < n; added++ {
+		i := n - 1 - added
+		t.names[i] = fmt.Sprintf("svc-%d", i)
+		chosen := make(map[int]bool, p.FanOut)
+		for len(chosen) < p.FanOut && len(chosen) < added {
+			j := weights[r.Intn(len(weights))]
+			if chosen[j] {
+				continue
+			}
+			chosen[j] = true
+			callees[i] = append(callees[i], j)
+			called[j] = true
+			weights = append(weights, j)
+		}
+		weights = append(weights, i)
+	}
+	for i := range callees {
+		t.steps[i] = concurrently(p, callees[i])
+		if !called[i] {
+			t.entrypoints[i] = true
+		}
+	}
+	return t
+}
+
+// serviceGraph turns t into services placed in namespaces and clusters.
+func (t topology) serviceGraph(p Params) graph.ServiceGraph {
+	n := len(t.names)
+	services := make([]svc.Service, n)
+	for i, name := range t.names {
+		services[i] = svc.Service{
+			Name:         name,
+			Type:         svctype.ServiceHTTP,
+			NumReplicas:  1,
+			IsEntrypoint: t.entrypoints[i],
+			ResponseSize: p.ResponseSize,
+		}
+		if t.responseSizes[i] != 0 {
+			services[i].ResponseSize = t.responseSizes[i]
+		}
+		// Contiguous services share namespaces, and contiguous namespaces
+		// share clusters, so that most calls stay close.
+		if p.Namespaces > 0 {
+			ns := i * p.Namespaces / n
+			services[i].Namespace = fmt.Sprintf("ns-%d", ns)
+			if p.Clusters > 0 {
+				services[i].Cluster = fmt.Sprintf("cluster-%d", ns*p.Clusters/p.Namespaces)
+			}
+		} else if p.Clusters > 0 {
+			services[i].Cluster = fmt.Sprintf("cluster-%d", i*p.Clusters/n)
+		}
+	}
+
+	for i := range services {
+		var s script.Script
+		sleep := p.Sleep
+		if t.sleeps[i] != nil {
+			sleep = t.sleeps[i]
+		}
+		if sleep != nil {
+			s = append(s, sleep)
+		}
+		for _, step := range t.steps[i] {
+			cmds := make(script.ConcurrentCommand, len(step))
+			for k, c := range step {
+				cmds[k] = script.RequestCommand{
+					ServiceName: services[c.to].Name,
+					Hostname:    hostname(services[i], services[c.to]),
+					Size:        c.size,
+					Probability: c.probability,
+				}
+			}
+			if len(cmds) == 1 {
+				s = append(s, cmds[0])
+			} else {
+				s = append(s, cmds)
+			}
+		}
+		services[i].Script = s
+	}
+	return graph.ServiceGraph{Services: services}
+}
+
+// hostname returns the host from which from calls to.
+func hostname(from svc.Service, to svc.Service) string {
+	switch {
+	case from.Cluster != to.Cluster:
+		namespace := to.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		return fmt.Sprintf("%s.%s.global:8080", to.Name, namespace)
+	case from.Namespace != to.Namespace:
+		return fmt.Sprintf("%s.%s.svc.cluster.local:8080", to.Name, to.Namespace)
+	default:
+		return fmt.Sprintf("%s:8080", to.Name)
+	}
+}
+
+// UnknownShapeError is returned when the shape of a graph is not recognized.
+type UnknownShapeError struct {
+	Shape Shape
+}
+
+func (e UnknownShapeError) Error() string {
+	return fmt.Sprintf(
+		"unknown shape: %q (must be %q, %q, %q, %q or %q)", e.Shape,
+		ShapeTree, ShapeChain, ShapeDAG, ShapeScaleFree, ShapeRealistic)
+}
+
+// InvalidParamsError is returned when the parameters of a shape are invalid.
+type InvalidParamsError struct {
+	Shape  Shape
+	Reason string
+}
+
+func (e InvalidParamsError) Error() string {
+	return fmt.Sprintf("invalid parameters for %s: %s", e.Shape, e.Reason)
+}
@@ -0,0 +1,260 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+	"istio.io/tools/isotope/convert/pkg/graph/svc"
+)
+
+// callees returns the names of the services called by each service.
+func callees(g graph.ServiceGraph) map[string][]string {
+	calls := make(map[string][]string, len(g.Services))
+	var visit func(from string, cmd script.Command)
+	visit = func(from string, cmd script.Command) {
+		switch cmd := cmd.(type) {
+		case script.RequestCommand:
+			calls[from] = append(calls[from], cmd.ServiceName)
+		case script.ConcurrentCommand:
+			for _, sub := range cmd {
+				visit(from, sub)
+			}
+		}
+	}
+	for _, service := range g.Services {
+		for _, cmd := range service.Script {
+			visit(service.Name, cmd)
+		}
+	}
+	return calls
+}
+
+func names(services []svc.Service) []string {
+	ns := make([]string, len(services))
+	for i, service := range services {
+		ns[i] = service.Name
+	}
+	return ns
+}
+
+func TestGenerate_Tree(t *testing.T) {
+	g, err := Generate(Params{Shape: ShapeTree, Depth: 2, FanOut: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"svc-0", "svc-0-0", "svc-0-1",
+		"svc-0-0-0", "svc-0-0-1", "svc-0-1-0", "svc-0-1-1",
+	}
+	if actual := names(g.Services); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v; actual %v", expected, actual)
+	}
+	calls := callees(g)
+	if actual := calls["svc-0-1"]; !reflect.DeepEqual([]string{"svc-0-1-0", "svc-0-1-1"}, actual) {
+		t.Errorf("expected svc-0-1 to call its children; actual %v", actual)
+	}
+	if !g.Services[0].IsEntrypoint {
+		t.Error("expected svc-0 to be an entrypoint")
+	}
+}
+
+func TestGenerate_Chain(t *testing.T) {
+	sleep := script.SleepCommand(10 * time.Millisecond)
+	g, err := Generate(Params{Shape: ShapeChain, Services: 3, RequestSize: 128, Sleep: sleep})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := script.Script{
+		sleep,
+		script.RequestCommand{ServiceName: "svc-1", Hostname: "svc-1:8080", Size: 128},
+	}
+	if !reflect.DeepEqual(expected, g.Services[0].Script) {
+		t.Errorf("expected %v; actual %v", expected, g.Services[0].Script)
+	}
+	if len(g.Services[2].Script) != 1 {
+		t.Errorf("expected the last service to only sleep; actual %v", g.Services[2].Script)
+	}
+}
+
+func TestGenerate_Shapes(t *testing.T) {
+	tests := []Params{
+		{Shape: ShapeDAG, Services: 30, Density: 0.1},
+		{Shape: ShapeScaleFree, Services: 30, FanOut: 2},
+		{Shape: ShapeRealistic, Services: 3},
+		{Shape: ShapeRealistic, Services: 60, Namespaces: 3, Clusters: 2},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(string(test.Shape), func(t *testing.T) {
+			t.Parallel()
+
+			g, err := Generate(test)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(g.Services) != test.Services {
+				t.Fatalf("expected %d services; actual %d", test.Services, len(g.Services))
+			}
+
+			// Services only call services after them, so the graph is acyclic,
+			// and every service is reachable from an entrypoint.
+			index := make(map[string]int, len(g.Services))
+			for i, service := range g.Services {
+				index[service.Name] = i
+			}
+			reached := make(map[string]bool)
+			calls := callees(g)
+			for i, service := range g.Services {
+				if service.IsEntrypoint {
+					reached[service.Name] = true
+				}
+				if !reached[service.Name] {
+					t.Errorf("%s is not reachable from an entrypoint", service.Name)
+				}
+				for _, callee := range calls[service.Name] {
+					if index[callee] <= i {
+						t.Errorf("%s calls %s before it", service.Name, callee)
+					}
+					reached[callee] = true
+				}
+			}
+			if test.Shape == ShapeScaleFree {
+				for from, to := range calls {
+					if len(to) > test.FanOut {
+						t.Errorf("%s calls %d services; expected at most %d", from, len(to), test.FanOut)
+					}
+				}
+			}
+
+			// The graph survives a round trip through YAML, which validates it.
+			b, err := yaml.Marshal(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var parsed graph.ServiceGraph
+			if err := yaml.Unmarshal(b, &parsed); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestGenerate_Deterministic(t *testing.T) {
+	params := Params{Shape: ShapeRealistic, Services: 40, Namespaces: 2, Seed: 42}
+	first, err := Generate(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Generate(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Error("expected the same seed to generate the same graph")
+	}
+
+	params.Seed = 43
+	third, err := Generate(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(first, third) {
+		t.Error("expected different seeds to generate different graphs")
+	}
+}
+
+func TestGenerate_Placement(t *testing.T) {
+	g, err := Generate(Params{Shape: ShapeChain, Services: 4, Namespaces: 2, Clusters: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		namespace, cluster, hostname string
+	}{
+		{"ns-0", "cluster-0", "svc-1:8080"},
+		{"ns-0", "cluster-0", "svc-2.ns-1.global:8080"},
+		{"ns-1", "cluster-1", "svc-3:8080"},
+		{"ns-1", "cluster-1", ""},
+	}
+	for i, service := range g.Services {
+		if service.Namespace != expected[i].namespace || service.Cluster != expected[i].cluster {
+			t.Errorf("expected %s in %s/%s; actual %s/%s", service.Name,
+				expected[i].namespace, expected[i].cluster, service.Namespace, service.Cluster)
+		}
+		hostname := ""
+		if len(service.Script) > 0 {
+			hostname = service.Script[0].(script.RequestCommand).Hostname
+		}
+		if hostname != expected[i].hostname {
+			t.Errorf("expected %s to call %q; actual %q", service.Name, expected[i].hostname, hostname)
+		}
+	}
+}
+
+func TestGenerate_Invalid(t *testing.T) {
+	tests := []struct {
+		params Params
+		err    error
+	}{
+		{Params{Shape: "ring"}, UnknownShapeError{"ring"}},
+		{
+			Params{Shape: ShapeTree, Depth: 2},
+			InvalidParamsError{ShapeTree, "depth must be non-negative and fan-out positive"},
+		},
+		{
+			Params{Shape: ShapeDAG, Services: 2, Density: 2},
+			InvalidParamsError{ShapeDAG, "density must be between 0 and 1"},
+		},
+		{
+			Params{Shape: ShapeRealistic, Services: 2},
+			InvalidParamsError{ShapeRealistic, "services must be at least 3"},
+		},
+		{
+			Params{Shape: ShapeTree, Depth: 10, FanOut: 10},
+			InvalidParamsError{ShapeTree, "depth 10 and fan-out 10 make more than 100000 services"},
+		},
+		{
+			Params{Shape: ShapeTree, Depth: 100000, FanOut: 1},
+			InvalidParamsError{ShapeTree, "depth 100000 and fan-out 1 make more than 100000 services"},
+		},
+		{
+			Params{Shape: ShapeScaleFree, Services: 10, FanOut: 11},
+			InvalidParamsError{ShapeScaleFree, "fan-out must not exceed services"},
+		},
+		{
+			Params{Shape: ShapeChain, Services: 1000000},
+			InvalidParamsError{ShapeChain, "services must be at most 100000"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := Generate(test.params); err != test.err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+		})
+	}
+}
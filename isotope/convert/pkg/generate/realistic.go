@@ -0,0 +1,162 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/dist"
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+	"istio.io/tools/isotope/convert/pkg/graph/size"
+)
+
+// layer is a tier of a realistic microservice application.
+type layer struct {
+	name  string
+	sleep dist.Distribution
+	// responseSizes are picked from at random.
+	responseSizes []size.ByteSize
+}
+
+var (
+	gatewayLayer = layer{
+		name:          "gateway",
+		sleep:         dist.LogNormal{Median: 500 * time.Microsecond, P99: 5 * time.Millisecond},
+		responseSizes: []size.ByteSize{4 << 10, 16 << 10},
+	}
+	frontendLayer = layer{
+		name:          "frontend",
+		sleep:         dist.LogNormal{Median: time.Millisecond, P99: 10 * time.Millisecond},
+		responseSizes: []size.ByteSize{4 << 10, 16 << 10, 64 << 10},
+	}
+	serviceLayer = layer{
+		name:          "service",
+		sleep:         dist.LogNormal{Median: 2 * time.Millisecond, P99: 20 * time.Millisecond},
+		responseSizes: []size.ByteSize{256, 1 << 10, 4 << 10},
+	}
+	backendLayer = layer{
+		name:          "backend",
+		sleep:         dist.LogNormal{Median: 5 * time.Millisecond, P99: 100 * time.Millisecond},
+		responseSizes: []size.ByteSize{128, 1 << 10, 16 << 10},
+	}
+
+	requestSizes = []size.ByteSize{128, 512, 1 << 10}
+)
+
+// realistic lays out a gateway, frontends (a tenth of the services), business
+// services and backends (a fifth of the services). The gateway routes each
+// request to one frontend on average. Frontends and business services make a
+// first call, like authentication, then fan out concurrently to business
+// services further down or to backends; some of those calls are optional,
+// like cache misses. Backends call nothing.
+func realistic(p Params, r *rand.Rand) topology {
+	n := p.Services
+	numFrontends := max(1, n/10)
+	numBackends := max(1, n/5)
+	numServices := n - 1 - numFrontends - numBackends
+	if numServices < 0 {
+		numServices = 0
+		numBackends = n - 1 - numFrontends
+	}
+	firstService := 1 + numFrontends
+	firstBackend := firstService + numServices
+
+	t := newTopology(n)
+	layers := make([]layer, n)
+	for i := range t.names {
+		switch {
+		case i == 0:
+			layers[i] = gatewayLayer
+			t.names[i] = gatewayLayer.name
+		case i < firstService:
+			layers[i] = frontendLayer
+			t.names[i] = fmt.Sprintf("%s-%d", frontendLayer.name, i-1)
+		case i < firstBackend:
+			layers[i] = serviceLayer
+			t.names[i] = fmt.Sprintf("%s-%d", serviceLayer.name, i-firstService)
+		default:
+			layers[i] = backendLayer
+			t.names[i] = fmt.Sprintf("%s-%d", backendLayer.name, i-firstBackend)
+		}
+		t.responseSizes[i] = p.ResponseSize
+		if p.ResponseSize == 0 {
+			l := layers[i]
+			t.responseSizes[i] = l.responseSizes[r.Intn(len(l.responseSizes))]
+		}
+		t.sleeps[i] = p.Sleep
+		if p.Sleep == nil {
+			t.sleeps[i] = script.SleepDistributionCommand{Distribution: layers[i].sleep}
+		}
+	}
+
+	newCall := func(to int, probability int) call {
+		c := call{to: to, size: p.RequestSize, probability: probability}
+		if p.RequestSize == 0 {
+			c.size = requestSizes[r.Intn(len(requestSizes))]
+		}
+		return c
+	}
+
+	called := make([]bool, n)
+	// Frontends route by probability, so the gateway calls one on average.
+	routes := make([]call, 0, numFrontends)
+	probability := 0
+	if numFrontends > 1 {
+		probability = max(1, 100/numFrontends)
+	}
+	for i := 1; i < firstService; i++ {
+		routes = append(routes, newCall(i, probability))
+		called[i] = true
+	}
+	t.steps[0] = [][]call{routes}
+
+	// Every other caller calls later services only, so the graph is acyclic.
+	for i := 1; i < firstBackend; i++ {
+		first := max(i+1, firstService)
+		candidates := n - first
+		numCalls := min(candidates, 1+r.Intn(4))
+		picked := r.Perm(candidates)[:numCalls]
+		var fanOut []call
+		for k, j := range picked {
+			to := first + j
+			called[to] = true
+			if k == 0 {
+				t.steps[i] = append(t.steps[i], []call{newCall(to, 0)})
+				continue
+			}
+			probability := 0
+			if r.Float64() < 0.2 {
+				probability = 50 + r.Intn(41)
+			}
+			fanOut = append(fanOut, newCall(to, probability))
+		}
+		if len(fanOut) > 0 {
+			t.steps[i] = append(t.steps[i], fanOut)
+		}
+	}
+
+	// Services nobody picked are called by a random frontend or business
+	// service before them.
+	for j := firstService; j < n; j++ {
+		if called[j] {
+			continue
+		}
+		i := 1 + r.Intn(min(j, firstBackend)-1)
+		t.steps[i] = append(t.steps[i], []call{newCall(j, 0)})
+	}
+	return t
+}
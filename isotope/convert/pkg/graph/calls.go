@@ -0,0 +1,108 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import "istio.io/tools/isotope/convert/pkg/graph/script"
+
+// Calls returns the names of the services each service of g may call, from
+// its script, its routes, its versions and their fallbacks.
+func (g ServiceGraph) Calls() map[string][]string {
+	calls := make(map[string][]string, len(g.Services))
+	for from, requests := range g.Requests() {
+		for _, request := range requests {
+			calls[from] = append(calls[from], request.ServiceName)
+		}
+	}
+	return calls
+}
+
+// Requests returns the requests each service of g may send, from its script,
+// its routes, its versions and their fallbacks.
+func (g ServiceGraph) Requests() map[string][]script.RequestCommand {
+	requests := make(map[string][]script.RequestCommand, len(g.Services))
+	var visit func(from string, cmd script.Command)
+	visit = func(from string, cmd script.Command) {
+		switch cmd := cmd.(type) {
+		case script.RequestCommand:
+			requests[from] = append(requests[from], cmd)
+			if fallback, ok := cmd.FallbackCommand(); ok {
+				visit(from, fallback)
+			}
+		case script.ConcurrentCommand:
+			for _, sub := range cmd {
+				visit(from, sub)
+			}
+		case script.SequenceCommand:
+			for _, sub := range cmd {
+				visit(from, sub)
+			}
+		case script.RepeatCommand:
+			for _, sub := range cmd.Body {
+				visit(from, sub)
+			}
+		case script.ChooseCommand:
+			for _, branch := range cmd {
+				for _, sub := range branch.Script {
+					visit(from, sub)
+				}
+			}
+		case script.FanoutCommand:
+			visit(from, cmd.Call)
+		}
+	}
+	for _, service := range g.Services {
+		for _, cmd := range service.Script {
+			visit(service.Name, cmd)
+		}
+		for _, route := range service.Routes {
+			for _, cmd := range route.Script {
+				visit(service.Name, cmd)
+			}
+		}
+		for _, version := range service.Versions {
+			for _, cmd := range version.Script {
+				visit(service.Name, cmd)
+			}
+		}
+	}
+	return requests
+}
+
+// Entrypoints returns the names of the services marked as entrypoints or, if
+// there are none, of the services no other service calls.
+func (g ServiceGraph) Entrypoints() []string {
+	var names []string
+	for _, service := range g.Services {
+		if service.IsEntrypoint {
+			names = append(names, service.Name)
+		}
+	}
+	if len(names) > 0 {
+		return names
+	}
+
+	called := make(map[string]bool)
+	for _, callees := range g.Calls() {
+		for _, callee := range callees {
+			called[callee] = true
+		}
+	}
+	for _, service := range g.Services {
+		if !called[service.Name] {
+			names = append(names, service.Name)
+		}
+	}
+	return names
+}
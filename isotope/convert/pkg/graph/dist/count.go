@@ -0,0 +1,192 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dist
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+const poissonName = "poisson"
+
+// poissonNormalThreshold is the mean above which Poisson samples and
+// quantiles are approximated by a normal distribution.
+const poissonNormalThreshold = 30
+
+// CountDistribution is a random distribution of non-negative counts.
+type CountDistribution interface {
+	// Sample draws a count from the distribution.
+	Sample(r Rand) int
+	// Mean is the expected value of the distribution.
+	Mean() float64
+	// Quantile returns the count below or at which a fraction q of samples
+	// fall.
+	Quantile(q float64) int
+	String() string
+}
+
+// UniformCount is a uniform distribution of the counts from Min to Max,
+// inclusive.
+type UniformCount struct {
+	Min int
+	Max int
+}
+
+// Sample draws a count from the distribution.
+func (d UniformCount) Sample(r Rand) int {
+	return d.Quantile(r.Float64())
+}
+
+// Mean is the expected value of the distribution.
+func (d UniformCount) Mean() float64 {
+	return float64(d.Min+d.Max) / 2
+}
+
+// Quantile returns the count below or at which a fraction q of samples fall.
+func (d UniformCount) Quantile(q float64) int {
+	n := d.Min + int(clamp(q)*float64(d.Max-d.Min+1))
+	if n > d.Max {
+		return d.Max
+	}
+	return n
+}
+
+func (d UniformCount) String() string {
+	return fmt.Sprintf("%s(min=%d, max=%d)", uniformName, d.Min, d.Max)
+}
+
+// MarshalJSON encodes the distribution as a JSON object.
+func (d UniformCount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonCountDistribution{
+		Distribution: uniformName,
+		Min:          &d.Min,
+		Max:          &d.Max,
+	})
+}
+
+// PoissonCount is a Poisson distribution with mean Mu. It models the number
+// of items in a request, such as the products in a cart.
+type PoissonCount struct {
+	Mu float64
+}
+
+// Sample draws a count from the distribution.
+func (d PoissonCount) Sample(r Rand) int {
+	if d.Mu > poissonNormalThreshold {
+		return toCount(d.Mu + math.Sqrt(d.Mu)*r.NormFloat64())
+	}
+	// Knuth's algorithm: count uniform samples until their product drops
+	// below e^-Mu.
+	limit := math.Exp(-d.Mu)
+	n := 0
+	for p := r.Float64(); p > limit; p *= r.Float64() {
+		n++
+	}
+	return n
+}
+
+// Mean is the expected value of the distribution.
+func (d PoissonCount) Mean() float64 {
+	return d.Mu
+}
+
+// Quantile returns the count below or at which a fraction q of samples fall.
+func (d PoissonCount) Quantile(q float64) int {
+	q = clamp(q)
+	if d.Mu > poissonNormalThreshold {
+		return toCount(d.Mu + math.Sqrt(d.Mu)*normalQuantile(q))
+	}
+	p := math.Exp(-d.Mu)
+	cumulative := p
+	n := 0
+	for cumulative < q && p > 0 {
+		n++
+		p *= d.Mu / float64(n)
+		cumulative += p
+	}
+	return n
+}
+
+func (d PoissonCount) String() string {
+	return fmt.Sprintf("%s(mean=%g)", poissonName, d.Mu)
+}
+
+// MarshalJSON encodes the distribution as a JSON object.
+func (d PoissonCount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonCountDistribution{
+		Distribution: poissonName,
+		Mean:         &d.Mu,
+	})
+}
+
+// jsonCountDistribution is the JSON representation of every
+// CountDistribution.
+type jsonCountDistribution struct {
+	Distribution string   `json:"distribution"`
+	Min          *int     `json:"min,omitempty"`
+	Max          *int     `json:"max,omitempty"`
+	Mean         *float64 `json:"mean,omitempty"`
+}
+
+// CountFromJSON converts a JSON object such as
+// {"distribution": "uniform", "min": 1, "max": 5} or
+// {"distribution": "poisson", "mean": 3} to a CountDistribution.
+func CountFromJSON(b []byte) (CountDistribution, error) {
+	var j jsonCountDistribution
+	if err := json.Unmarshal(b, &j); err != nil {
+		return nil, err
+	}
+	switch j.Distribution {
+	case uniformName:
+		if j.Min == nil {
+			return nil, MissingParameterError{uniformName, "min"}
+		}
+		if j.Max == nil {
+			return nil, MissingParameterError{uniformName, "max"}
+		}
+		d := UniformCount{Min: *j.Min, Max: *j.Max}
+		if d.Min < 0 {
+			return nil, InvalidParameterError{uniformName, "min", "must be non-negative"}
+		}
+		if d.Max < d.Min {
+			return nil, InvalidParameterError{uniformName, "max", "must be at least min"}
+		}
+		return d, nil
+	case poissonName:
+		if j.Mean == nil {
+			return nil, MissingParameterError{poissonName, "mean"}
+		}
+		if *j.Mean < 0 {
+			return nil, InvalidParameterError{poissonName, "mean", "must be non-negative"}
+		}
+		return PoissonCount{Mu: *j.Mean}, nil
+	default:
+		return nil, UnknownDistributionError{j.Distribution}
+	}
+}
+
+// toCount rounds f to the nearest count, clamping to the valid non-negative
+// range.
+func toCount(f float64) int {
+	switch {
+	case math.IsNaN(f) || f <= 0:
+		return 0
+	case f >= math.MaxInt32:
+		return math.MaxInt32
+	default:
+		return int(math.Round(f))
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dist
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestCountFromJSON(t *testing.T) {
+	tests := []struct {
+		input        []byte
+		distribution CountDistribution
+		err          error
+	}{
+		{
+			[]byte(`{"distribution": "uniform", "min": 1, "max": 5}`),
+			UniformCount{Min: 1, Max: 5},
+			nil,
+		},
+		{
+			[]byte(`{"distribution": "poisson", "mean": 3}`),
+			PoissonCount{Mu: 3},
+			nil,
+		},
+		{
+			[]byte(`{"distribution": "uniform", "min": 1}`),
+			nil,
+			MissingParameterError{"uniform", "max"},
+		},
+		{
+			[]byte(`{"distribution": "uniform", "min": 5, "max": 1}`),
+			nil,
+			InvalidParameterError{"uniform", "max", "must be at least min"},
+		},
+		{
+			[]byte(`{"distribution": "poisson", "mean": -1}`),
+			nil,
+			InvalidParameterError{"poisson", "mean", "must be non-negative"},
+		},
+		{
+			[]byte(`{"distribution": "lognormal"}`),
+			nil,
+			UnknownDistributionError{"lognormal"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			distribution, err := CountFromJSON(test.input)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if !reflect.DeepEqual(test.distribution, distribution) {
+				t.Errorf("expected %v; actual %v", test.distribution, distribution)
+			}
+		})
+	}
+}
+
+func TestCountDistribution_MarshalJSON(t *testing.T) {
+	for _, d := range []CountDistribution{
+		UniformCount{Min: 0, Max: 5},
+		PoissonCount{Mu: 2.5},
+	} {
+		b, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := CountFromJSON(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(d, actual) {
+			t.Errorf("expected %v; actual %v", d, actual)
+		}
+	}
+}
+
+func TestCountDistribution_Sample(t *testing.T) {
+	tests := []struct {
+		distribution CountDistribution
+		min, max     int
+	}{
+		{UniformCount{Min: 2, Max: 4}, 2, 4},
+		{PoissonCount{Mu: 3}, 0, math.MaxInt32},
+		{PoissonCount{Mu: 100}, 0, math.MaxInt32},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.distribution.String(), func(t *testing.T) {
+			t.Parallel()
+
+			r := rand.New(rand.NewSource(1))
+			const n = 20000
+			var sum int
+			for i := 0; i < n; i++ {
+				sample := test.distribution.Sample(r)
+				if sample < test.min || sample > test.max {
+					t.Fatalf("expected sample in [%d, %d]; actual %d",
+						test.min, test.max, sample)
+				}
+				sum += sample
+			}
+			expected := test.distribution.Mean()
+			actual := float64(sum) / n
+			if math.Abs(actual-expected) > 0.05*expected {
+				t.Errorf("expected mean %v; actual %v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestCountDistribution_Quantile(t *testing.T) {
+	tests := []struct {
+		distribution CountDistribution
+		q            float64
+		expected     int
+	}{
+		{UniformCount{Min: 1, Max: 4}, 0, 1},
+		{UniformCount{Min: 1, Max: 4}, 0.5, 3},
+		{UniformCount{Min: 1, Max: 4}, 1, 4},
+		{PoissonCount{Mu: 3}, 0.5, 3},
+		{PoissonCount{Mu: 3}, 0.99, 8},
+		{PoissonCount{Mu: 0}, 0.99, 0},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			if actual := test.distribution.Quantile(test.q); actual != test.expected {
+				t.Errorf("expected %d; actual %d", test.expected, actual)
+			}
+		})
+	}
+}
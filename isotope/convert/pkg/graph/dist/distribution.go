@@ -0,0 +1,479 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dist describes random distributions of durations and counts.
+package dist
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lognormalName   = "lognormal"
+	uniformName     = "uniform"
+	normalName      = "normal"
+	exponentialName = "exponential"
+	empiricalName   = "empirical"
+)
+
+// Rand is the source of randomness used to sample a Distribution. It is
+// satisfied by *math/rand.Rand.
+type Rand interface {
+	Float64() float64
+	NormFloat64() float64
+	ExpFloat64() float64
+}
+
+// Distribution is a random distribution of non-negative durations.
+type Distribution interface {
+	// Sample draws a duration from the distribution.
+	Sample(r Rand) time.Duration
+	// Mean is the expected value of the distribution.
+	Mean() time.Duration
+	// Quantile returns the duration below which a fraction q of samples fall.
+	Quantile(q float64) time.Duration
+	String() string
+}
+
+// LogNormal is a log-normal distribution described by its median and 99th
+// percentile. It models the long tail of real service latencies.
+type LogNormal struct {
+	Median time.Duration
+	P99    time.Duration
+}
+
+func (d LogNormal) mu() float64 {
+	return math.Log(float64(d.Median))
+}
+
+func (d LogNormal) sigma() float64 {
+	return math.Log(float64(d.P99)/float64(d.Median)) / normalQuantile(0.99)
+}
+
+// Sample draws a duration from the distribution.
+func (d LogNormal) Sample(r Rand) time.Duration {
+	return toDuration(math.Exp(d.mu() + d.sigma()*r.NormFloat64()))
+}
+
+// Mean is the expected value of the distribution.
+func (d LogNormal) Mean() time.Duration {
+	sigma := d.sigma()
+	return toDuration(math.Exp(d.mu() + sigma*sigma/2))
+}
+
+// Quantile returns the duration below which a fraction q of samples fall.
+func (d LogNormal) Quantile(q float64) time.Duration {
+	return toDuration(math.Exp(d.mu() + d.sigma()*normalQuantile(q)))
+}
+
+func (d LogNormal) String() string {
+	return fmt.Sprintf("%s(median=%s, p99=%s)", lognormalName, d.Median, d.P99)
+}
+
+// MarshalJSON encodes the distribution as a JSON object.
+func (d LogNormal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonDistribution{
+		Distribution: lognormalName,
+		Median:       d.Median.String(),
+		P99:          d.P99.String(),
+	})
+}
+
+// Uniform is a uniform distribution between Min and Max.
+type Uniform struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Sample draws a duration from the distribution.
+func (d Uniform) Sample(r Rand) time.Duration {
+	return d.Min + time.Duration(r.Float64()*float64(d.Max-d.Min))
+}
+
+// Mean is the expected value of the distribution.
+func (d Uniform) Mean() time.Duration {
+	return (d.Min + d.Max) / 2
+}
+
+// Quantile returns the duration below which a fraction q of samples fall.
+func (d Uniform) Quantile(q float64) time.Duration {
+	return d.Min + time.Duration(clamp(q)*float64(d.Max-d.Min))
+}
+
+func (d Uniform) String() string {
+	return fmt.Sprintf("%s(min=%s, max=%s)", uniformName, d.Min, d.Max)
+}
+
+// MarshalJSON encodes the distribution as a JSON object.
+func (d Uniform) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonDistribution{
+		Distribution: uniformName,
+		Min:          d.Min.String(),
+		Max:          d.Max.String(),
+	})
+}
+
+// Normal is a normal distribution with mean Mu and standard deviation Sigma.
+// Negative samples are clamped to zero.
+type Normal struct {
+	Mu    time.Duration
+	Sigma time.Duration
+}
+
+// Sample draws a duration from the distribution.
+func (d Normal) Sample(r Rand) time.Duration {
+	return toDuration(float64(d.Mu) + float64(d.Sigma)*r.NormFloat64())
+}
+
+// Mean is the expected value of the distribution, ignoring clamping.
+func (d Normal) Mean() time.Duration {
+	return d.Mu
+}
+
+// Quantile returns the duration below which a fraction q of samples fall.
+func (d Normal) Quantile(q float64) time.Duration {
+	return toDuration(float64(d.Mu) + float64(d.Sigma)*normalQuantile(q))
+}
+
+func (d Normal) String() string {
+	return fmt.Sprintf("%s(mean=%s, stddev=%s)", normalName, d.Mu, d.Sigma)
+}
+
+// MarshalJSON encodes the distribution as a JSON object.
+func (d Normal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonDistribution{
+		Distribution: normalName,
+		Mean:         d.Mu.String(),
+		StdDev:       d.Sigma.String(),
+	})
+}
+
+// Exponential is an exponential distribution with mean Mu.
+type Exponential struct {
+	Mu time.Duration
+}
+
+// Sample draws a duration from the distribution.
+func (d Exponential) Sample(r Rand) time.Duration {
+	return toDuration(float64(d.Mu) * r.ExpFloat64())
+}
+
+// Mean is the expected value of the distribution.
+func (d Exponential) Mean() time.Duration {
+	return d.Mu
+}
+
+// Quantile returns the duration below which a fraction q of samples fall.
+func (d Exponential) Quantile(q float64) time.Duration {
+	return toDuration(-float64(d.Mu) * math.Log(1-clamp(q)))
+}
+
+func (d Exponential) String() string {
+	return fmt.Sprintf("%s(mean=%s)", exponentialName, d.Mu)
+}
+
+// MarshalJSON encodes the distribution as a JSON object.
+func (d Exponential) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonDistribution{
+		Distribution: exponentialName,
+		Mean:         d.Mu.String(),
+	})
+}
+
+// Bucket is a bucket of an Empirical histogram. Value is the upper bound of
+// the bucket and Weight is its relative frequency.
+type Bucket struct {
+	Value  time.Duration
+	Weight float64
+}
+
+// Empirical is a distribution described by a histogram, usually measured from
+// a real service. Buckets are sorted by Value. A sample falls uniformly
+// between the previous bucket's Value (or zero) and its own bucket's Value.
+type Empirical struct {
+	Buckets []Bucket
+}
+
+func (d Empirical) totalWeight() (total float64) {
+	for _, b := range d.Buckets {
+		total += b.Weight
+	}
+	return
+}
+
+// lowerBound returns the lower bound of the bucket at index i.
+func (d Empirical) lowerBound(i int) time.Duration {
+	if i == 0 {
+		return 0
+	}
+	return d.Buckets[i-1].Value
+}
+
+// Sample draws a duration from the distribution.
+func (d Empirical) Sample(r Rand) time.Duration {
+	return d.Quantile(r.Float64())
+}
+
+// Mean is the expected value of the distribution.
+func (d Empirical) Mean() time.Duration {
+	total := d.totalWeight()
+	if total == 0 {
+		return 0
+	}
+	var sum float64
+	for i, b := range d.Buckets {
+		sum += b.Weight * float64(d.lowerBound(i)+b.Value) / 2
+	}
+	return toDuration(sum / total)
+}
+
+// Quantile returns the duration below which a fraction q of samples fall.
+func (d Empirical) Quantile(q float64) time.Duration {
+	target := clamp(q) * d.totalWeight()
+	var cumulative float64
+	for i, b := range d.Buckets {
+		if b.Weight > 0 && cumulative+b.Weight >= target {
+			low := d.lowerBound(i)
+			frac := (target - cumulative) / b.Weight
+			return low + time.Duration(frac*float64(b.Value-low))
+		}
+		cumulative += b.Weight
+	}
+	if len(d.Buckets) == 0 {
+		return 0
+	}
+	return d.Buckets[len(d.Buckets)-1].Value
+}
+
+func (d Empirical) String() string {
+	return fmt.Sprintf(
+		"%s(buckets=%d, mean=%s)", empiricalName, len(d.Buckets), d.Mean())
+}
+
+// MarshalJSON encodes the distribution as a JSON object with its buckets
+// inline, so that the encoded form is self-contained.
+func (d Empirical) MarshalJSON() ([]byte, error) {
+	buckets := make([]jsonBucket, 0, len(d.Buckets))
+	for _, b := range d.Buckets {
+		buckets = append(buckets, jsonBucket{Value: b.Value.String(), Weight: b.Weight})
+	}
+	return json.Marshal(jsonDistribution{
+		Distribution: empiricalName,
+		Buckets:      buckets,
+	})
+}
+
+// jsonDistribution is the JSON representation of every Distribution.
+type jsonDistribution struct {
+	Distribution string       `json:"distribution"`
+	Median       string       `json:"median,omitempty"`
+	P99          string       `json:"p99,omitempty"`
+	Min          string       `json:"min,omitempty"`
+	Max          string       `json:"max,omitempty"`
+	Mean         string       `json:"mean,omitempty"`
+	StdDev       string       `json:"stddev,omitempty"`
+	File         string       `json:"file,omitempty"`
+	Buckets      []jsonBucket `json:"buckets,omitempty"`
+}
+
+type jsonBucket struct {
+	Value  string  `json:"value"`
+	Weight float64 `json:"weight"`
+}
+
+// FromJSON converts a JSON object such as
+// {"distribution": "lognormal", "median": "10ms", "p99": "80ms"} to a
+// Distribution.
+func FromJSON(b []byte) (Distribution, error) {
+	var j jsonDistribution
+	if err := json.Unmarshal(b, &j); err != nil {
+		return nil, err
+	}
+	p := parser{name: j.Distribution}
+	switch j.Distribution {
+	case lognormalName:
+		d := LogNormal{
+			Median: p.duration("median", j.Median),
+			P99:    p.duration("p99", j.P99),
+		}
+		if p.err == nil && (d.Median <= 0 || d.P99 < d.Median) {
+			p.err = InvalidParameterError{
+				lognormalName, "p99", "must be at least the median, which must be positive"}
+		}
+		return d, p.err
+	case uniformName:
+		d := Uniform{
+			Min: p.duration("min", j.Min),
+			Max: p.duration("max", j.Max),
+		}
+		if p.err == nil && d.Max < d.Min {
+			p.err = InvalidParameterError{uniformName, "max", "must be at least min"}
+		}
+		return d, p.err
+	case normalName:
+		d := Normal{
+			Mu:    p.duration("mean", j.Mean),
+			Sigma: p.duration("stddev", j.StdDev),
+		}
+		return d, p.err
+	case exponentialName:
+		d := Exponential{Mu: p.duration("mean", j.Mean)}
+		return d, p.err
+	case empiricalName:
+		return parseEmpirical(j)
+	default:
+		return nil, UnknownDistributionError{j.Distribution}
+	}
+}
+
+// ResolveFiles makes the relative files of the empirical distributions in doc,
+// a document unmarshalled from JSON or YAML, relative to dir instead of the
+// working directory, e.g. to the directory of the file doc was read from.
+func ResolveFiles(doc interface{}, dir string) {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if file, ok := v["file"].(string); ok &&
+			v["distribution"] == empiricalName && !filepath.IsAbs(file) {
+			v["file"] = filepath.Join(dir, file)
+		}
+		for _, child := range v {
+			ResolveFiles(child, dir)
+		}
+	case []interface{}:
+		for _, child := range v {
+			ResolveFiles(child, dir)
+		}
+	}
+}
+
+func parseEmpirical(j jsonDistribution) (Distribution, error) {
+	buckets := j.Buckets
+	if j.File != "" {
+		var err error
+		buckets, err = readHistogramFile(j.File)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(buckets) == 0 {
+		return nil, MissingParameterError{empiricalName, "buckets"}
+	}
+	p := parser{name: empiricalName}
+	d := Empirical{Buckets: make([]Bucket, 0, len(buckets))}
+	for _, b := range buckets {
+		if b.Weight < 0 {
+			return nil, InvalidParameterError{empiricalName, "weight", "must be non-negative"}
+		}
+		d.Buckets = append(d.Buckets, Bucket{
+			Value:  p.duration("value", b.Value),
+			Weight: b.Weight,
+		})
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if d.totalWeight() == 0 {
+		return nil, InvalidParameterError{empiricalName, "weight", "must not all be zero"}
+	}
+	sort.SliceStable(d.Buckets, func(i, j int) bool {
+		return d.Buckets[i].Value < d.Buckets[j].Value
+	})
+	return d, nil
+}
+
+// readHistogramFile reads buckets from a text file where each non-empty line
+// holds a duration and a weight separated by whitespace or a comma (e.g.
+// "10ms 42"). Lines starting with '#' are ignored.
+func readHistogramFile(path string) ([]jsonBucket, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var buckets []jsonBucket
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) != 2 {
+			return nil, InvalidHistogramLineError{path, line}
+		}
+		weight, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, InvalidHistogramLineError{path, line}
+		}
+		buckets = append(buckets, jsonBucket{Value: fields[0], Weight: weight})
+	}
+	return buckets, nil
+}
+
+// parser accumulates the first error while reading the parameters of a
+// distribution.
+type parser struct {
+	name string
+	err  error
+}
+
+func (p *parser) duration(param string, s string) time.Duration {
+	if p.err != nil {
+		return 0
+	}
+	if s == "" {
+		p.err = MissingParameterError{p.name, param}
+		return 0
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		p.err = InvalidParameterError{p.name, param, err.Error()}
+		return 0
+	}
+	if d < 0 {
+		p.err = InvalidParameterError{p.name, param, "must be non-negative"}
+		return 0
+	}
+	return d
+}
+
+// normalQuantile is the inverse of the standard normal CDF.
+func normalQuantile(q float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*clamp(q)-1)
+}
+
+func clamp(q float64) float64 {
+	return math.Max(0, math.Min(1, q))
+}
+
+// toDuration converts nanoseconds to a Duration, clamping to the valid
+// non-negative range.
+func toDuration(ns float64) time.Duration {
+	switch {
+	case math.IsNaN(ns) || ns <= 0:
+		return 0
+	case ns >= math.MaxInt64:
+		return time.Duration(math.MaxInt64)
+	default:
+		return time.Duration(ns)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dist
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFromJSON(t *testing.T) {
+	tests := []struct {
+		input        []byte
+		distribution Distribution
+		err          error
+	}{
+		{
+			[]byte(`{"distribution": "lognormal", "median": "10ms", "p99": "80ms"}`),
+			LogNormal{Median: 10 * time.Millisecond, P99: 80 * time.Millisecond},
+			nil,
+		},
+		{
+			[]byte(`{"distribution": "uniform", "min": "1ms", "max": "5ms"}`),
+			Uniform{Min: time.Millisecond, Max: 5 * time.Millisecond},
+			nil,
+		},
+		{
+			[]byte(`{"distribution": "normal", "mean": "10ms", "stddev": "2ms"}`),
+			Normal{Mu: 10 * time.Millisecond, Sigma: 2 * time.Millisecond},
+			nil,
+		},
+		{
+			[]byte(`{"distribution": "exponential", "mean": "3ms"}`),
+			Exponential{Mu: 3 * time.Millisecond},
+			nil,
+		},
+		{
+			[]byte(`{"distribution": "empirical", "buckets": [
+				{"value": "20ms", "weight": 1}, {"value": "10ms", "weight": 3}]}`),
+			Empirical{Buckets: []Bucket{
+				{Value: 10 * time.Millisecond, Weight: 3},
+				{Value: 20 * time.Millisecond, Weight: 1},
+			}},
+			nil,
+		},
+		{
+			[]byte(`{"distribution": "pareto"}`),
+			nil,
+			UnknownDistributionError{"pareto"},
+		},
+		{
+			[]byte(`{"distribution": "exponential"}`),
+			nil,
+			MissingParameterError{"exponential", "mean"},
+		},
+		{
+			[]byte(`{"distribution": "uniform", "min": "5ms", "max": "1ms"}`),
+			nil,
+			InvalidParameterError{"uniform", "max", "must be at least min"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			distribution, err := FromJSON(test.input)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if err == nil && !reflect.DeepEqual(test.distribution, distribution) {
+				t.Errorf("expected %v; actual %v", test.distribution, distribution)
+			}
+		})
+	}
+}
+
+func TestFromJSON_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "histogram.txt")
+	contents := "# latency weight\n5ms 1\n10ms, 3\n"
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	input, _ := json.Marshal(map[string]string{
+		"distribution": "empirical",
+		"file":         path,
+	})
+	distribution, err := FromJSON(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Empirical{Buckets: []Bucket{
+		{Value: 5 * time.Millisecond, Weight: 1},
+		{Value: 10 * time.Millisecond, Weight: 3},
+	}}
+	if !reflect.DeepEqual(expected, distribution) {
+		t.Errorf("expected %v; actual %v", expected, distribution)
+	}
+}
+
+func TestDistribution_MarshalJSON(t *testing.T) {
+	tests := []Distribution{
+		LogNormal{Median: 10 * time.Millisecond, P99: 80 * time.Millisecond},
+		Uniform{Min: time.Millisecond, Max: 5 * time.Millisecond},
+		Normal{Mu: 10 * time.Millisecond, Sigma: 2 * time.Millisecond},
+		Exponential{Mu: 3 * time.Millisecond},
+		Empirical{Buckets: []Bucket{{Value: 10 * time.Millisecond, Weight: 3}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.String(), func(t *testing.T) {
+			t.Parallel()
+
+			b, err := json.Marshal(test)
+			if err != nil {
+				t.Fatal(err)
+			}
+			distribution, err := FromJSON(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(test, distribution) {
+				t.Errorf("expected %v; actual %v", test, distribution)
+			}
+		})
+	}
+}
+
+func TestDistribution_Quantile(t *testing.T) {
+	tests := []struct {
+		distribution Distribution
+		q            float64
+		expected     time.Duration
+	}{
+		{LogNormal{Median: 10 * time.Millisecond, P99: 80 * time.Millisecond}, 0.5, 10 * time.Millisecond},
+		{LogNormal{Median: 10 * time.Millisecond, P99: 80 * time.Millisecond}, 0.99, 80 * time.Millisecond},
+		{Uniform{Min: 0, Max: 10 * time.Millisecond}, 0.25, 2500 * time.Microsecond},
+		{Normal{Mu: 10 * time.Millisecond, Sigma: 2 * time.Millisecond}, 0.5, 10 * time.Millisecond},
+		{Exponential{Mu: 10 * time.Millisecond}, 0, 0},
+		{Empirical{Buckets: []Bucket{{Value: 10 * time.Millisecond, Weight: 1}, {Value: 20 * time.Millisecond, Weight: 1}}}, 0.75, 15 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.distribution.String(), func(t *testing.T) {
+			t.Parallel()
+
+			actual := test.distribution.Quantile(test.q)
+			if diff := actual - test.expected; diff > time.Microsecond || diff < -time.Microsecond {
+				t.Errorf("expected %v; actual %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDistribution_Sample(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	d := LogNormal{Median: 10 * time.Millisecond, P99: 80 * time.Millisecond}
+
+	const n = 10000
+	below := 0
+	for i := 0; i < n; i++ {
+		if d.Sample(r) <= d.Median {
+			below++
+		}
+	}
+	if below < n*45/100 || below > n*55/100 {
+		t.Errorf("expected about half the samples below the median; actual %d of %d", below, n)
+	}
+}
+
+func TestResolveFiles(t *testing.T) {
+	t.Parallel()
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{"script": [
+		{"sleep": {"distribution": "empirical", "file": "histogram.txt"}},
+		{"sleep": {"distribution": "empirical", "file": "/histogram.txt"}},
+		{"call": {"service": "b", "file": "b.txt"}}
+	]}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	ResolveFiles(doc, filepath.Join("topologies", "a"))
+
+	script := doc.(map[string]interface{})["script"].([]interface{})
+	files := []interface{}{
+		script[0].(map[string]interface{})["sleep"].(map[string]interface{})["file"],
+		script[1].(map[string]interface{})["sleep"].(map[string]interface{})["file"],
+		script[2].(map[string]interface{})["call"].(map[string]interface{})["file"],
+	}
+	expected := []interface{}{filepath.Join("topologies", "a", "histogram.txt"), "/histogram.txt", "b.txt"}
+	if !reflect.DeepEqual(expected, files) {
+		t.Errorf("expected %v; actual %v", expected, files)
+	}
+}
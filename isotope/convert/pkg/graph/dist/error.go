@@ -0,0 +1,66 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dist
+
+import "fmt"
+
+// UnknownDistributionError is returned when the name of a distribution is not
+// recognized.
+type UnknownDistributionError struct {
+	Name string
+}
+
+func (e UnknownDistributionError) Error() string {
+	return fmt.Sprintf("unknown distribution: %q", e.Name)
+}
+
+// MissingParameterError is returned when a required parameter of a
+// distribution is not set.
+type MissingParameterError struct {
+	Distribution string
+	Parameter    string
+}
+
+func (e MissingParameterError) Error() string {
+	return fmt.Sprintf(
+		"%s distribution requires parameter %q", e.Distribution, e.Parameter)
+}
+
+// InvalidParameterError is returned when a parameter of a distribution has an
+// invalid value.
+type InvalidParameterError struct {
+	Distribution string
+	Parameter    string
+	Reason       string
+}
+
+func (e InvalidParameterError) Error() string {
+	return fmt.Sprintf(
+		"invalid parameter %q for %s distribution: %s",
+		e.Parameter, e.Distribution, e.Reason)
+}
+
+// InvalidHistogramLineError is returned when a line of a histogram file is
+// not a duration followed by a weight.
+type InvalidHistogramLineError struct {
+	Path string
+	Line string
+}
+
+func (e InvalidHistogramLineError) Error() string {
+	return fmt.Sprintf(
+		"invalid line in histogram file %s: %q (expected \"<duration> <weight>\")",
+		e.Path, e.Line)
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package duration provides a time.Duration which is encoded in JSON as a
+// string like "10ms" or "2m".
+package duration
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Duration is a time.Duration which can be unmarshalled from a JSON string
+// parsable by time.ParseDuration.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON encodes the Duration as a JSON string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON converts a JSON string to a Duration. Negative durations are
+// rejected.
+func (d *Duration) UnmarshalJSON(b []byte) (err error) {
+	var s string
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return
+	}
+	*d, err = FromString(s)
+	return
+}
+
+// FromString converts a string like "10ms" to a Duration if it is
+// non-negative.
+func FromString(s string) (Duration, error) {
+	x, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if x < 0 {
+		return 0, NegativeDurationError{x}
+	}
+	return Duration(x), nil
+}
+
+// NegativeDurationError is returned when parsing a negative duration.
+type NegativeDurationError struct {
+	Duration time.Duration
+}
+
+func (e NegativeDurationError) Error() string {
+	return e.Duration.String() + " must be non-negative"
+}
@@ -20,4 +20,7 @@ import "istio.io/tools/isotope/convert/pkg/graph/svc"
 // architecture.
 type ServiceGraph struct {
 	Services []svc.Service `json:"services"`
+
+	// Seed is the seed of the services which do not set their own.
+	Seed *uint64 `json:"seed,omitempty"`
 }
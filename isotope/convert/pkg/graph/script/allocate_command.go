@@ -0,0 +1,29 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"istio.io/tools/isotope/convert/pkg/graph/size"
+)
+
+// AllocateCommand describes a command to allocate and touch memory, which is
+// held until the service responds.
+type AllocateCommand struct {
+	Bytes size.ByteSize `json:"bytes"`
+}
+
+func (c AllocateCommand) String() string {
+	return c.Bytes.String()
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"encoding/json"
+)
+
+// ChooseCommand describes a command to execute exactly one of several
+// scripts, each chosen with a probability proportional to its weight.
+type ChooseCommand []Branch
+
+// Branch is a script which may be chosen by a ChooseCommand.
+type Branch struct {
+	Weight float64 `json:"weight"`
+	Script Script  `json:"script,omitempty"`
+}
+
+// UnmarshalJSON converts b to a ChooseCommand. b must be a JSON array of
+// branches whose weights are non-negative and not all zero.
+func (c *ChooseCommand) UnmarshalJSON(b []byte) (err error) {
+	var branches []Branch
+	err = json.Unmarshal(b, &branches)
+	if err != nil {
+		return
+	}
+	*c = ChooseCommand(branches)
+	for _, branch := range branches {
+		if branch.Weight < 0 {
+			return InvalidCommandError{chooseCommandKey, "weights must be non-negative"}
+		}
+	}
+	if c.TotalWeight() <= 0 {
+		return InvalidCommandError{chooseCommandKey, "weights must not all be zero"}
+	}
+	return
+}
+
+// TotalWeight is the sum of the weights of the branches.
+func (c ChooseCommand) TotalWeight() (total float64) {
+	for _, branch := range c {
+		total += branch.Weight
+	}
+	return
+}
+
+// Probability is the chance the branch at index i is chosen.
+func (c ChooseCommand) Probability(i int) float64 {
+	return c[i].Weight / c.TotalWeight()
+}
+
+// Choose returns the script of the branch chosen by x, a random number in
+// [0, 1).
+func (c ChooseCommand) Choose(x float64) Script {
+	i := c.ChooseIndex(x)
+	if i < 0 {
+		return nil
+	}
+	return c[i].Script
+}
+
+// ChooseIndex returns the index of the branch chosen by x, a random number in
+// [0, 1), or -1 if no branch has a weight.
+func (c ChooseCommand) ChooseIndex(x float64) int {
+	target := x * c.TotalWeight()
+	var cumulative float64
+	for i, branch := range c {
+		cumulative += branch.Weight
+		if branch.Weight > 0 && target < cumulative {
+			return i
+		}
+	}
+	// Only reached through rounding errors when x is close to 1.
+	for i := len(c) - 1; i >= 0; i-- {
+		if c[i].Weight > 0 {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChooseCommand_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   []byte
+		command ChooseCommand
+		err     error
+	}{
+		{
+			[]byte(`[{"weight": 70, "script": [{"sleep": "1ms"}]}, {"weight": 30}]`),
+			ChooseCommand{
+				{Weight: 70, Script: Script{SleepCommand(time.Millisecond)}},
+				{Weight: 30},
+			},
+			nil,
+		},
+		{
+			[]byte(`[{"weight": 0}]`),
+			ChooseCommand{{Weight: 0}},
+			InvalidCommandError{"choose", "weights must not all be zero"},
+		},
+		{
+			[]byte(`[{"weight": -1}, {"weight": 2}]`),
+			ChooseCommand{{Weight: -1}, {Weight: 2}},
+			InvalidCommandError{"choose", "weights must be non-negative"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			var command ChooseCommand
+			err := json.Unmarshal(test.input, &command)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if !reflect.DeepEqual(test.command, command) {
+				t.Errorf("expected %v; actual %v", test.command, command)
+			}
+		})
+	}
+}
+
+func TestChooseCommand_Choose(t *testing.T) {
+	a := Script{SleepCommand(1)}
+	b := Script{SleepCommand(2)}
+	command := ChooseCommand{{Weight: 3, Script: a}, {Weight: 0}, {Weight: 1, Script: b}}
+
+	tests := []struct {
+		x      float64
+		script Script
+	}{
+		{0, a},
+		{0.74, a},
+		{0.75, b},
+		{0.9999999999, b},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			if actual := command.Choose(test.x); !reflect.DeepEqual(test.script, actual) {
+				t.Errorf("expected %v; actual %v", test.script, actual)
+			}
+		})
+	}
+}
@@ -23,8 +23,14 @@ import (
 type Command interface{}
 
 const (
-	sleepCommandKey   = "sleep"
-	requestCommandKey = "call"
+	sleepCommandKey    = "sleep"
+	requestCommandKey  = "call"
+	sequenceCommandKey = "sequence"
+	repeatCommandKey   = "repeat"
+	chooseCommandKey   = "choose"
+	fanoutCommandKey   = "fanout"
+	computeCommandKey  = "compute"
+	allocateCommandKey = "allocate"
 )
 
 func commandsToMarshallable(cmds []Command) ([]interface{}, error) {
@@ -43,10 +49,28 @@ func commandToMarshallable(cmd Command) (interface{}, error) {
 	switch cmd := cmd.(type) {
 	case SleepCommand:
 		return map[string]string{sleepCommandKey: cmd.String()}, nil
+	case SleepDistributionCommand:
+		return map[string]SleepDistributionCommand{sleepCommandKey: cmd}, nil
 	case RequestCommand:
 		return map[string]RequestCommand{requestCommandKey: cmd}, nil
 	case ConcurrentCommand:
 		return commandsToMarshallable(cmd)
+	case SequenceCommand:
+		marshallableCmds, err := commandsToMarshallable(cmd)
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]interface{}{sequenceCommandKey: marshallableCmds}, nil
+	case RepeatCommand:
+		return map[string]RepeatCommand{repeatCommandKey: cmd}, nil
+	case ChooseCommand:
+		return map[string]ChooseCommand{chooseCommandKey: cmd}, nil
+	case FanoutCommand:
+		return map[string]FanoutCommand{fanoutCommandKey: cmd}, nil
+	case ComputeCommand:
+		return map[string]ComputeCommand{computeCommandKey: cmd}, nil
+	case AllocateCommand:
+		return map[string]AllocateCommand{allocateCommandKey: cmd}, nil
 	default:
 		return nil, InvalidCommandTypeError{cmd}
 	}
@@ -97,6 +121,36 @@ func (c *unmarshallableCommand) UnmarshalJSON(b []byte) error {
 			if err != nil {
 				return err
 			}
+		case sequenceCommandKey:
+			c.Command, err = parseSequenceCommandFromJSONMap(b)
+			if err != nil {
+				return err
+			}
+		case repeatCommandKey:
+			c.Command, err = parseRepeatCommandFromJSONMap(b)
+			if err != nil {
+				return err
+			}
+		case chooseCommandKey:
+			c.Command, err = parseChooseCommandFromJSONMap(b)
+			if err != nil {
+				return err
+			}
+		case fanoutCommandKey:
+			c.Command, err = parseFanoutCommandFromJSONMap(b)
+			if err != nil {
+				return err
+			}
+		case computeCommandKey:
+			c.Command, err = parseComputeCommandFromJSONMap(b)
+			if err != nil {
+				return err
+			}
+		case allocateCommandKey:
+			c.Command, err = parseAllocateCommandFromJSONMap(b)
+			if err != nil {
+				return err
+			}
 		default:
 			return UnknownCommandKeyError{key}
 		}
@@ -120,9 +174,36 @@ func parseJSONCommandKey(b []byte) (s string, err error) {
 	return
 }
 
-// b must contain a single key whose value is an unmarshallable SleepCommand.
-func parseSleepCommandFromJSONMap(b []byte) (cmd SleepCommand, err error) {
-	var m map[string]SleepCommand
+// b must contain a single key whose value is either an unmarshallable
+// SleepCommand (a JSON string) or an unmarshallable SleepDistributionCommand
+// (a JSON object).
+func parseSleepCommandFromJSONMap(b []byte) (Command, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	var value json.RawMessage
+	for _, value = range m {
+	}
+
+	isJSONString := len(value) > 0 && value[0] == '"'
+	if isJSONString {
+		var cmd SleepCommand
+		if err := json.Unmarshal(value, &cmd); err != nil {
+			return nil, err
+		}
+		return cmd, nil
+	}
+	var cmd SleepDistributionCommand
+	if err := json.Unmarshal(value, &cmd); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// b must contain a single key whose value is an unmarshallable RequestCommand.
+func parseRequestCommandFromJSONMap(b []byte) (cmd RequestCommand, err error) {
+	var m map[string]RequestCommand
 	err = json.Unmarshal(b, &m)
 	if err != nil {
 		return
@@ -132,9 +213,71 @@ func parseSleepCommandFromJSONMap(b []byte) (cmd SleepCommand, err error) {
 	return
 }
 
-// b must contain a single key whose value is an unmarshallable RequestCommand.
-func parseRequestCommandFromJSONMap(b []byte) (cmd RequestCommand, err error) {
-	var m map[string]RequestCommand
+// b must contain a single key whose value is an unmarshallable
+// SequenceCommand.
+func parseSequenceCommandFromJSONMap(b []byte) (cmd SequenceCommand, err error) {
+	var m map[string]SequenceCommand
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return
+	}
+	for _, cmd = range m {
+	}
+	return
+}
+
+// b must contain a single key whose value is an unmarshallable RepeatCommand.
+func parseRepeatCommandFromJSONMap(b []byte) (cmd RepeatCommand, err error) {
+	var m map[string]RepeatCommand
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return
+	}
+	for _, cmd = range m {
+	}
+	return
+}
+
+// b must contain a single key whose value is an unmarshallable ChooseCommand.
+func parseChooseCommandFromJSONMap(b []byte) (cmd ChooseCommand, err error) {
+	var m map[string]ChooseCommand
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return
+	}
+	for _, cmd = range m {
+	}
+	return
+}
+
+// b must contain a single key whose value is an unmarshallable FanoutCommand.
+func parseFanoutCommandFromJSONMap(b []byte) (cmd FanoutCommand, err error) {
+	var m map[string]FanoutCommand
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return
+	}
+	for _, cmd = range m {
+	}
+	return
+}
+
+// b must contain a single key whose value is an unmarshallable ComputeCommand.
+func parseComputeCommandFromJSONMap(b []byte) (cmd ComputeCommand, err error) {
+	var m map[string]ComputeCommand
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return
+	}
+	for _, cmd = range m {
+	}
+	return
+}
+
+// b must contain a single key whose value is an unmarshallable
+// AllocateCommand.
+func parseAllocateCommandFromJSONMap(b []byte) (cmd AllocateCommand, err error) {
+	var m map[string]AllocateCommand
 	err = json.Unmarshal(b, &m)
 	if err != nil {
 		return
@@ -173,3 +316,14 @@ type UnknownCommandKeyError struct {
 func (e UnknownCommandKeyError) Error() string {
 	return fmt.Sprintf("unknown command: %s", e.CommandKey)
 }
+
+// InvalidCommandError is returned when the parameters of a command are
+// invalid.
+type InvalidCommandError struct {
+	CommandKey string
+	Reason     string
+}
+
+func (e InvalidCommandError) Error() string {
+	return fmt.Sprintf("invalid %s command: %s", e.CommandKey, e.Reason)
+}
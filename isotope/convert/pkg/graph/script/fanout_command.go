@@ -0,0 +1,86 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"encoding/json"
+)
+
+// FanoutCommand describes a command to send the same request to another
+// service Count times, with at most Concurrency requests in flight. It
+// emulates calls made for each item of a request, like each product in a
+// cart.
+type FanoutCommand struct {
+	Call  RequestCommand
+	Count int
+	// Concurrency limits the requests in flight. If unset, all are sent at
+	// once.
+	Concurrency int
+}
+
+type jsonFanoutCommand struct {
+	Count       int `json:"count"`
+	Concurrency int `json:"concurrency,omitempty"`
+}
+
+// UnmarshalJSON converts a JSON object with the properties of a
+// RequestCommand, a count and optionally a concurrency to a FanoutCommand.
+func (c *FanoutCommand) UnmarshalJSON(b []byte) (err error) {
+	var call RequestCommand
+	err = json.Unmarshal(b, &call)
+	if err != nil {
+		return
+	}
+	var j jsonFanoutCommand
+	err = json.Unmarshal(b, &j)
+	if err != nil {
+		return
+	}
+	*c = FanoutCommand{Call: call, Count: j.Count, Concurrency: j.Concurrency}
+	if c.Count < 0 {
+		return InvalidCommandError{fanoutCommandKey, "count must be non-negative"}
+	}
+	if c.Concurrency < 0 {
+		return InvalidCommandError{fanoutCommandKey, "concurrency must be non-negative"}
+	}
+	return
+}
+
+// MarshalJSON encodes the FanoutCommand as a JSON object with the properties
+// of its request, count and concurrency.
+func (c FanoutCommand) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{}
+	b, err := json.Marshal(c.Call)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	m["count"] = c.Count
+	if c.Concurrency > 0 {
+		m["concurrency"] = c.Concurrency
+	}
+	return json.Marshal(m)
+}
+
+// Batches is the number of rounds of concurrent requests needed to send all
+// of them.
+func (c FanoutCommand) Batches() int {
+	if c.Concurrency <= 0 || c.Concurrency >= c.Count {
+		return min(c.Count, 1)
+	}
+	return (c.Count + c.Concurrency - 1) / c.Concurrency
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFanoutCommand_UnmarshalJSON(t *testing.T) {
+	DefaultRequestCommand = RequestCommand{}
+
+	tests := []struct {
+		input   []byte
+		command FanoutCommand
+		err     error
+	}{
+		{
+			[]byte(`{"service": "inventory", "size": 10, "count": 5, "concurrency": 2}`),
+			FanoutCommand{
+				Call: RequestCommand{
+					ServiceName: "inventory",
+					Hostname:    "inventory:8080",
+					Size:        10,
+				},
+				Count:       5,
+				Concurrency: 2,
+			},
+			nil,
+		},
+		{
+			[]byte(`{"service": "inventory", "count": -1}`),
+			FanoutCommand{
+				Call:  RequestCommand{ServiceName: "inventory", Hostname: "inventory:8080"},
+				Count: -1,
+			},
+			InvalidCommandError{"fanout", "count must be non-negative"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			var command FanoutCommand
+			err := json.Unmarshal(test.input, &command)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if !reflect.DeepEqual(test.command, command) {
+				t.Errorf("expected %v; actual %v", test.command, command)
+			}
+		})
+	}
+}
+
+func TestFanoutCommand_MarshalJSON(t *testing.T) {
+	command := FanoutCommand{
+		Call:        RequestCommand{ServiceName: "b", Hostname: "b:8080", Size: 1},
+		Count:       3,
+		Concurrency: 2,
+	}
+	b, err := json.Marshal(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual FanoutCommand
+	if err := json.Unmarshal(b, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(command, actual) {
+		t.Errorf("expected %v; actual %v", command, actual)
+	}
+}
+
+func TestFanoutCommand_Batches(t *testing.T) {
+	tests := []struct {
+		count, concurrency, batches int
+	}{
+		{0, 0, 0},
+		{5, 0, 1},
+		{5, 5, 1},
+		{5, 2, 3},
+		{4, 2, 2},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			command := FanoutCommand{Count: test.count, Concurrency: test.concurrency}
+			if actual := command.Batches(); actual != test.batches {
+				t.Errorf("expected %d; actual %d", test.batches, actual)
+			}
+		})
+	}
+}
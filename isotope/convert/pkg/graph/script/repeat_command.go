@@ -0,0 +1,97 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"istio.io/tools/isotope/convert/pkg/graph/dist"
+)
+
+// RepeatCommand describes a command to execute a script several times in a
+// row, a fixed Count of times or a number drawn from Distribution each time
+// it is executed.
+type RepeatCommand struct {
+	Count        int
+	Distribution dist.CountDistribution
+	Body         Script
+}
+
+type jsonRepeatCommand struct {
+	Count        *int            `json:"count,omitempty"`
+	Distribution json.RawMessage `json:"distribution,omitempty"`
+	Body         Script          `json:"body"`
+}
+
+// UnmarshalJSON converts a JSON object with a body and either a count or a
+// distribution (e.g. {"distribution": "poisson", "mean": 3}) to a
+// RepeatCommand.
+func (c *RepeatCommand) UnmarshalJSON(b []byte) (err error) {
+	var j jsonRepeatCommand
+	err = json.Unmarshal(b, &j)
+	if err != nil {
+		return
+	}
+	*c = RepeatCommand{Body: j.Body}
+	switch {
+	case j.Count != nil && j.Distribution != nil:
+		return InvalidCommandError{repeatCommandKey, "only one of count and distribution may be set"}
+	case j.Count != nil:
+		if *j.Count < 0 {
+			return InvalidCommandError{repeatCommandKey, "count must be non-negative"}
+		}
+		c.Count = *j.Count
+	case j.Distribution != nil:
+		c.Distribution, err = dist.CountFromJSON(j.Distribution)
+		if err != nil {
+			return
+		}
+	default:
+		return InvalidCommandError{repeatCommandKey, "one of count and distribution must be set"}
+	}
+	return
+}
+
+// MarshalJSON encodes the RepeatCommand as a JSON object.
+func (c RepeatCommand) MarshalJSON() ([]byte, error) {
+	j := jsonRepeatCommand{Body: c.Body}
+	if c.Distribution == nil {
+		j.Count = &c.Count
+	} else {
+		d, err := json.Marshal(c.Distribution)
+		if err != nil {
+			return nil, err
+		}
+		j.Distribution = d
+	}
+	return json.Marshal(j)
+}
+
+// Times returns the number of times to execute the body, drawn from r if the
+// count is random.
+func (c RepeatCommand) Times(r dist.Rand) int {
+	if c.Distribution == nil {
+		return c.Count
+	}
+	return c.Distribution.Sample(r)
+}
+
+func (c RepeatCommand) String() string {
+	if c.Distribution == nil {
+		return fmt.Sprint(c.Count)
+	}
+	return c.Distribution.String()
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/dist"
+)
+
+func TestRepeatCommand_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   []byte
+		command RepeatCommand
+		err     error
+	}{
+		{
+			[]byte(`{"count": 3, "body": [{"sleep": "1ms"}]}`),
+			RepeatCommand{
+				Count: 3,
+				Body:  Script{SleepCommand(time.Millisecond)},
+			},
+			nil,
+		},
+		{
+			[]byte(`{"distribution": {"distribution": "poisson", "mean": 2}, "body": []}`),
+			RepeatCommand{
+				Distribution: dist.PoissonCount{Mu: 2},
+				Body:         Script{},
+			},
+			nil,
+		},
+		{
+			[]byte(`{"body": []}`),
+			RepeatCommand{Body: Script{}},
+			InvalidCommandError{"repeat", "one of count and distribution must be set"},
+		},
+		{
+			[]byte(`{"count": -1, "body": []}`),
+			RepeatCommand{Body: Script{}},
+			InvalidCommandError{"repeat", "count must be non-negative"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			var command RepeatCommand
+			err := json.Unmarshal(test.input, &command)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if !reflect.DeepEqual(test.command, command) {
+				t.Errorf("expected %v; actual %v", test.command, command)
+			}
+		})
+	}
+}
+
+func TestRepeatCommand_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		input  RepeatCommand
+		output []byte
+	}{
+		{
+			RepeatCommand{Count: 0, Body: Script{SleepCommand(time.Millisecond)}},
+			[]byte(`{"count":0,"body":[{"sleep":"1ms"}]}`),
+		},
+		{
+			RepeatCommand{Distribution: dist.UniformCount{Min: 1, Max: 2}, Body: Script{}},
+			[]byte(`{"distribution":{"distribution":"uniform","min":1,"max":2},"body":[]}`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			output, err := json.Marshal(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(test.output) != string(output) {
+				t.Errorf("expected %s; actual %s", test.output, output)
+			}
+		})
+	}
+}
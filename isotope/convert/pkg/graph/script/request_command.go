@@ -18,6 +18,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
 	"istio.io/tools/isotope/convert/pkg/graph/size"
 )
 
@@ -27,11 +32,30 @@ type RequestCommand struct {
 	ServiceName string            `json:"service"`
 	Hostname    string            `json:"hostname"`
 	ExtraHeader map[string]string `json:"extra-header"`
+	// Method is the HTTP method of the request. Defaults to GET.
+	Method string `json:"method,omitempty"`
+	// Path is the path of the request, which may contain placeholders (see
+	// Template). Defaults to "/".
+	Path Template `json:"path,omitempty"`
+	// Query holds the query parameters of the request. Values may contain
+	// placeholders (see Template).
+	Query map[string]Template `json:"query,omitempty"`
+	// ContentType is the Content-Type header of the request body.
+	ContentType string `json:"contentType,omitempty"`
 	// Size is the number of bytes in the request body.
 	Size size.ByteSize `json:"size"`
 	// Probability is the chance a call will be made, from 1-100%. If unset, the call will always be made
 	// 1 means 1% of calls will be made; 100 means 100% of calls will be made
 	Probability int `json:"probability,omitempty"`
+	// Timeout is the maximum duration of each attempt of the call. If unset,
+	// attempts never time out.
+	Timeout duration.Duration `json:"timeout,omitempty"`
+	// Retries describes how failed attempts are retried. If unset, failed
+	// calls are not retried.
+	Retries *RetryPolicy `json:"retries,omitempty"`
+	// OnError describes what to do when the call fails after all retries. If
+	// unset, the script fails.
+	OnError ErrorPolicy `json:"onError,omitempty"`
 }
 
 // DefaultRequestCommand is used by UnmarshalJSON to set defaults.
@@ -50,7 +74,7 @@ func (c *RequestCommand) UnmarshalJSON(b []byte) (err error) {
 			return
 		}
 		c.ServiceName = s
-		c.Hostname = fmt.Sprintf("%s:8080", s)
+		c.Hostname = defaultHostname(s)
 	} else {
 		// Wrap the RequestCommand to dodge the custom UnmarshalJSON.
 		unmarshallableRequestCommand := unmarshallableRequestCommand(*c)
@@ -61,13 +85,161 @@ func (c *RequestCommand) UnmarshalJSON(b []byte) (err error) {
 
 		*c = RequestCommand(unmarshallableRequestCommand)
 		if c.Hostname == "" {
-			c.Hostname = fmt.Sprintf("%s:8080", c.ServiceName)
+			c.Hostname = defaultHostname(c.ServiceName)
 		}
 		if c.Probability < 0 || c.Probability > 100 {
 			return errors.New("math: invalid probability, outside range: [0,100]")
 		}
+		if err = c.validateHTTPRequest(); err != nil {
+			return
+		}
 	}
 	return
 }
 
 type unmarshallableRequestCommand RequestCommand
+
+func defaultHostname(serviceName string) string {
+	return fmt.Sprintf("%s:8080", serviceName)
+}
+
+func (c RequestCommand) validateHTTPRequest() error {
+	if c.Method != "" && c.Method != strings.ToUpper(c.Method) {
+		return fmt.Errorf("HTTP method %q must be upper case", c.Method)
+	}
+	if c.Path != "" && !strings.HasPrefix(string(c.Path), "/") {
+		return fmt.Errorf(`path %q must start with "/"`, c.Path)
+	}
+	if err := c.Path.Validate(); err != nil {
+		return err
+	}
+	for _, value := range c.Query {
+		if err := value.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HTTPMethod returns the HTTP method of the request, defaulting to GET.
+func (c RequestCommand) HTTPMethod() string {
+	if c.Method == "" {
+		return http.MethodGet
+	}
+	return c.Method
+}
+
+// RenderURL renders the path and query of the request into a URL for host.
+// intn must return a random integer in [0, n).
+func (c RequestCommand) RenderURL(host string, intn func(n int) int) string {
+	u := url.URL{Scheme: "http", Host: host, Path: c.Path.Render(intn)}
+	if len(c.Query) > 0 {
+		query := make(url.Values, len(c.Query))
+		for key, value := range c.Query {
+			query.Set(key, value.Render(intn))
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// FallbackCommand returns the command to send to the fallback service of c's
+// OnError policy. It carries the same payload and timeouts, but is always made
+// and fails the script if it fails too.
+func (c RequestCommand) FallbackCommand() (RequestCommand, bool) {
+	fallback, ok := c.OnError.Fallback()
+	if !ok {
+		return RequestCommand{}, false
+	}
+	cmd := c
+	cmd.ServiceName = fallback
+	cmd.Hostname = defaultHostname(fallback)
+	cmd.Probability = 0
+	cmd.OnError = ""
+	return cmd, true
+}
+
+// RetryPolicy describes how a failed call is retried.
+type RetryPolicy struct {
+	// Attempts is the maximum number of retries after the first attempt.
+	Attempts int `json:"attempts"`
+	// Backoff is the delay before the first retry. It doubles after each
+	// retry. If unset, retries are immediate.
+	Backoff duration.Duration `json:"backoff,omitempty"`
+}
+
+// UnmarshalJSON converts b to a RetryPolicy. b may be a JSON number, which is
+// the number of attempts, or a JSON object.
+func (p *RetryPolicy) UnmarshalJSON(b []byte) (err error) {
+	isJSONNumber := b[0] != '{'
+	if isJSONNumber {
+		*p = RetryPolicy{}
+		err = json.Unmarshal(b, &p.Attempts)
+	} else {
+		var unmarshallable unmarshallableRetryPolicy
+		err = json.Unmarshal(b, &unmarshallable)
+		*p = RetryPolicy(unmarshallable)
+	}
+	if err != nil {
+		return
+	}
+	if p.Attempts < 0 {
+		err = errors.New("retry attempts must be non-negative")
+	}
+	return
+}
+
+type unmarshallableRetryPolicy RetryPolicy
+
+// ErrorPolicy describes what a script does when a call fails. It is one of
+// "fail" (the default), "continue" or "fallback:<service>".
+type ErrorPolicy string
+
+const (
+	// ErrorPolicyFail fails the script, so the calling service responds with
+	// an error.
+	ErrorPolicyFail ErrorPolicy = "fail"
+	// ErrorPolicyContinue ignores the failure and moves on to the next step.
+	ErrorPolicyContinue ErrorPolicy = "continue"
+
+	errorPolicyFallbackPrefix = "fallback:"
+)
+
+// UnmarshalJSON converts and validates a JSON string to an ErrorPolicy.
+func (p *ErrorPolicy) UnmarshalJSON(b []byte) (err error) {
+	var s string
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return
+	}
+	policy := ErrorPolicy(s)
+	if _, isFallback := policy.Fallback(); !isFallback &&
+		policy != ErrorPolicyFail && policy != ErrorPolicyContinue {
+		return InvalidErrorPolicyError{s}
+	}
+	*p = policy
+	return
+}
+
+// Fallback returns the name of the service to call instead when the policy is
+// "fallback:<service>".
+func (p ErrorPolicy) Fallback() (string, bool) {
+	s := string(p)
+	if !strings.HasPrefix(s, errorPolicyFallbackPrefix) {
+		return "", false
+	}
+	service := strings.TrimPrefix(s, errorPolicyFallbackPrefix)
+	return service, service != ""
+}
+
+// InvalidErrorPolicyError is returned when an onError policy is not
+// recognized.
+type InvalidErrorPolicyError struct {
+	Policy string
+}
+
+func (e InvalidErrorPolicyError) Error() string {
+	return fmt.Sprintf(
+		`invalid onError policy %q (must be "fail", "continue" or "fallback:<service>")`,
+		e.Policy)
+}
@@ -18,6 +18,9 @@ import (
 	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
 )
 
 func TestRequestCommand_UnmarshalJSON(t *testing.T) {
@@ -103,3 +106,98 @@ func TestRequestCommand_UnmarshalJSON_Default(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestCommand_UnmarshalJSON_Resilience(t *testing.T) {
+	DefaultRequestCommand = RequestCommand{}
+
+	tests := []struct {
+		input   []byte
+		command RequestCommand
+		err     error
+	}{
+		{
+			[]byte(`{"service": "a", "timeout": "100ms", "retries": 2, "onError": "continue"}`),
+			RequestCommand{
+				ServiceName: "a",
+				Hostname:    "a:8080",
+				Timeout:     duration.Duration(100 * time.Millisecond),
+				Retries:     &RetryPolicy{Attempts: 2},
+				OnError:     ErrorPolicyContinue,
+			},
+			nil,
+		},
+		{
+			[]byte(`{"service": "a", "retries": {"attempts": 3, "backoff": "10ms"}, "onError": "fallback:b"}`),
+			RequestCommand{
+				ServiceName: "a",
+				Hostname:    "a:8080",
+				Retries: &RetryPolicy{
+					Attempts: 3,
+					Backoff:  duration.Duration(10 * time.Millisecond),
+				},
+				OnError: ErrorPolicy("fallback:b"),
+			},
+			nil,
+		},
+		{
+			[]byte(`{"service": "a", "method": "POST", "path": "/items/{rand:10}", "query": {"q": "x"}, "contentType": "application/json"}`),
+			RequestCommand{
+				ServiceName: "a",
+				Hostname:    "a:8080",
+				Method:      "POST",
+				Path:        "/items/{rand:10}",
+				Query:       map[string]Template{"q": "x"},
+				ContentType: "application/json",
+			},
+			nil,
+		},
+		{
+			[]byte(`{"service": "a", "path": "/items/{id}"}`),
+			RequestCommand{},
+			InvalidTemplateError{"/items/{id}"},
+		},
+		{
+			[]byte(`{"service": "a", "onError": "ignore"}`),
+			RequestCommand{},
+			InvalidErrorPolicyError{"ignore"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			var command RequestCommand
+			err := json.Unmarshal(test.input, &command)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if err == nil && !reflect.DeepEqual(test.command, command) {
+				t.Errorf("expected %v; actual %v", test.command, command)
+			}
+		})
+	}
+}
+
+func TestRequestCommand_FallbackCommand(t *testing.T) {
+	cmd := RequestCommand{
+		ServiceName: "a",
+		Hostname:    "a.ns:8080",
+		Size:        128,
+		Probability: 50,
+		OnError:     ErrorPolicy("fallback:b"),
+	}
+	expected := RequestCommand{ServiceName: "b", Hostname: "b:8080", Size: 128}
+
+	fallback, ok := cmd.FallbackCommand()
+	if !ok {
+		t.Fatalf("expected a fallback command")
+	}
+	if !reflect.DeepEqual(expected, fallback) {
+		t.Errorf("expected %v; actual %v", expected, fallback)
+	}
+	if _, ok := fallback.FallbackCommand(); ok {
+		t.Errorf("expected fallback command to have no fallback")
+	}
+}
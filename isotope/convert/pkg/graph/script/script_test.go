@@ -19,6 +19,9 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/dist"
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
 )
 
 func TestScript_UnmarshalJSON(t *testing.T) {
@@ -60,6 +63,54 @@ func TestScript_UnmarshalJSON(t *testing.T) {
 			},
 			nil,
 		},
+		{
+			[]byte(`[[{"call": "A"}, {"sequence": [{"call": "B"}, [{"call": "C"}, {"call": "D"}]]}]]`),
+			Script{
+				ConcurrentCommand{
+					RequestCommand{ServiceName: "A", Hostname: "A:8080"},
+					SequenceCommand{
+						RequestCommand{ServiceName: "B", Hostname: "B:8080"},
+						ConcurrentCommand{
+							RequestCommand{ServiceName: "C", Hostname: "C:8080"},
+							RequestCommand{ServiceName: "D", Hostname: "D:8080"},
+						},
+					},
+				},
+			},
+			nil,
+		},
+		{
+			[]byte(`[{"repeat": {"count": 2, "body": [{"call": "A"}]}}, {"choose": [{"weight": 1, "script": [{"call": "B"}]}]}, {"fanout": {"service": "C", "count": 3}}]`),
+			Script{
+				RepeatCommand{
+					Count: 2,
+					Body:  Script{RequestCommand{ServiceName: "A", Hostname: "A:8080"}},
+				},
+				ChooseCommand{
+					{Weight: 1, Script: Script{RequestCommand{ServiceName: "B", Hostname: "B:8080"}}},
+				},
+				FanoutCommand{
+					Call:  RequestCommand{ServiceName: "C", Hostname: "C:8080"},
+					Count: 3,
+				},
+			},
+			nil,
+		},
+		{
+			[]byte(`[{"compute": {"cpu": "5ms"}}, {"allocate": {"bytes": "1MiB"}}]`),
+			Script{
+				ComputeCommand{CPU: duration.Duration(5 * time.Millisecond)},
+				AllocateCommand{Bytes: 1 << 20},
+			},
+			nil,
+		},
+		{
+			[]byte(`[{"sleep": {"distribution": "exponential", "mean": "5ms"}}]`),
+			Script{
+				SleepDistributionCommand{dist.Exponential{Mu: 5 * time.Millisecond}},
+			},
+			nil,
+		},
 	}
 
 	for _, test := range tests {
@@ -78,3 +129,54 @@ func TestScript_UnmarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestScript_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		input  Script
+		output []byte
+	}{
+		{
+			Script{
+				SleepCommand(10 * time.Millisecond),
+				SleepDistributionCommand{dist.LogNormal{
+					Median: 10 * time.Millisecond, P99: 80 * time.Millisecond,
+				}},
+			},
+			[]byte(`[{"sleep":"10ms"},{"sleep":{"distribution":"lognormal","median":"10ms","p99":"80ms"}}]`),
+		},
+		{
+			Script{
+				ConcurrentCommand{
+					SleepCommand(10 * time.Millisecond),
+					SequenceCommand{
+						SleepCommand(20 * time.Millisecond),
+						ConcurrentCommand{SleepCommand(30 * time.Millisecond)},
+					},
+				},
+			},
+			[]byte(`[[{"sleep":"10ms"},{"sequence":[{"sleep":"20ms"},[{"sleep":"30ms"}]]}]]`),
+		},
+		{
+			Script{
+				ComputeCommand{CPU: duration.Duration(5 * time.Millisecond)},
+				AllocateCommand{Bytes: 1 << 20},
+			},
+			[]byte(`[{"compute":{"cpu":"5ms"}},{"allocate":{"bytes":"1MiB"}}]`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			output, err := json.Marshal(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(test.output) != string(output) {
+				t.Errorf("expected %s; actual %s", test.output, output)
+			}
+		})
+	}
+}
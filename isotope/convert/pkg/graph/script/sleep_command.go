@@ -17,6 +17,8 @@ package script
 import (
 	"encoding/json"
 	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/dist"
 )
 
 // SleepCommand describes a command to pause for a duration.
@@ -40,3 +42,30 @@ func (c *SleepCommand) UnmarshalJSON(b []byte) (err error) {
 func (c SleepCommand) String() string {
 	return time.Duration(c).String()
 }
+
+// SleepDistributionCommand describes a command to pause for a duration drawn
+// from a random distribution each time it is executed.
+type SleepDistributionCommand struct {
+	Distribution dist.Distribution
+}
+
+// UnmarshalJSON converts a JSON object describing a distribution (e.g.
+// {"distribution": "lognormal", "median": "10ms", "p99": "80ms"}) to a
+// SleepDistributionCommand.
+func (c *SleepDistributionCommand) UnmarshalJSON(b []byte) (err error) {
+	d, err := dist.FromJSON(b)
+	if err != nil {
+		return
+	}
+	c.Distribution = d
+	return
+}
+
+// MarshalJSON encodes the SleepDistributionCommand as its distribution.
+func (c SleepDistributionCommand) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Distribution)
+}
+
+func (c SleepDistributionCommand) String() string {
+	return c.Distribution.String()
+}
@@ -18,6 +18,8 @@ import (
 	"encoding/json"
 	"testing"
 	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/dist"
 )
 
 func TestSleepCommand_UnmarshalJSON(t *testing.T) {
@@ -49,3 +51,40 @@ func TestSleepCommand_UnmarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestSleepDistributionCommand_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   []byte
+		command SleepDistributionCommand
+		err     error
+	}{
+		{
+			[]byte(`{"distribution": "uniform", "min": "10ms", "max": "20ms"}`),
+			SleepDistributionCommand{
+				dist.Uniform{Min: 10 * time.Millisecond, Max: 20 * time.Millisecond},
+			},
+			nil,
+		},
+		{
+			[]byte(`{"distribution": "exponential"}`),
+			SleepDistributionCommand{},
+			dist.MissingParameterError{Distribution: "exponential", Parameter: "mean"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			var command SleepDistributionCommand
+			err := json.Unmarshal(test.input, &command)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if test.command != command {
+				t.Errorf("expected %v; actual %v", test.command, command)
+			}
+		})
+	}
+}
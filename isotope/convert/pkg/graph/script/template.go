@@ -0,0 +1,118 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// randPlaceholder is replaced by a random integer in [0, N) where N is
+	// given as "{rand:N}", or defaultRandRange for "{rand}".
+	randPlaceholder  = "rand"
+	defaultRandRange = 1000
+	// uuidPlaceholder is replaced by a random version 4 UUID.
+	uuidPlaceholder = "uuid"
+)
+
+// Template is a string, such as a request path, which may contain
+// placeholders rendered on every request: "{rand}", "{rand:N}" and "{uuid}".
+// For example, "/items/{rand:100}" renders to "/items/42".
+type Template string
+
+// Validate returns an error if the template contains an unknown or malformed
+// placeholder.
+func (t Template) Validate() error {
+	_, err := t.render(func(int) int { return 0 })
+	return err
+}
+
+// Render replaces the placeholders of the template. intn must return a random
+// integer in [0, n).
+func (t Template) Render(intn func(n int) int) string {
+	s, err := t.render(intn)
+	if err != nil {
+		// Templates are validated when unmarshalled.
+		return string(t)
+	}
+	return s
+}
+
+func (t Template) render(intn func(n int) int) (string, error) {
+	s := string(t)
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(s, '{')
+		if start < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return "", InvalidTemplateError{string(t)}
+		}
+		end += start
+		b.WriteString(s[:start])
+		value, err := renderPlaceholder(s[start+1:end], intn)
+		if err != nil {
+			return "", InvalidTemplateError{string(t)}
+		}
+		b.WriteString(value)
+		s = s[end+1:]
+	}
+}
+
+func renderPlaceholder(placeholder string, intn func(n int) int) (string, error) {
+	name, arg, hasArg := strings.Cut(placeholder, ":")
+	switch name {
+	case randPlaceholder:
+		n := defaultRandRange
+		if hasArg {
+			var err error
+			n, err = strconv.Atoi(arg)
+			if err != nil || n <= 0 {
+				return "", fmt.Errorf("invalid range %q", arg)
+			}
+		}
+		return strconv.Itoa(intn(n)), nil
+	case uuidPlaceholder:
+		if hasArg {
+			return "", fmt.Errorf("unexpected argument %q", arg)
+		}
+		var b [16]byte
+		for i := range b {
+			b[i] = byte(intn(256))
+		}
+		b[6] = (b[6] & 0x0f) | 0x40
+		b[8] = (b[8] & 0x3f) | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+	default:
+		return "", fmt.Errorf("unknown placeholder %q", name)
+	}
+}
+
+// InvalidTemplateError is returned when a template has an unknown or
+// malformed placeholder.
+type InvalidTemplateError struct {
+	Template string
+}
+
+func (e InvalidTemplateError) Error() string {
+	return fmt.Sprintf(
+		`invalid template %q (placeholders must be "{rand}", "{rand:N}" or "{uuid}")`,
+		e.Template)
+}
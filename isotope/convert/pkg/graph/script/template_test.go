@@ -0,0 +1,86 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"testing"
+)
+
+func TestTemplate_Render(t *testing.T) {
+	// Always draws the largest value in range.
+	intn := func(n int) int { return n - 1 }
+
+	tests := []struct {
+		input  Template
+		output string
+		err    error
+	}{
+		{"/", "/", nil},
+		{"/items/{rand}", "/items/999", nil},
+		{"/items/{rand:10}/reviews", "/items/9/reviews", nil},
+		{"/users/{uuid}", "/users/ffffffff-ffff-4fff-bfff-ffffffffffff", nil},
+		{"/items/{rand:0}", "", InvalidTemplateError{"/items/{rand:0}"}},
+		{"/items/{id}", "", InvalidTemplateError{"/items/{id}"}},
+		{"/items/{rand", "", InvalidTemplateError{"/items/{rand"}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(string(test.input), func(t *testing.T) {
+			t.Parallel()
+
+			err := test.input.Validate()
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if output := test.input.Render(intn); test.output != output {
+				t.Errorf("expected %s; actual %s", test.output, output)
+			}
+		})
+	}
+}
+
+func TestRequestCommand_RenderURL(t *testing.T) {
+	intn := func(n int) int { return 7 }
+
+	tests := []struct {
+		command RequestCommand
+		url     string
+	}{
+		{RequestCommand{}, "http://a:8080"},
+		{RequestCommand{Path: "/checkout"}, "http://a:8080/checkout"},
+		{
+			RequestCommand{
+				Path:  "/items/{rand:10}",
+				Query: map[string]Template{"page": "{rand}", "sort": "asc"},
+			},
+			"http://a:8080/items/7?page=7&sort=asc",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			if url := test.command.RenderURL("a:8080", intn); test.url != url {
+				t.Errorf("expected %s; actual %s", test.url, url)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import "istio.io/tools/isotope/convert/pkg/graph/svc"
+
+// Slice returns the part of g needed to run the services for which include is
+// true: those services, and stubs of the other services they call, which only
+// keep their name, namespace, cluster and type.
+func (g ServiceGraph) Slice(include func(svc.Service) bool) ServiceGraph {
+	calls := g.Calls()
+	included := map[string]bool{}
+	called := map[string]bool{}
+	for _, service := range g.Services {
+		if include(service) {
+			included[service.Name] = true
+			for _, name := range calls[service.Name] {
+				called[name] = true
+			}
+		}
+	}
+
+	slice := ServiceGraph{Seed: g.Seed}
+	for _, service := range g.Services {
+		switch {
+		case included[service.Name]:
+			slice.Services = append(slice.Services, service)
+		case called[service.Name]:
+			slice.Services = append(slice.Services, svc.Service{
+				Name:      service.Name,
+				Namespace: service.Namespace,
+				Cluster:   service.Cluster,
+				Type:      service.Type,
+			})
+		}
+	}
+	return slice
+}
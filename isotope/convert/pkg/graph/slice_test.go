@@ -0,0 +1,71 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"istio.io/tools/isotope/convert/pkg/graph/svc"
+	"istio.io/tools/isotope/convert/pkg/graph/svctype"
+)
+
+func TestServiceGraph_Slice(t *testing.T) {
+	var g ServiceGraph
+	err := yaml.Unmarshal([]byte(`
+services:
+- name: a
+  namespace: one
+  script:
+  - call: b
+  - call:
+      service: c
+      onError: fallback:d
+- name: b
+  namespace: one
+- name: c
+  namespace: two
+  type: grpc
+  script:
+  - call: e
+- name: d
+  namespace: three
+  cluster: remote
+- name: e
+  namespace: two
+`), &g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slice := g.Slice(func(service svc.Service) bool {
+		return service.Namespace == "one"
+	})
+
+	expected := []svc.Service{
+		g.Services[0],
+		g.Services[1],
+		{Name: "c", Namespace: "two", Type: svctype.ServiceGRPC},
+		{Name: "d", Namespace: "three", Cluster: "remote", Type: svctype.ServiceHTTP},
+	}
+	if !reflect.DeepEqual(expected, slice.Services) {
+		t.Errorf("expected %v; actual %v", expected, slice.Services)
+	}
+	if err := validate(slice); err != nil {
+		t.Errorf("expected nil; actual %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+)
+
+// defaultTargetCPUUtilization is the CPU utilization, in percent of the
+// requested CPU, autoscalers aim for when not set.
+const defaultTargetCPUUtilization = 80
+
+// Resources are the compute resources of each replica of a service.
+type Resources struct {
+	Requests ResourceList `json:"requests,omitempty"`
+	Limits   ResourceList `json:"limits,omitempty"`
+}
+
+// ResourceList holds Kubernetes quantities of CPU (e.g. "500m") and memory
+// (e.g. "128Mi"). Unset quantities are not constrained.
+type ResourceList struct {
+	CPU    *resource.Quantity `json:"cpu,omitempty"`
+	Memory *resource.Quantity `json:"memory,omitempty"`
+}
+
+// Probes configure how Kubernetes checks the replicas of a service, by
+// requesting the health endpoint of the service port. Unset probes are not
+// added.
+type Probes struct {
+	Readiness *Probe `json:"readiness,omitempty"`
+	Liveness  *Probe `json:"liveness,omitempty"`
+}
+
+// Probe configures a Kubernetes probe. Durations are rounded up to seconds
+// and, like the failure threshold, default to Kubernetes' defaults when unset.
+type Probe struct {
+	InitialDelay     duration.Duration `json:"initialDelay,omitempty"`
+	Period           duration.Duration `json:"period,omitempty"`
+	Timeout          duration.Duration `json:"timeout,omitempty"`
+	FailureThreshold int32             `json:"failureThreshold,omitempty"`
+}
+
+// Autoscaling configures a HorizontalPodAutoscaler scaling a service on the
+// CPU utilization of its replicas, which requires a CPU request.
+type Autoscaling struct {
+	// MinReplicas defaults to the number of replicas of the service.
+	MinReplicas int32 `json:"minReplicas,omitempty"`
+	MaxReplicas int32 `json:"maxReplicas"`
+	// TargetCPUUtilization is the average CPU usage to aim for, in percent of
+	// the requested CPU. Defaults to 80.
+	TargetCPUUtilization int32 `json:"targetCPUUtilization,omitempty"`
+}
+
+// UnmarshalJSON converts b to Autoscaling and checks its limits.
+func (a *Autoscaling) UnmarshalJSON(b []byte) (err error) {
+	unmarshallable := unmarshallableAutoscaling{
+		TargetCPUUtilization: defaultTargetCPUUtilization,
+	}
+	err = json.Unmarshal(b, &unmarshallable)
+	if err != nil {
+		return
+	}
+	*a = Autoscaling(unmarshallable)
+	if a.MinReplicas < 0 {
+		return InvalidDeploymentError{"autoscaling", "minReplicas must be non-negative"}
+	}
+	if a.MaxReplicas < 1 || a.MaxReplicas < a.MinReplicas {
+		return InvalidDeploymentError{
+			"autoscaling", "maxReplicas must be positive and at least minReplicas"}
+	}
+	if a.TargetCPUUtilization < 1 {
+		return InvalidDeploymentError{"autoscaling", "targetCPUUtilization must be positive"}
+	}
+	return
+}
+
+type unmarshallableAutoscaling Autoscaling
+
+// DisruptionBudget configures a PodDisruptionBudget limiting how many
+// replicas of a service voluntary disruptions, like node drains, may take
+// down. Exactly one of its fields is set, to a number or a percentage.
+type DisruptionBudget struct {
+	MinAvailable   *intstr.IntOrString `json:"minAvailable,omitempty"`
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// UnmarshalJSON converts b to a DisruptionBudget and checks exactly one
+// field is set.
+func (d *DisruptionBudget) UnmarshalJSON(b []byte) (err error) {
+	var unmarshallable unmarshallableDisruptionBudget
+	err = json.Unmarshal(b, &unmarshallable)
+	if err != nil {
+		return
+	}
+	*d = DisruptionBudget(unmarshallable)
+	if (d.MinAvailable == nil) == (d.MaxUnavailable == nil) {
+		return InvalidDeploymentError{
+			"disruptionBudget", "exactly one of minAvailable and maxUnavailable must be set"}
+	}
+	return
+}
+
+type unmarshallableDisruptionBudget DisruptionBudget
+
+// validateDeployment checks the deployment settings of svc are consistent
+// with each other.
+func (svc Service) validateDeployment() error {
+	if svc.Autoscaling == nil {
+		return nil
+	}
+	if svc.Resources == nil || svc.Resources.Requests.CPU == nil {
+		return InvalidDeploymentError{"autoscaling", "requires resources.requests.cpu"}
+	}
+	if svc.Autoscaling.MinReplicas == 0 && svc.NumReplicas > svc.Autoscaling.MaxReplicas {
+		return InvalidDeploymentError{
+			"autoscaling", "maxReplicas must be at least numReplicas"}
+	}
+	return nil
+}
+
+// InvalidDeploymentError is returned when the Kubernetes deployment settings
+// of a service are misconfigured.
+type InvalidDeploymentError struct {
+	Setting string
+	Reason  string
+}
+
+func (e InvalidDeploymentError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Setting, e.Reason)
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+)
+
+func TestService_UnmarshalJSON_Deployment(t *testing.T) {
+	cpu := resource.MustParse("500m")
+	memory := resource.MustParse("128Mi")
+	half := intstr.FromString("50%")
+
+	tests := []struct {
+		input []byte
+		svc   Service
+		err   error
+	}{
+		{
+			[]byte(`{
+				"name": "A",
+				"resources": {"requests": {"cpu": "500m"}, "limits": {"memory": "128Mi"}},
+				"probes": {"readiness": {"period": "5s", "failureThreshold": 2}},
+				"autoscaling": {"maxReplicas": 4},
+				"disruptionBudget": {"minAvailable": "50%"}
+			}`),
+			Service{
+				Name:        "A",
+				Type:        DefaultService.Type,
+				NumReplicas: 1,
+				Resources: &Resources{
+					Requests: ResourceList{CPU: &cpu},
+					Limits:   ResourceList{Memory: &memory},
+				},
+				Probes: &Probes{
+					Readiness: &Probe{
+						Period:           duration.Duration(5 * time.Second),
+						FailureThreshold: 2,
+					},
+				},
+				Autoscaling: &Autoscaling{
+					MaxReplicas:          4,
+					TargetCPUUtilization: defaultTargetCPUUtilization,
+				},
+				DisruptionBudget: &DisruptionBudget{MinAvailable: &half},
+			},
+			nil,
+		},
+		{
+			[]byte(`{"name": "A", "autoscaling": {"maxReplicas": 4}}`),
+			Service{},
+			InvalidDeploymentError{"autoscaling", "requires resources.requests.cpu"},
+		},
+		{
+			[]byte(`{
+				"name": "A",
+				"numReplicas": 5,
+				"resources": {"requests": {"cpu": 1}},
+				"autoscaling": {"maxReplicas": 4}
+			}`),
+			Service{},
+			InvalidDeploymentError{"autoscaling", "maxReplicas must be at least numReplicas"},
+		},
+		{
+			[]byte(`{"name": "A", "autoscaling": {"minReplicas": 3, "maxReplicas": 2}}`),
+			Service{},
+			InvalidDeploymentError{
+				"autoscaling", "maxReplicas must be positive and at least minReplicas"},
+		},
+		{
+			[]byte(`{"name": "A", "disruptionBudget": {}}`),
+			Service{},
+			InvalidDeploymentError{
+				"disruptionBudget", "exactly one of minAvailable and maxUnavailable must be set"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			var svc Service
+			err := json.Unmarshal(test.input, &svc)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if err == nil && !reflect.DeepEqual(test.svc, svc) {
+				t.Errorf("expected %+v; actual %+v", test.svc, svc)
+			}
+		})
+	}
+}
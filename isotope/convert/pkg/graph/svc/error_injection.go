@@ -0,0 +1,238 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+	"istio.io/tools/isotope/convert/pkg/graph/pct"
+)
+
+// ErrorMode describes how a service spreads its ErrorRate over requests.
+type ErrorMode string
+
+const (
+	// ErrorModeDeterministic fails exactly every Nth request. It is the
+	// default.
+	ErrorModeDeterministic ErrorMode = "deterministic"
+	// ErrorModeRandom fails each request independently with a probability of
+	// ErrorRate.
+	ErrorModeRandom ErrorMode = "random"
+)
+
+// UnmarshalJSON converts a JSON string to an ErrorMode.
+func (m *ErrorMode) UnmarshalJSON(b []byte) (err error) {
+	var s string
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return
+	}
+	switch mode := ErrorMode(s); mode {
+	case ErrorModeDeterministic, ErrorModeRandom:
+		*m = mode
+	default:
+		err = InvalidErrorModeError{s}
+	}
+	return
+}
+
+// ConnectionFailure describes how an injected failure breaks the connection
+// instead of responding.
+type ConnectionFailure string
+
+const (
+	// ConnectionReset resets the connection (TCP RST).
+	ConnectionReset ConnectionFailure = "reset"
+	// ConnectionAbort closes the connection without a response.
+	ConnectionAbort ConnectionFailure = "abort"
+)
+
+// UnmarshalJSON converts a JSON string to a ConnectionFailure.
+func (c *ConnectionFailure) UnmarshalJSON(b []byte) (err error) {
+	var s string
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return
+	}
+	switch failure := ConnectionFailure(s); failure {
+	case ConnectionReset, ConnectionAbort:
+		*c = failure
+	default:
+		err = InvalidConnectionFailureError{s}
+	}
+	return
+}
+
+// ErrorInjection describes how a service fails the requests selected by its
+// ErrorRate.
+type ErrorInjection struct {
+	// Mode is how failing requests are selected. Defaults to
+	// ErrorModeDeterministic.
+	Mode ErrorMode `json:"mode,omitempty"`
+
+	// Failures is the set of failures to choose from, by weight, when a
+	// request fails. Defaults to responding with a 500.
+	Failures []Failure `json:"failures,omitempty"`
+
+	// Bursts are periodic time windows during which the error rate of the
+	// burst replaces the service's ErrorRate.
+	Bursts []ErrorBurst `json:"bursts,omitempty"`
+}
+
+// Failure is a single kind of injected failure.
+type Failure struct {
+	// Code is the HTTP status code to respond with. Defaults to 500.
+	Code int `json:"code,omitempty"`
+
+	// Connection, if set, breaks the connection instead of responding.
+	Connection ConnectionFailure `json:"connection,omitempty"`
+
+	// Weight is the relative chance of choosing this failure. Defaults to 1.
+	Weight int `json:"weight,omitempty"`
+}
+
+// DefaultFailure is the failure injected when no failures are configured.
+var DefaultFailure = Failure{Code: http.StatusInternalServerError, Weight: 1}
+
+// UnmarshalJSON converts b to a Failure, applying the defaults of
+// DefaultFailure.
+func (f *Failure) UnmarshalJSON(b []byte) (err error) {
+	unmarshallable := unmarshallableFailure(DefaultFailure)
+	err = json.Unmarshal(b, &unmarshallable)
+	if err != nil {
+		return
+	}
+	*f = Failure(unmarshallable)
+	if f.Weight < 0 {
+		err = InvalidFailureError{"weight must be non-negative"}
+		return
+	}
+	if f.Connection == "" && (f.Code < 100 || f.Code > 599) {
+		err = InvalidFailureError{fmt.Sprintf("invalid HTTP status code %d", f.Code)}
+		return
+	}
+	return
+}
+
+type unmarshallableFailure Failure
+
+// ErrorBurst is a window of Duration, repeating Every period, in which
+// requests fail randomly with Rate. Windows are aligned to the wall clock so
+// that every replica of a service bursts at the same time.
+type ErrorBurst struct {
+	Rate     pct.Percentage    `json:"rate"`
+	Duration duration.Duration `json:"duration"`
+	Every    duration.Duration `json:"every"`
+}
+
+// UnmarshalJSON converts b to an ErrorBurst and checks the window fits in the
+// period.
+func (b *ErrorBurst) UnmarshalJSON(data []byte) (err error) {
+	var unmarshallable unmarshallableErrorBurst
+	err = json.Unmarshal(data, &unmarshallable)
+	if err != nil {
+		return
+	}
+	*b = ErrorBurst(unmarshallable)
+	if b.Every <= 0 || b.Duration > b.Every {
+		err = InvalidErrorBurstError{*b}
+		return
+	}
+	return
+}
+
+type unmarshallableErrorBurst ErrorBurst
+
+// IsActive returns true if t falls within a window of the burst.
+func (b ErrorBurst) IsActive(t time.Time) bool {
+	return time.Duration(t.UnixNano()%int64(b.Every)) < time.Duration(b.Duration)
+}
+
+// ActiveBurst returns the first burst active at time t, if any.
+func (e ErrorInjection) ActiveBurst(t time.Time) (ErrorBurst, bool) {
+	for _, b := range e.Bursts {
+		if b.IsActive(t) {
+			return b, true
+		}
+	}
+	return ErrorBurst{}, false
+}
+
+// ChooseFailure picks one of the Failures by weight, using intn to draw a
+// random number in [0, n). DefaultFailure is returned if there are none.
+func (e ErrorInjection) ChooseFailure(intn func(n int) int) Failure {
+	total := 0
+	for _, f := range e.Failures {
+		total += f.Weight
+	}
+	if total == 0 {
+		return DefaultFailure
+	}
+	x := intn(total)
+	for _, f := range e.Failures {
+		if x < f.Weight {
+			return f
+		}
+		x -= f.Weight
+	}
+	return DefaultFailure
+}
+
+// InvalidErrorModeError is returned when an error mode is not recognized.
+type InvalidErrorModeError struct {
+	Mode string
+}
+
+func (e InvalidErrorModeError) Error() string {
+	return fmt.Sprintf(
+		"unknown error mode: %q (must be %q or %q)",
+		e.Mode, ErrorModeDeterministic, ErrorModeRandom)
+}
+
+// InvalidConnectionFailureError is returned when a connection failure is not
+// recognized.
+type InvalidConnectionFailureError struct {
+	Connection string
+}
+
+func (e InvalidConnectionFailureError) Error() string {
+	return fmt.Sprintf(
+		"unknown connection failure: %q (must be %q or %q)",
+		e.Connection, ConnectionReset, ConnectionAbort)
+}
+
+// InvalidFailureError is returned when a failure is misconfigured.
+type InvalidFailureError struct {
+	Reason string
+}
+
+func (e InvalidFailureError) Error() string {
+	return "invalid failure: " + e.Reason
+}
+
+// InvalidErrorBurstError is returned when a burst is longer than its period.
+type InvalidErrorBurstError struct {
+	Burst ErrorBurst
+}
+
+func (e InvalidErrorBurstError) Error() string {
+	return fmt.Sprintf(
+		"error burst of %s every %s must have a positive period at least as long as the burst",
+		e.Burst.Duration, e.Burst.Every)
+}
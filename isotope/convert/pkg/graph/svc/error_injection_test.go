@@ -0,0 +1,150 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+)
+
+func TestErrorInjection_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input     []byte
+		injection ErrorInjection
+		err       error
+	}{
+		{
+			[]byte(`{"mode": "random", "failures": [{"code": 503, "weight": 3}, {"connection": "reset"}]}`),
+			ErrorInjection{
+				Mode: ErrorModeRandom,
+				Failures: []Failure{
+					{Code: 503, Weight: 3},
+					{Code: 500, Connection: ConnectionReset, Weight: 1},
+				},
+			},
+			nil,
+		},
+		{
+			[]byte(`{"bursts": [{"rate": "30%", "duration": "10s", "every": "2m"}]}`),
+			ErrorInjection{
+				Bursts: []ErrorBurst{
+					{
+						Rate:     0.3,
+						Duration: duration.Duration(10 * time.Second),
+						Every:    duration.Duration(2 * time.Minute),
+					},
+				},
+			},
+			nil,
+		},
+		{
+			[]byte(`{"mode": "sometimes"}`),
+			ErrorInjection{},
+			InvalidErrorModeError{"sometimes"},
+		},
+		{
+			[]byte(`{"failures": [{"connection": "drop"}]}`),
+			ErrorInjection{},
+			InvalidConnectionFailureError{"drop"},
+		},
+		{
+			[]byte(`{"failures": [{"code": 42}]}`),
+			ErrorInjection{},
+			InvalidFailureError{"invalid HTTP status code 42"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			var injection ErrorInjection
+			err := json.Unmarshal(test.input, &injection)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if err == nil && !reflect.DeepEqual(test.injection, injection) {
+				t.Errorf("expected %v; actual %v", test.injection, injection)
+			}
+		})
+	}
+}
+
+func TestErrorInjection_ChooseFailure(t *testing.T) {
+	injection := ErrorInjection{
+		Failures: []Failure{
+			{Code: 503, Weight: 3},
+			{Code: 429, Weight: 1},
+		},
+	}
+	tests := []struct {
+		draw    int
+		failure Failure
+	}{
+		{0, Failure{Code: 503, Weight: 3}},
+		{2, Failure{Code: 503, Weight: 3}},
+		{3, Failure{Code: 429, Weight: 1}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			failure := injection.ChooseFailure(func(n int) int { return test.draw })
+			if test.failure != failure {
+				t.Errorf("expected %v; actual %v", test.failure, failure)
+			}
+		})
+	}
+
+	if failure := (ErrorInjection{}).ChooseFailure(nil); failure != DefaultFailure {
+		t.Errorf("expected %v; actual %v", DefaultFailure, failure)
+	}
+}
+
+func TestErrorBurst_IsActive(t *testing.T) {
+	burst := ErrorBurst{
+		Rate:     0.3,
+		Duration: duration.Duration(10 * time.Second),
+		Every:    duration.Duration(2 * time.Minute),
+	}
+	tests := []struct {
+		time   time.Time
+		active bool
+	}{
+		{time.Unix(0, 0), true},
+		{time.Unix(9, 0), true},
+		{time.Unix(10, 0), false},
+		{time.Unix(119, 0), false},
+		{time.Unix(120, 0), true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			if active := burst.IsActive(test.time); test.active != active {
+				t.Errorf("expected %v; actual %v", test.active, active)
+			}
+		})
+	}
+}
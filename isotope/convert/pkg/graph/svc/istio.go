@@ -0,0 +1,159 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+	"istio.io/tools/isotope/convert/pkg/graph/pct"
+)
+
+// TLS modes of the connections to a service, as in Istio's ClientTLSSettings.
+const (
+	TLSModeDisable     = "DISABLE"
+	TLSModeSimple      = "SIMPLE"
+	TLSModeMutual      = "MUTUAL"
+	TLSModeIstioMutual = "ISTIO_MUTUAL"
+)
+
+// Istio configures the Istio VirtualService and DestinationRule generated for
+// a service. Unset settings are left to Istio's defaults.
+type Istio struct {
+	// Timeout bounds each request to the service, including retries.
+	Timeout duration.Duration `json:"timeout,omitempty"`
+
+	// Retries are made by the caller's proxy on failed requests.
+	Retries *IstioRetries `json:"retries,omitempty"`
+
+	// Fault is injected by the caller's proxy into requests to the service.
+	Fault *IstioFault `json:"fault,omitempty"`
+
+	// TrafficSplit maps subsets of the service, selected by their "version"
+	// label, to the percentage of requests they receive. The percentages add
+	// up to 100.
+	TrafficSplit map[string]int32 `json:"trafficSplit,omitempty"`
+
+	// ConnectionPool limits the connections and requests to the service.
+	ConnectionPool *IstioConnectionPool `json:"connectionPool,omitempty"`
+
+	// OutlierDetection ejects replicas which keep failing from the load
+	// balancing pool.
+	OutlierDetection *IstioOutlierDetection `json:"outlierDetection,omitempty"`
+
+	// TLSMode is the TLS mode of the connections to the service: DISABLE,
+	// SIMPLE, MUTUAL or ISTIO_MUTUAL.
+	TLSMode string `json:"tlsMode,omitempty"`
+}
+
+// IstioRetries configures the retries of requests to a service.
+type IstioRetries struct {
+	Attempts      int32             `json:"attempts"`
+	PerTryTimeout duration.Duration `json:"perTryTimeout,omitempty"`
+	// RetryOn lists the conditions to retry on, e.g. "5xx,reset".
+	RetryOn string `json:"retryOn,omitempty"`
+}
+
+// IstioFault configures the delays and aborts injected into requests to a
+// service.
+type IstioFault struct {
+	Delay *IstioDelay `json:"delay,omitempty"`
+	Abort *IstioAbort `json:"abort,omitempty"`
+}
+
+// IstioDelay delays a percentage of requests by a fixed duration.
+type IstioDelay struct {
+	Percentage pct.Percentage    `json:"percentage"`
+	FixedDelay duration.Duration `json:"fixedDelay"`
+}
+
+// IstioAbort fails a percentage of requests with an HTTP status.
+type IstioAbort struct {
+	Percentage pct.Percentage `json:"percentage"`
+	HTTPStatus int32          `json:"httpStatus"`
+}
+
+// IstioConnectionPool limits the connections and requests to each replica of
+// a service.
+type IstioConnectionPool struct {
+	MaxConnections           int32             `json:"maxConnections,omitempty"`
+	ConnectTimeout           duration.Duration `json:"connectTimeout,omitempty"`
+	HTTP1MaxPendingRequests  int32             `json:"http1MaxPendingRequests,omitempty"`
+	HTTP2MaxRequests         int32             `json:"http2MaxRequests,omitempty"`
+	MaxRequestsPerConnection int32             `json:"maxRequestsPerConnection,omitempty"`
+	MaxRetries               int32             `json:"maxRetries,omitempty"`
+}
+
+// IstioOutlierDetection ejects replicas of a service after consecutive
+// failures.
+type IstioOutlierDetection struct {
+	Consecutive5xxErrors int32             `json:"consecutive5xxErrors,omitempty"`
+	Interval             duration.Duration `json:"interval,omitempty"`
+	BaseEjectionTime     duration.Duration `json:"baseEjectionTime,omitempty"`
+	MaxEjectionPercent   int32             `json:"maxEjectionPercent,omitempty"`
+}
+
+// UnmarshalJSON converts b to Istio and checks its settings.
+func (i *Istio) UnmarshalJSON(b []byte) (err error) {
+	var unmarshallable unmarshallableIstio
+	err = json.Unmarshal(b, &unmarshallable)
+	if err != nil {
+		return
+	}
+	*i = Istio(unmarshallable)
+	return i.validate()
+}
+
+type unmarshallableIstio Istio
+
+func (i Istio) validate() error {
+	if i.Retries != nil && i.Retries.Attempts < 1 {
+		return InvalidIstioError{"retries.attempts must be positive"}
+	}
+	if i.Fault != nil && i.Fault.Abort != nil &&
+		(i.Fault.Abort.HTTPStatus < 200 || i.Fault.Abort.HTTPStatus > 599) {
+		return InvalidIstioError{"fault.abort.httpStatus must be an HTTP status"}
+	}
+	if len(i.TrafficSplit) > 0 {
+		var total int32
+		for subset, weight := range i.TrafficSplit {
+			if weight < 0 {
+				return InvalidIstioError{
+					fmt.Sprintf("trafficSplit of %q must be non-negative", subset)}
+			}
+			total += weight
+		}
+		if total != 100 {
+			return InvalidIstioError{"trafficSplit must add up to 100"}
+		}
+	}
+	switch i.TLSMode {
+	case "", TLSModeDisable, TLSModeSimple, TLSModeMutual, TLSModeIstioMutual:
+	default:
+		return InvalidIstioError{fmt.Sprintf("unknown tlsMode %q", i.TLSMode)}
+	}
+	return nil
+}
+
+// InvalidIstioError is returned when the Istio settings of a service are
+// misconfigured.
+type InvalidIstioError struct {
+	Reason string
+}
+
+func (e InvalidIstioError) Error() string {
+	return fmt.Sprintf("invalid istio: %s", e.Reason)
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+)
+
+func TestIstio_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input []byte
+		istio Istio
+		err   error
+	}{
+		{
+			[]byte(`{"timeout": "2s", "retries": {"attempts": 3, "retryOn": "5xx"}, "trafficSplit": {"v1": 90, "v2": 10}, "tlsMode": "ISTIO_MUTUAL"}`),
+			Istio{
+				Timeout:      duration.Duration(2 * time.Second),
+				Retries:      &IstioRetries{Attempts: 3, RetryOn: "5xx"},
+				TrafficSplit: map[string]int32{"v1": 90, "v2": 10},
+				TLSMode:      TLSModeIstioMutual,
+			},
+			nil,
+		},
+		{
+			[]byte(`{}`),
+			Istio{},
+			nil,
+		},
+		{
+			[]byte(`{"retries": {"attempts": 0}}`),
+			Istio{},
+			InvalidIstioError{"retries.attempts must be positive"},
+		},
+		{
+			[]byte(`{"fault": {"abort": {"percentage": "1%", "httpStatus": 42}}}`),
+			Istio{},
+			InvalidIstioError{"fault.abort.httpStatus must be an HTTP status"},
+		},
+		{
+			[]byte(`{"trafficSplit": {"v1": 90, "v2": 20}}`),
+			Istio{},
+			InvalidIstioError{"trafficSplit must add up to 100"},
+		},
+		{
+			[]byte(`{"trafficSplit": {"v1": 110, "v2": -10}}`),
+			Istio{},
+			InvalidIstioError{`trafficSplit of "v2" must be non-negative`},
+		},
+		{
+			[]byte(`{"tlsMode": "STRICT"}`),
+			Istio{},
+			InvalidIstioError{`unknown tlsMode "STRICT"`},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			var istio Istio
+			err := json.Unmarshal(test.input, &istio)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if err == nil && !reflect.DeepEqual(test.istio, istio) {
+				t.Errorf("expected %+v; actual %+v", test.istio, istio)
+			}
+		})
+	}
+}
+
+func TestService_UnmarshalJSON_KeepsDefaultPointers(t *testing.T) {
+	defaultIstio := &Istio{TLSMode: TLSModeIstioMutual}
+	origDefaultService := DefaultService
+	DefaultService = Service{Istio: defaultIstio}
+	defer func() { DefaultService = origDefaultService }()
+
+	var services []Service
+	err := json.Unmarshal(
+		[]byte(`[{"name": "a", "istio": {"timeout": "1s"}}, {"name": "b"}]`), &services)
+	if err != nil {
+		t.Fatalf("expected nil; actual %v", err)
+	}
+	expected := Istio{Timeout: duration.Duration(time.Second)}
+	if !reflect.DeepEqual(expected, *services[0].Istio) {
+		t.Errorf("expected %+v; actual %+v", expected, *services[0].Istio)
+	}
+	if services[1].Istio != defaultIstio || defaultIstio.TLSMode != TLSModeIstioMutual {
+		t.Errorf("expected %+v; actual %+v", defaultIstio, services[1].Istio)
+	}
+}
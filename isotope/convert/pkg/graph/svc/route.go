@@ -0,0 +1,103 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svc
+
+import (
+	"fmt"
+	"strings"
+
+	"istio.io/tools/isotope/convert/pkg/graph/pct"
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+	"istio.io/tools/isotope/convert/pkg/graph/size"
+)
+
+// Route describes how a service behaves for requests matching a path and,
+// optionally, a method, instead of its top-level script.
+type Route struct {
+	// Script is sequentially called each time the route is called. If unset,
+	// the route responds immediately.
+	Script script.Script `json:"script,omitempty"`
+
+	// ErrorRate is the percentage chance between 0 and 1 that the route fails
+	// a request. Defaults to the service's ErrorRate.
+	ErrorRate pct.Percentage `json:"errorRate,omitempty"`
+
+	// ResponseSize is the number of bytes in the response body. Defaults to
+	// the service's ResponseSize.
+	ResponseSize size.ByteSize `json:"responseSize,omitempty"`
+}
+
+// ParseRouteKey splits a key of Service.Routes into its method and path. A key
+// is either a path (matching every method), such as "/health", or a method
+// and path separated by a space, such as "POST /checkout". A path ending in
+// "*" matches every path with that prefix.
+func ParseRouteKey(key string) (method string, path string, err error) {
+	path = key
+	if m, p, ok := strings.Cut(key, " "); ok {
+		method, path = m, strings.TrimSpace(p)
+		if method == "" || method != strings.ToUpper(method) {
+			err = InvalidRouteKeyError{key}
+			return
+		}
+	}
+	if !strings.HasPrefix(path, "/") || strings.Contains(path, " ") ||
+		strings.Contains(strings.TrimSuffix(path, "*"), "*") {
+		err = InvalidRouteKeyError{key}
+		return
+	}
+	return
+}
+
+// MatchRoute returns the key of the route matching a request to method and
+// path. Exact paths take precedence over prefixes, longer prefixes over
+// shorter ones, and routes with a method over routes without one.
+func (svc Service) MatchRoute(method string, path string) (string, bool) {
+	if _, ok := svc.Routes[method+" "+path]; ok {
+		return method + " " + path, true
+	}
+	if _, ok := svc.Routes[path]; ok {
+		return path, true
+	}
+
+	bestKey, bestLength, bestHasMethod := "", -1, false
+	for key := range svc.Routes {
+		routeMethod, routePath, err := ParseRouteKey(key)
+		if err != nil || !strings.HasSuffix(routePath, "*") {
+			continue
+		}
+		prefix := strings.TrimSuffix(routePath, "*")
+		if !strings.HasPrefix(path, prefix) || (routeMethod != "" && routeMethod != method) {
+			continue
+		}
+		hasMethod := routeMethod != ""
+		if len(prefix) > bestLength ||
+			(len(prefix) == bestLength && hasMethod && !bestHasMethod) ||
+			(len(prefix) == bestLength && hasMethod == bestHasMethod && key < bestKey) {
+			bestKey, bestLength, bestHasMethod = key, len(prefix), hasMethod
+		}
+	}
+	return bestKey, bestLength >= 0
+}
+
+// InvalidRouteKeyError is returned when a key of Service.Routes is neither a
+// path nor a method followed by a path.
+type InvalidRouteKeyError struct {
+	Key string
+}
+
+func (e InvalidRouteKeyError) Error() string {
+	return fmt.Sprintf(
+		`invalid route %q (must be "<path>" or "<METHOD> <path>")`, e.Key)
+}
@@ -0,0 +1,130 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"istio.io/tools/isotope/convert/pkg/graph/svctype"
+)
+
+func TestParseRouteKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		method string
+		path   string
+		err    error
+	}{
+		{"/health", "", "/health", nil},
+		{"POST /checkout", "POST", "/checkout", nil},
+		{"GET /items/*", "GET", "/items/*", nil},
+		{"checkout", "", "", InvalidRouteKeyError{"checkout"}},
+		{"post /checkout", "", "", InvalidRouteKeyError{"post /checkout"}},
+		{"/items/*/reviews", "", "", InvalidRouteKeyError{"/items/*/reviews"}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.key, func(t *testing.T) {
+			t.Parallel()
+
+			method, path, err := ParseRouteKey(test.key)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if err == nil && (test.method != method || test.path != path) {
+				t.Errorf("expected %s %s; actual %s %s", test.method, test.path, method, path)
+			}
+		})
+	}
+}
+
+func TestService_MatchRoute(t *testing.T) {
+	service := Service{
+		Routes: map[string]Route{
+			"/health":        {},
+			"POST /checkout": {},
+			"/checkout":      {},
+			"/items/*":       {},
+			"GET /items/*":   {},
+			"/items/a/*":     {},
+		},
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		key    string
+		ok     bool
+	}{
+		{"GET", "/health", "/health", true},
+		{"POST", "/checkout", "POST /checkout", true},
+		{"GET", "/checkout", "/checkout", true},
+		{"GET", "/items/b", "GET /items/*", true},
+		{"PUT", "/items/b", "/items/*", true},
+		{"GET", "/items/a/1", "/items/a/*", true},
+		{"GET", "/", "", false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.method+" "+test.path, func(t *testing.T) {
+			t.Parallel()
+
+			key, ok := service.MatchRoute(test.method, test.path)
+			if test.key != key || test.ok != ok {
+				t.Errorf("expected %q %v; actual %q %v", test.key, test.ok, key, ok)
+			}
+		})
+	}
+}
+
+func TestService_UnmarshalJSON_Routes(t *testing.T) {
+	input := []byte(`{
+		"name": "a",
+		"errorRate": 0.1,
+		"responseSize": 128,
+		"routes": {
+			"/health": {},
+			"POST /checkout": {"errorRate": 0, "responseSize": 16}
+		}
+	}`)
+	expected := Service{
+		Name:         "a",
+		Type:         svctype.ServiceHTTP,
+		NumReplicas:  1,
+		ErrorRate:    0.1,
+		ResponseSize: 128,
+		Routes: map[string]Route{
+			"/health":        {ErrorRate: 0.1, ResponseSize: 128},
+			"POST /checkout": {ErrorRate: 0, ResponseSize: 16},
+		},
+	}
+
+	var service Service
+	if err := json.Unmarshal(input, &service); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, service) {
+		t.Errorf("expected %v; actual %v", expected, service)
+	}
+
+	err := json.Unmarshal([]byte(`{"name": "a", "routes": {"health": {}}}`), &service)
+	if expected := (InvalidRouteKeyError{"health"}); err != expected {
+		t.Errorf("expected %v; actual %v", expected, err)
+	}
+}
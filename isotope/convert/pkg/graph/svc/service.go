@@ -43,15 +43,54 @@ type Service struct {
 	IsEntrypoint bool `json:"isEntrypoint,omitempty"`
 
 	// ErrorRate is the percentage chance between 0 and 1 that this service
-	// should respond with a 500 server error rather than 200 OK.
+	// should fail a request (by default, respond with a 500 server error)
+	// rather than respond with 200 OK.
 	ErrorRate pct.Percentage `json:"errorRate,omitempty"`
 
+	// ErrorInjection describes how failing requests are selected and how they
+	// fail.
+	ErrorInjection *ErrorInjection `json:"errorInjection,omitempty"`
+
 	// ResponseSize is the number of bytes in the response body.
 	ResponseSize size.ByteSize `json:"responseSize,omitempty"`
 
 	// Script is sequentially called each time the service is called.
 	Script script.Script `json:"script,omitempty"`
 
+	// Routes overrides the behaviour of the service for requests matching a
+	// path or a method and path. See ParseRouteKey for the format of keys.
+	Routes map[string]Route `json:"routes,omitempty"`
+
+	// Versions deploy the service as several versions, each with its own
+	// replicas and behaviour, behind the same Service.
+	Versions []Version `json:"versions,omitempty"`
+
+	// BackgroundCPU is the number of CPU cores, e.g. 0.25, each replica keeps
+	// busy regardless of requests, like a real service's background work.
+	BackgroundCPU float64 `json:"backgroundCPU,omitempty"`
+
+	// Resources are the CPU and memory requests and limits of each replica.
+	Resources *Resources `json:"resources,omitempty"`
+
+	// Probes are the readiness and liveness probes of each replica.
+	Probes *Probes `json:"probes,omitempty"`
+
+	// Autoscaling adds a HorizontalPodAutoscaler for the service.
+	Autoscaling *Autoscaling `json:"autoscaling,omitempty"`
+
+	// DisruptionBudget adds a PodDisruptionBudget for the service.
+	DisruptionBudget *DisruptionBudget `json:"disruptionBudget,omitempty"`
+
+	// Istio configures the Istio traffic resources generated for the service.
+	Istio *Istio `json:"istio,omitempty"`
+
+	// Transport configures the connections of the service's outgoing calls.
+	Transport *Transport `json:"transport,omitempty"`
+
+	// Seed, if set, makes the random decisions of a request depend only on
+	// the seed and the request's ID, or the seed it was called with.
+	Seed *uint64 `json:"seed,omitempty"`
+
 	// Labels to add to the generated K8S entities.
 	Labels map[string]string `json:"labels,omitempty"`
 }
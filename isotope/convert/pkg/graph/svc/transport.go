@@ -0,0 +1,82 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+)
+
+// Transport configures the connections a service opens for its outgoing
+// calls. Zero values leave the setting of the service process unchanged.
+type Transport struct {
+	// HTTP2 sends HTTP calls over HTTP/2: cleartext (h2c) with prior knowledge
+	// or, with TLS, negotiated via ALPN.
+	HTTP2 bool `json:"http2,omitempty"`
+
+	// KeepAlive reuses connections between calls when true and opens a new
+	// connection per call when false.
+	KeepAlive *bool `json:"keepAlive,omitempty"`
+
+	// MaxConnectionsPerHost limits the connections to each destination.
+	MaxConnectionsPerHost int `json:"maxConnectionsPerHost,omitempty"`
+
+	// MaxIdleConnectionsPerHost is the number of idle connections to keep open
+	// to each destination.
+	MaxIdleConnectionsPerHost int `json:"maxIdleConnectionsPerHost,omitempty"`
+
+	// IdleTimeout closes connections which have been idle for this long.
+	IdleTimeout duration.Duration `json:"idleTimeout,omitempty"`
+
+	// TLS calls destinations over TLS.
+	TLS bool `json:"tls,omitempty"`
+
+	// TLSInsecureSkipVerify accepts any certificate from destinations, such as
+	// the self-signed ones of a test cluster.
+	TLSInsecureSkipVerify bool `json:"tlsInsecureSkipVerify,omitempty"`
+}
+
+// UnmarshalJSON converts b to a Transport and checks the limits are not
+// negative.
+func (t *Transport) UnmarshalJSON(b []byte) (err error) {
+	var unmarshallable unmarshallableTransport
+	err = json.Unmarshal(b, &unmarshallable)
+	if err != nil {
+		return
+	}
+	*t = Transport(unmarshallable)
+	if t.MaxConnectionsPerHost < 0 {
+		err = InvalidTransportError{"maxConnectionsPerHost must be non-negative"}
+		return
+	}
+	if t.MaxIdleConnectionsPerHost < 0 {
+		err = InvalidTransportError{"maxIdleConnectionsPerHost must be non-negative"}
+		return
+	}
+	return
+}
+
+type unmarshallableTransport Transport
+
+// InvalidTransportError is returned when a transport is misconfigured.
+type InvalidTransportError struct {
+	Reason string
+}
+
+func (e InvalidTransportError) Error() string {
+	return fmt.Sprintf("invalid transport: %s", e.Reason)
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+)
+
+func TestTransport_UnmarshalJSON(t *testing.T) {
+	keepAlive := false
+	tests := []struct {
+		input     []byte
+		transport Transport
+		err       error
+	}{
+		{
+			[]byte(`{"http2": true, "keepAlive": false, "maxConnectionsPerHost": 10, "idleTimeout": "30s", "tls": true}`),
+			Transport{
+				HTTP2:                 true,
+				KeepAlive:             &keepAlive,
+				MaxConnectionsPerHost: 10,
+				IdleTimeout:           duration.Duration(30 * time.Second),
+				TLS:                   true,
+			},
+			nil,
+		},
+		{
+			[]byte(`{}`),
+			Transport{},
+			nil,
+		},
+		{
+			[]byte(`{"maxConnectionsPerHost": -1}`),
+			Transport{},
+			InvalidTransportError{"maxConnectionsPerHost must be non-negative"},
+		},
+		{
+			[]byte(`{"maxIdleConnectionsPerHost": -1}`),
+			Transport{},
+			InvalidTransportError{"maxIdleConnectionsPerHost must be non-negative"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			var transport Transport
+			err := json.Unmarshal(test.input, &transport)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if err == nil && !reflect.DeepEqual(test.transport, transport) {
+				t.Errorf("expected %+v; actual %+v", test.transport, transport)
+			}
+		})
+	}
+}
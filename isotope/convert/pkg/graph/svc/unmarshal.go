@@ -27,21 +27,110 @@ var DefaultService = Service{Type: svctype.ServiceHTTP, NumReplicas: 1}
 // UnmarshalJSON converts b to a Service, applying the default values from
 // DefaultService.
 func (svc *Service) UnmarshalJSON(b []byte) (err error) {
-	unmarshallable := unmarshallableService(DefaultService)
+	// Decoding into the pointers of DefaultService would change the defaults
+	// of every service, so they are only inherited when omitted.
+	unmarshallable := unmarshallableService(DefaultService.withoutPointers())
 	err = json.Unmarshal(b, &unmarshallable)
 	if err != nil {
 		return
 	}
 	*svc = Service(unmarshallable)
+	svc.inheritPointers(DefaultService)
 	if svc.Name == "" {
 		err = ErrEmptyName
 		return
 	}
+	if svc.BackgroundCPU < 0 {
+		err = ErrNegativeBackgroundCPU
+		return
+	}
+	err = svc.validateDeployment()
+	if err != nil {
+		return
+	}
+	err = svc.inheritRouteDefaults(b)
+	if err != nil {
+		return
+	}
+	err = svc.inheritVersionDefaults(b)
+	if err != nil {
+		return
+	}
 	return
 }
 
 type unmarshallableService Service
 
+func (svc Service) withoutPointers() Service {
+	svc.ErrorInjection = nil
+	svc.Resources = nil
+	svc.Probes = nil
+	svc.Autoscaling = nil
+	svc.DisruptionBudget = nil
+	svc.Istio = nil
+	svc.Transport = nil
+	return svc
+}
+
+func (svc *Service) inheritPointers(defaults Service) {
+	if svc.ErrorInjection == nil {
+		svc.ErrorInjection = defaults.ErrorInjection
+	}
+	if svc.Resources == nil {
+		svc.Resources = defaults.Resources
+	}
+	if svc.Probes == nil {
+		svc.Probes = defaults.Probes
+	}
+	if svc.Autoscaling == nil {
+		svc.Autoscaling = defaults.Autoscaling
+	}
+	if svc.DisruptionBudget == nil {
+		svc.DisruptionBudget = defaults.DisruptionBudget
+	}
+	if svc.Istio == nil {
+		svc.Istio = defaults.Istio
+	}
+	if svc.Transport == nil {
+		svc.Transport = defaults.Transport
+	}
+}
+
+// inheritRouteDefaults validates the keys of svc.Routes and sets the error
+// rate and response size of the routes which omit them in b to the
+// service's.
+func (svc *Service) inheritRouteDefaults(b []byte) error {
+	if len(svc.Routes) == 0 {
+		return nil
+	}
+	var explicit struct {
+		Routes map[string]struct {
+			ErrorRate    json.RawMessage `json:"errorRate"`
+			ResponseSize json.RawMessage `json:"responseSize"`
+		} `json:"routes"`
+	}
+	if err := json.Unmarshal(b, &explicit); err != nil {
+		return err
+	}
+	for key, route := range svc.Routes {
+		if _, _, err := ParseRouteKey(key); err != nil {
+			return err
+		}
+		if explicit.Routes[key].ErrorRate == nil {
+			route.ErrorRate = svc.ErrorRate
+		}
+		if explicit.Routes[key].ResponseSize == nil {
+			route.ResponseSize = svc.ResponseSize
+		}
+		svc.Routes[key] = route
+	}
+	return nil
+}
+
 // ErrEmptyName is returned when attempting to parse JSON without an empty name
 // field.
 var ErrEmptyName = errors.New("services must have a name")
+
+// ErrNegativeBackgroundCPU is returned when the background CPU load of a
+// service is negative.
+var ErrNegativeBackgroundCPU = errors.New("backgroundCPU must be non-negative")
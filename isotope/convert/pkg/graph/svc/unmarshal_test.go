@@ -47,6 +47,26 @@ func TestService_UnmarshalJSON(t *testing.T) {
 			Service{Type: svctype.ServiceHTTP, NumReplicas: 1},
 			ErrEmptyName,
 		},
+		{
+			[]byte(`{"name": "A", "backgroundCPU": 0.5}`),
+			Service{
+				Name:          "A",
+				Type:          svctype.ServiceHTTP,
+				NumReplicas:   1,
+				BackgroundCPU: 0.5,
+			},
+			nil,
+		},
+		{
+			[]byte(`{"name": "A", "backgroundCPU": -1}`),
+			Service{
+				Name:          "A",
+				Type:          svctype.ServiceHTTP,
+				NumReplicas:   1,
+				BackgroundCPU: -1,
+			},
+			ErrNegativeBackgroundCPU,
+		},
 	}
 
 	for _, test := range tests {
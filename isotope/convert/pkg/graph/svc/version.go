@@ -0,0 +1,125 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"istio.io/tools/isotope/convert/pkg/graph/pct"
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+	"istio.io/tools/isotope/convert/pkg/graph/size"
+)
+
+// Version is a version of a service, e.g. a canary, deployed with its own
+// replicas and behaviour behind the service. Omitted settings default to the
+// service's.
+type Version struct {
+	// Name identifies the version, e.g. "v2", and is the value of the
+	// "version" label of its replicas.
+	Name string `json:"name"`
+
+	// NumReplicas is the number of replicas backing this version.
+	NumReplicas int32 `json:"numReplicas"`
+
+	// ErrorRate is the percentage chance between 0 and 1 that this version
+	// fails a request.
+	ErrorRate pct.Percentage `json:"errorRate"`
+
+	// ResponseSize is the number of bytes in the response body.
+	ResponseSize size.ByteSize `json:"responseSize"`
+
+	// Script is sequentially called each time this version is called.
+	Script script.Script `json:"script"`
+}
+
+// WithVersion returns svc behaving as its version with the given name, or svc
+// itself if name is empty.
+func (svc Service) WithVersion(name string) (Service, error) {
+	if name == "" {
+		return svc, nil
+	}
+	for _, version := range svc.Versions {
+		if version.Name == name {
+			svc.NumReplicas = version.NumReplicas
+			svc.ErrorRate = version.ErrorRate
+			svc.ResponseSize = version.ResponseSize
+			svc.Script = version.Script
+			return svc, nil
+		}
+	}
+	return Service{}, fmt.Errorf("service %s has no version %q", svc.Name, name)
+}
+
+// inheritVersionDefaults validates svc.Versions, and the traffic split between
+// them, and sets the settings of the versions which omit them in b to the
+// service's.
+func (svc *Service) inheritVersionDefaults(b []byte) error {
+	var explicit struct {
+		Versions []struct {
+			NumReplicas  json.RawMessage `json:"numReplicas"`
+			ErrorRate    json.RawMessage `json:"errorRate"`
+			ResponseSize json.RawMessage `json:"responseSize"`
+			Script       json.RawMessage `json:"script"`
+		} `json:"versions"`
+	}
+	if err := json.Unmarshal(b, &explicit); err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(svc.Versions))
+	for i := range svc.Versions {
+		version := &svc.Versions[i]
+		if errs := validation.IsDNS1123Label(version.Name); len(errs) > 0 {
+			return InvalidVersionError{version.Name, errs[0]}
+		}
+		if names[version.Name] {
+			return InvalidVersionError{version.Name, "duplicate name"}
+		}
+		names[version.Name] = true
+		if explicit.Versions[i].NumReplicas == nil {
+			version.NumReplicas = svc.NumReplicas
+		}
+		if explicit.Versions[i].ErrorRate == nil {
+			version.ErrorRate = svc.ErrorRate
+		}
+		if explicit.Versions[i].ResponseSize == nil {
+			version.ResponseSize = svc.ResponseSize
+		}
+		if explicit.Versions[i].Script == nil {
+			version.Script = svc.Script
+		}
+	}
+	if svc.Istio != nil {
+		for name := range svc.Istio.TrafficSplit {
+			if !names[name] {
+				return InvalidVersionError{name, "is in istio.trafficSplit but not in versions"}
+			}
+		}
+	}
+	return nil
+}
+
+// InvalidVersionError is returned when a version of a service is
+// misconfigured.
+type InvalidVersionError struct {
+	Version string
+	Reason  string
+}
+
+func (e InvalidVersionError) Error() string {
+	return fmt.Sprintf("invalid version %q: %s", e.Version, e.Reason)
+}
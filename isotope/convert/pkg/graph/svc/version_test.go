@@ -0,0 +1,105 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+	"istio.io/tools/isotope/convert/pkg/graph/svctype"
+)
+
+func TestService_UnmarshalJSON_Versions(t *testing.T) {
+	input := []byte(`{
+		"name": "a",
+		"numReplicas": 2,
+		"errorRate": 0.1,
+		"responseSize": 128,
+		"script": [{"sleep": "10ms"}],
+		"versions": [
+			{"name": "v1"},
+			{"name": "v2", "numReplicas": 1, "errorRate": 0, "script": []}
+		]
+	}`)
+	sleep := script.Script{script.SleepCommand(10 * time.Millisecond)}
+	expected := Service{
+		Name:         "a",
+		Type:         svctype.ServiceHTTP,
+		NumReplicas:  2,
+		ErrorRate:    0.1,
+		ResponseSize: 128,
+		Script:       sleep,
+		Versions: []Version{
+			{Name: "v1", NumReplicas: 2, ErrorRate: 0.1, ResponseSize: 128, Script: sleep},
+			{Name: "v2", NumReplicas: 1, ErrorRate: 0, ResponseSize: 128, Script: script.Script{}},
+		},
+	}
+
+	var service Service
+	if err := json.Unmarshal(input, &service); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, service) {
+		t.Errorf("expected %v; actual %v", expected, service)
+	}
+
+	v2, err := service.WithVersion("v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.NumReplicas != 1 || v2.ErrorRate != 0 || len(v2.Script) != 0 {
+		t.Errorf("expected %v; actual %v", expected.Versions[1], v2)
+	}
+	if _, err := service.WithVersion("v3"); err == nil {
+		t.Errorf("expected error; actual %v", err)
+	}
+
+	err = json.Unmarshal([]byte(`{"name": "a", "versions": [{"name": "V_1"}]}`), &service)
+	if err, ok := err.(InvalidVersionError); !ok || err.Version != "V_1" {
+		t.Errorf("expected invalid version V_1; actual %v", err)
+	}
+}
+
+func TestService_UnmarshalJSON_InvalidVersions(t *testing.T) {
+	tests := []struct {
+		input []byte
+		err   error
+	}{
+		{
+			[]byte(`{"name": "a", "versions": [{"name": "v1"}, {"name": "v1"}]}`),
+			InvalidVersionError{"v1", "duplicate name"},
+		},
+		{
+			[]byte(`{"name": "a", "versions": [{"name": "v1"}], "istio": {"trafficSplit": {"v1": 50, "v2": 50}}}`),
+			InvalidVersionError{"v2", "is in istio.trafficSplit but not in versions"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			var service Service
+			err := json.Unmarshal(test.input, &service)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+		})
+	}
+}
@@ -15,9 +15,15 @@
 package graph
 
 import (
+	"bytes"
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"sync"
 
+	"sigs.k8s.io/yaml"
+
+	"istio.io/tools/isotope/convert/pkg/graph/dist"
 	"istio.io/tools/isotope/convert/pkg/graph/pct"
 	"istio.io/tools/isotope/convert/pkg/graph/script"
 	"istio.io/tools/isotope/convert/pkg/graph/size"
@@ -25,6 +31,40 @@ import (
 	"istio.io/tools/isotope/convert/pkg/graph/svctype"
 )
 
+// ReadServiceGraphFile reads the ServiceGraph in the YAML file at path. The
+// relative files it refers to, like those of empirical distributions, are
+// read from the directory of path.
+func ReadServiceGraphFile(path string) (ServiceGraph, error) {
+	graphYAML, err := os.ReadFile(path)
+	if err != nil {
+		return ServiceGraph{}, err
+	}
+	return UnmarshalServiceGraphYAML(graphYAML, filepath.Dir(path))
+}
+
+// UnmarshalServiceGraphYAML unmarshals the ServiceGraph in graphYAML, whose
+// relative files, like those of empirical distributions, are read from dir.
+func UnmarshalServiceGraphYAML(graphYAML []byte, dir string) (sg ServiceGraph, err error) {
+	graphJSON, err := yaml.YAMLToJSON(graphYAML)
+	if err != nil {
+		return
+	}
+	// Numbers are kept as they are, e.g. seeds too large for a float64.
+	decoder := json.NewDecoder(bytes.NewReader(graphJSON))
+	decoder.UseNumber()
+	var doc interface{}
+	if err = decoder.Decode(&doc); err != nil {
+		return
+	}
+	dist.ResolveFiles(doc, dir)
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(b, &sg)
+	return
+}
+
 // UnmarshalJSON converts b into a valid ServiceGraph. See validate() for the
 // details on what it means to be "valid".
 func (g *ServiceGraph) UnmarshalJSON(b []byte) (err error) {
@@ -38,6 +78,11 @@ func (g *ServiceGraph) UnmarshalJSON(b []byte) (err error) {
 	if err != nil {
 		return
 	}
+	for i := range g.Services {
+		if g.Services[i].Seed == nil {
+			g.Services[i].Seed = g.Seed
+		}
+	}
 
 	err = validate(*g)
 	if err != nil {
@@ -76,12 +121,20 @@ type serviceGraphJSONMetadata struct {
 }
 
 type defaults struct {
-	Type         svctype.ServiceType `json:"type"`
-	ErrorRate    pct.Percentage      `json:"errorRate"`
-	ResponseSize size.ByteSize       `json:"responseSize"`
-	Script       script.Script       `json:"script"`
-	RequestSize  size.ByteSize       `json:"requestSize"`
-	NumReplicas  int32               `json:"numReplicas"`
+	Type             svctype.ServiceType   `json:"type"`
+	ErrorRate        pct.Percentage        `json:"errorRate"`
+	ErrorInjection   *svc.ErrorInjection   `json:"errorInjection"`
+	ResponseSize     size.ByteSize         `json:"responseSize"`
+	Script           script.Script         `json:"script"`
+	RequestSize      size.ByteSize         `json:"requestSize"`
+	NumReplicas      int32                 `json:"numReplicas"`
+	BackgroundCPU    float64               `json:"backgroundCPU"`
+	Resources        *svc.Resources        `json:"resources"`
+	Probes           *svc.Probes           `json:"probes"`
+	Autoscaling      *svc.Autoscaling      `json:"autoscaling"`
+	DisruptionBudget *svc.DisruptionBudget `json:"disruptionBudget"`
+	Istio            *svc.Istio            `json:"istio"`
+	Transport        *svc.Transport        `json:"transport"`
 }
 
 func withGlobalDefaults(defaults defaults, f func()) {
@@ -89,11 +142,19 @@ func withGlobalDefaults(defaults defaults, f func()) {
 
 	origDefaultService := svc.DefaultService
 	svc.DefaultService = svc.Service{
-		Type:         defaults.Type,
-		NumReplicas:  defaults.NumReplicas,
-		ErrorRate:    defaults.ErrorRate,
-		ResponseSize: defaults.ResponseSize,
-		Script:       defaults.Script,
+		Type:             defaults.Type,
+		NumReplicas:      defaults.NumReplicas,
+		ErrorRate:        defaults.ErrorRate,
+		ErrorInjection:   defaults.ErrorInjection,
+		ResponseSize:     defaults.ResponseSize,
+		Script:           defaults.Script,
+		BackgroundCPU:    defaults.BackgroundCPU,
+		Resources:        defaults.Resources,
+		Probes:           defaults.Probes,
+		Autoscaling:      defaults.Autoscaling,
+		DisruptionBudget: defaults.DisruptionBudget,
+		Istio:            defaults.Istio,
+		Transport:        defaults.Transport,
 	}
 
 	origDefaultRequestCommand := script.DefaultRequestCommand
@@ -16,10 +16,13 @@ package graph
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
+	"istio.io/tools/isotope/convert/pkg/graph/dist"
 	"istio.io/tools/isotope/convert/pkg/graph/script"
 	"istio.io/tools/isotope/convert/pkg/graph/svc"
 
@@ -34,15 +37,31 @@ func TestServiceGraph_UnmarshalJSON(t *testing.T) {
 	}{
 		{jsonWithOneService, graphWithOneService, nil},
 		{jsonWithDefaultsAndManyServices, graphWithDefaultsAndManyServices, nil},
+		{jsonWithSeeds, graphWithSeeds, nil},
 		{
 			jsonWithRequestToUndefinedService,
 			ServiceGraph{},
 			ErrRequestToUndefinedService{"b"},
 		},
 		{
-			jsonWithNestedConcurrentCommand,
+			jsonWithFallbackToUndefinedService,
 			ServiceGraph{},
-			ErrNestedConcurrentCommand,
+			ErrRequestToUndefinedService{"c"},
+		},
+		{
+			jsonWithRouteToUndefinedService,
+			ServiceGraph{},
+			ErrRequestToUndefinedService{"c"},
+		},
+		{
+			jsonWithNestedRequestToUndefinedService,
+			ServiceGraph{},
+			ErrRequestToUndefinedService{"c"},
+		},
+		{
+			jsonWithFanoutToUndefinedService,
+			ServiceGraph{},
+			ErrRequestToUndefinedService{"c"},
 		},
 	}
 
@@ -72,7 +91,7 @@ var (
 			"services": [{"name": "a"}]
 		}
 	`)
-	graphWithOneService = ServiceGraph{[]svc.Service{
+	graphWithOneService = ServiceGraph{Services: []svc.Service{
 		{
 			Name:        "a",
 			Type:        svctype.ServiceHTTP,
@@ -124,7 +143,7 @@ var (
 			]
 		}
 	`)
-	graphWithDefaultsAndManyServices = ServiceGraph{[]svc.Service{
+	graphWithDefaultsAndManyServices = ServiceGraph{Services: []svc.Service{
 		{
 			Name:         "a",
 			Type:         svctype.ServiceHTTP,
@@ -161,6 +180,29 @@ var (
 			}),
 		},
 	}}
+	jsonWithSeeds = []byte(`
+		{
+			"seed": 7,
+			"services": [{"name": "a"}, {"name": "b", "seed": 0}]
+		}
+	`)
+	graphWithSeeds = ServiceGraph{
+		Services: []svc.Service{
+			{
+				Name:        "a",
+				Type:        svctype.ServiceHTTP,
+				NumReplicas: 1,
+				Seed:        seed(7),
+			},
+			{
+				Name:        "b",
+				Type:        svctype.ServiceHTTP,
+				NumReplicas: 1,
+				Seed:        seed(0),
+			},
+		},
+		Seed: seed(7),
+	}
 	jsonWithRequestToUndefinedService = []byte(`
 		{
 			"services": [
@@ -171,7 +213,51 @@ var (
 			]
 		}
 	`)
-	jsonWithNestedConcurrentCommand = []byte(`
+	jsonWithFallbackToUndefinedService = []byte(`
+		{
+			"services": [
+				{
+					"name": "a",
+					"script": [{ "call": {"service": "b", "onError": "fallback:c"}}]
+				},
+				{
+					"name": "b"
+				}
+			]
+		}
+	`)
+	jsonWithRouteToUndefinedService = []byte(`
+		{
+			"services": [
+				{
+					"name": "a",
+					"routes": {
+						"/checkout": {"script": [{ "call": "c" }]}
+					}
+				}
+			]
+		}
+	`)
+	jsonWithFanoutToUndefinedService = []byte(`
+		{
+			"services": [
+				{
+					"name": "a",
+					"script": [
+						{
+							"repeat": {
+								"count": 2,
+								"body": [
+									{ "fanout": { "service": "c", "count": 3 } }
+								]
+							}
+						}
+					]
+				}
+			]
+		}
+	`)
+	jsonWithNestedRequestToUndefinedService = []byte(`
 		{
 			"services": [
 				{
@@ -181,11 +267,16 @@ var (
 					"name": "b",
 					"script": [
 						[
-							[
-								{ "call": { "service" : "a"}}, 
-								{ "call": {"service" : "a", "hostname": "a:8080"}}
-							],
-							{ "sleep": "20ms" }
+							{ "call": "a" },
+							{
+								"sequence": [
+									{ "sleep": "20ms" },
+									[
+										{ "call": "a" },
+										{ "call": "c" }
+									]
+								]
+							}
 						]
 					]
 				}
@@ -193,3 +284,41 @@ var (
 		}
 	`)
 )
+
+func seed(s uint64) *uint64 {
+	return &s
+}
+
+func TestReadServiceGraphFile(t *testing.T) {
+	// The histogram is next to the graph, not in the working directory.
+	dir := t.TempDir()
+	files := map[string]string{
+		"histogram.txt": "5ms 1\n",
+		"service-graph.yaml": `
+seed: 18446744073709551615
+services:
+- name: a
+  script:
+  - sleep: {distribution: empirical, file: histogram.txt}
+`,
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g, err := ReadServiceGraphFile(filepath.Join(dir, "service-graph.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Seed == nil || *g.Seed != 18446744073709551615 {
+		t.Errorf("expected seed %v; actual %v", uint64(18446744073709551615), g.Seed)
+	}
+	expected := script.Script{script.SleepDistributionCommand{
+		Distribution: dist.Empirical{Buckets: []dist.Bucket{{Value: 5 * time.Millisecond, Weight: 1}}},
+	}}
+	if !reflect.DeepEqual(expected, g.Services[0].Script) {
+		t.Errorf("expected %v; actual %v", expected, g.Services[0].Script)
+	}
+}
@@ -15,7 +15,6 @@
 package graph
 
 import (
-	"errors"
 	"fmt"
 
 	"istio.io/tools/isotope/convert/pkg/graph/script"
@@ -23,8 +22,9 @@ import (
 
 // validate returns nil if g is valid.
 // g is valid if a ServiceGraph:
-// - Each of its services only makes requests to other defined services.
-// - ConcurrentCommands do not contain other ConcurrentCommands.
+// - Each of its services only makes requests to other defined services,
+// including fallback services, at any depth of nested commands.
+// The rule applies to the scripts of each route and version as well.
 func validate(g ServiceGraph) error {
 	svcNames := map[string]bool{}
 	for _, svc := range g.Services {
@@ -34,6 +34,16 @@ func validate(g ServiceGraph) error {
 		if err := validateCommands(svc.Script, svcNames); err != nil {
 			return err
 		}
+		for _, route := range svc.Routes {
+			if err := validateCommands(route.Script, svcNames); err != nil {
+				return err
+			}
+		}
+		for _, version := range svc.Versions {
+			if err := validateCommands(version.Script, svcNames); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -42,28 +52,44 @@ func validateCommands(cmds []script.Command, svcNames map[string]bool) error {
 	for _, cmd := range cmds {
 		switch cmd := cmd.(type) {
 		case script.RequestCommand:
-			if !svcNames[cmd.ServiceName] {
-				return ErrRequestToUndefinedService{cmd.ServiceName}
+			if err := validateRequestCommand(cmd, svcNames); err != nil {
+				return err
 			}
 		case script.ConcurrentCommand:
 			if err := validateCommands(cmd, svcNames); err != nil {
 				return err
 			}
-			if containsConcurrentCommand([]script.Command(cmd)) {
-				return ErrNestedConcurrentCommand
+		case script.SequenceCommand:
+			if err := validateCommands(cmd, svcNames); err != nil {
+				return err
+			}
+		case script.RepeatCommand:
+			if err := validateCommands(cmd.Body, svcNames); err != nil {
+				return err
+			}
+		case script.ChooseCommand:
+			for _, branch := range cmd {
+				if err := validateCommands(branch.Script, svcNames); err != nil {
+					return err
+				}
+			}
+		case script.FanoutCommand:
+			if err := validateRequestCommand(cmd.Call, svcNames); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
-func containsConcurrentCommand(cmds []script.Command) bool {
-	for _, cmd := range cmds {
-		if _, ok := cmd.(script.ConcurrentCommand); ok {
-			return true
-		}
+func validateRequestCommand(cmd script.RequestCommand, svcNames map[string]bool) error {
+	if !svcNames[cmd.ServiceName] {
+		return ErrRequestToUndefinedService{cmd.ServiceName}
+	}
+	if fallback, ok := cmd.OnError.Fallback(); ok && !svcNames[fallback] {
+		return ErrRequestToUndefinedService{fallback}
 	}
-	return false
+	return nil
 }
 
 // ErrRequestToUndefinedService is returned when a RequestCommand has a
@@ -75,8 +101,3 @@ type ErrRequestToUndefinedService struct {
 func (e ErrRequestToUndefinedService) Error() string {
 	return fmt.Sprintf(`cannot call undefined service "%s"`, e.ServiceName)
 }
-
-// ErrNestedConcurrentCommand is returned when a ConcurrentCommand contains
-// a ConcurrentCommand.
-var ErrNestedConcurrentCommand = errors.New(
-	"concurrent commands may not be nested")
@@ -18,6 +18,7 @@ package graphviz
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"text/template"
 
 	"istio.io/tools/isotope/convert/pkg/graph"
@@ -29,7 +30,14 @@ import (
 // string.
 func ServiceGraphToDotLanguage(
 	serviceGraph graph.ServiceGraph) (string, error) {
-	graph, err := ServiceGraphToGraph(serviceGraph)
+	return ServiceGraphToDotLanguageWithOptions(serviceGraph, Options{})
+}
+
+// ServiceGraphToDotLanguageWithOptions converts a ServiceGraph to a Graphviz
+// DOT language string, customised by opts.
+func ServiceGraphToDotLanguageWithOptions(
+	serviceGraph graph.ServiceGraph, opts Options) (string, error) {
+	graph, err := ServiceGraphToGraphWithOptions(serviceGraph, opts)
 	if err != nil {
 		return "", err
 	}
@@ -58,22 +66,62 @@ func GraphToDotLanguage(g Graph) (string, error) {
 
 // ServiceGraphToGraph converts a service graph to a graphviz graph.
 func ServiceGraphToGraph(sg graph.ServiceGraph) (Graph, error) {
+	return ServiceGraphToGraphWithOptions(sg, Options{})
+}
+
+// ServiceGraphToGraphWithOptions converts a service graph to a graphviz graph,
+// customised by opts.
+func ServiceGraphToGraphWithOptions(sg graph.ServiceGraph, opts Options) (Graph, error) {
 	nodes := make([]Node, 0, len(sg.Services))
 	edges := make([]Edge, 0, len(sg.Services))
 	for _, service := range sg.Services {
-		node, connections, err := toGraphvizNode(service)
+		node, connections, err := toGraphvizNode(service, opts)
 		if err != nil {
 			return Graph{}, err
 		}
 		nodes = append(nodes, node)
 		edges = append(edges, connections...)
 	}
-	return Graph{
+	g := Graph{
 		Nodes: nodes,
 		Edges: edges,
-	}, nil
+	}
+	if opts.CollapseReplicas {
+		g = collapseReplicas(g, sg.Services)
+	}
+	if opts.ColorBy != ColorByNone {
+		if err := colorNodes(g, sg.Services, opts.ColorBy); err != nil {
+			return Graph{}, err
+		}
+	}
+	return g, nil
+}
+
+// Options customise the conversion of a service graph to a graphviz graph.
+type Options struct {
+	// CollapseReplicas draws services which only differ by name as one node.
+	CollapseReplicas bool
+
+	// ColorBy colours nodes by the namespace or cluster of their service.
+	ColorBy ColorBy
+
+	// EdgeLabels labels edges with the size and probability of their requests.
+	EdgeLabels bool
 }
 
+// ColorBy is the attribute of a service which determines the colour of its
+// node.
+type ColorBy string
+
+const (
+	// ColorByNone leaves nodes uncoloured.
+	ColorByNone ColorBy = ""
+	// ColorByNamespace colours nodes by the namespace of their service.
+	ColorByNamespace ColorBy = "namespace"
+	// ColorByCluster colours nodes by the cluster of their service.
+	ColorByCluster ColorBy = "cluster"
+)
+
 // Graph represents a Graphviz graph.
 type Graph struct {
 	Nodes []Node
@@ -87,6 +135,19 @@ type Node struct {
 	ErrorRate    string
 	ResponseSize string
 	Steps        [][]string
+
+	// Collapsed is the number of other services drawn as this node.
+	Collapsed int
+	// Color is the background colour of the node's header, if any.
+	Color string
+}
+
+// Title is the text naming the node.
+func (n Node) Title() string {
+	if n.Collapsed > 0 {
+		return fmt.Sprintf("%s (+%d)", n.Name, n.Collapsed)
+	}
+	return n.Name
 }
 
 // Edge represents a directed edge in the Graphviz graph.
@@ -94,6 +155,7 @@ type Edge struct {
 	From      string
 	To        string
 	StepIndex int
+	Label     string
 }
 
 const graphvizTemplate = `digraph {
@@ -106,7 +168,7 @@ const graphvizTemplate = `digraph {
   {{ range .Nodes -}}
   "{{ .Name }}" [label=<
 <TABLE BORDER="0" CELLBORDER="1" CELLSPACING="0">
-  <TR><TD><B>{{ .Name }}</B><BR />Type: {{ .Type }}<BR />Err: {{ .ErrorRate }}</TD></TR>
+  <TR><TD{{ if .Color }} BGCOLOR="{{ .Color }}"{{ end }}><B>{{ .Title }}</B><BR />Type: {{ .Type }}<BR />Err: {{ .ErrorRate }}</TD></TR>
   {{- range $i, $cmds := .Steps }}
   <TR><TD PORT="{{ $i }}">
   {{- range $j, $cmd := $cmds -}}
@@ -121,42 +183,102 @@ const graphvizTemplate = `digraph {
 
   {{- range .Edges }}
   "{{ .From -}}":{{- .StepIndex }} -> "{{ .To }}"
+  {{- if .Label }} [label="{{ .Label }}"]{{ end }}
   {{- end }}
 }
 `
 
 func getEdgesFromExe(
-	exe script.Command, idx int, fromServiceName string) (edges []Edge) {
+	exe script.Command, idx int, fromServiceName string, opts Options) (edges []Edge) {
 	switch cmd := exe.(type) {
 	case script.ConcurrentCommand:
 		for _, subCmd := range cmd {
-			subEdges := getEdgesFromExe(subCmd, idx, fromServiceName)
+			subEdges := getEdgesFromExe(subCmd, idx, fromServiceName, opts)
 			edges = append(edges, subEdges...)
 		}
+	case script.SequenceCommand:
+		for _, subCmd := range cmd {
+			subEdges := getEdgesFromExe(subCmd, idx, fromServiceName, opts)
+			edges = append(edges, subEdges...)
+		}
+	case script.RepeatCommand:
+		for _, subCmd := range cmd.Body {
+			subEdges := getEdgesFromExe(subCmd, idx, fromServiceName, opts)
+			edges = append(edges, subEdges...)
+		}
+	case script.ChooseCommand:
+		for _, branch := range cmd {
+			for _, subCmd := range branch.Script {
+				subEdges := getEdgesFromExe(subCmd, idx, fromServiceName, opts)
+				edges = append(edges, subEdges...)
+			}
+		}
+	case script.FanoutCommand:
+		for _, e := range getEdgesFromExe(cmd.Call, idx, fromServiceName, opts) {
+			if e.Label != "" {
+				e.Label = fmt.Sprintf("%dx %s", cmd.Count, e.Label)
+			}
+			edges = append(edges, e)
+		}
 	case script.RequestCommand:
 		e := Edge{
 			From:      fromServiceName,
 			To:        cmd.ServiceName,
 			StepIndex: idx,
 		}
+		if opts.EdgeLabels {
+			e.Label = edgeLabel(cmd)
+		}
 		edges = append(edges, e)
+		if fallback, ok := cmd.FallbackCommand(); ok {
+			edges = append(edges, getEdgesFromExe(fallback, idx, fromServiceName, opts)...)
+		}
 	}
 	return
 }
 
-func toGraphvizNode(service svc.Service) (Node, []Edge, error) {
+// edgeLabel describes the size and, unless it is always sent, the probability
+// of the request sent by cmd.
+func edgeLabel(cmd script.RequestCommand) string {
+	if cmd.Probability == 0 {
+		return cmd.Size.String()
+	}
+	return fmt.Sprintf("%s %d%%", cmd.Size, cmd.Probability)
+}
+
+func toGraphvizNode(service svc.Service, opts Options) (Node, []Edge, error) {
 	steps := make([][]string, 0, len(service.Script))
 	edges := make([]Edge, 0, len(service.Script))
-	for idx, exe := range service.Script {
-		step, err := executableToStringSlice(exe)
-		if err != nil {
-			return Node{}, nil, err
+	appendScript := func(s script.Script) error {
+		for _, exe := range s {
+			step, err := executableToStringSlice(exe)
+			if err != nil {
+				return err
+			}
+			stepEdges := getEdgesFromExe(exe, len(steps), service.Name, opts)
+			edges = append(edges, stepEdges...)
+			steps = append(steps, step)
 		}
-		steps = append(steps, step)
+		return nil
+	}
 
-		stepEdges := getEdgesFromExe(exe, idx, service.Name)
-		edges = append(edges, stepEdges...)
+	if err := appendScript(service.Script); err != nil {
+		return Node{}, nil, err
 	}
+	routeKeys := make([]string, 0, len(service.Routes))
+	for key := range service.Routes {
+		routeKeys = append(routeKeys, key)
+	}
+	sort.Strings(routeKeys)
+	for _, key := range routeKeys {
+		route := service.Routes[key]
+		steps = append(steps, []string{fmt.Sprintf(
+			"ROUTE %s (Err: %s)", key, route.ErrorRate.String())})
+		if err := appendScript(route.Script); err != nil {
+			return Node{}, nil, err
+		}
+	}
+
 	n := Node{
 		Name:         service.Name,
 		Type:         service.Type.String(),
@@ -171,43 +293,105 @@ func nonConcurrentCommandToString(exe script.Command) (string, error) {
 	switch cmd := exe.(type) {
 	case script.SleepCommand:
 		return fmt.Sprintf("SLEEP %s", cmd), nil
+	case script.SleepDistributionCommand:
+		return fmt.Sprintf("SLEEP %s", cmd), nil
+	case script.ComputeCommand:
+		return fmt.Sprintf("COMPUTE %s", cmd), nil
+	case script.AllocateCommand:
+		return fmt.Sprintf("ALLOCATE %s", cmd), nil
+	case script.FanoutCommand:
+		call, err := nonConcurrentCommandToString(cmd.Call)
+		if err != nil {
+			return "", err
+		}
+		s := fmt.Sprintf("FANOUT %d", cmd.Count)
+		if cmd.Concurrency > 0 {
+			s += fmt.Sprintf(" (CONCURRENCY %d)", cmd.Concurrency)
+		}
+		return s + " " + call, nil
 	case script.RequestCommand:
-		return fmt.Sprintf(
-			"CALL \"%s\" %s",
-			cmd.ServiceName, cmd.Size.String()), nil
+		s := fmt.Sprintf("CALL \"%s\"", cmd.ServiceName)
+		if cmd.Method != "" || cmd.Path != "" {
+			s += fmt.Sprintf(" %s %s", cmd.HTTPMethod(), cmd.Path)
+		}
+		s += " " + cmd.Size.String()
+		if cmd.Timeout > 0 {
+			s += fmt.Sprintf(" TIMEOUT %s", cmd.Timeout)
+		}
+		if cmd.Retries != nil && cmd.Retries.Attempts > 0 {
+			s += fmt.Sprintf(" RETRY %d", cmd.Retries.Attempts)
+		}
+		if cmd.OnError != "" {
+			s += fmt.Sprintf(" ON ERROR %s", cmd.OnError)
+		}
+		return s, nil
 	default:
 		return "", fmt.Errorf("unexpected type of executable %T", exe)
 	}
 }
 
+// nestingPrefix marks each level of nesting of the lines of a step.
+const nestingPrefix = "| "
+
+// executableToStringSlice returns the lines describing a step. The commands of
+// a top-level ConcurrentCommand are listed one per line; other commands
+// holding commands are headed by their kind and their commands prefixed by
+// nestingPrefix.
 func executableToStringSlice(exe script.Command) ([]string, error) {
-	slice := make([]string, 0, 1)
-	appendNonConcurrentExe := func(exe script.Command) error {
-		s, err := nonConcurrentCommandToString(exe)
-		if err != nil {
-			return err
+	if cmd, ok := exe.(script.ConcurrentCommand); ok {
+		slice := make([]string, 0, len(cmd))
+		for _, exe := range cmd {
+			lines, err := commandToLines(exe, "")
+			if err != nil {
+				return nil, err
+			}
+			slice = append(slice, lines...)
 		}
-		slice = append(slice, s)
-		return nil
+		return slice, nil
 	}
+	return commandToLines(exe, "")
+}
 
+func commandToLines(exe script.Command, prefix string) ([]string, error) {
+	var header string
+	var cmds []script.Command
 	switch cmd := exe.(type) {
-	case script.SleepCommand:
-		if err := appendNonConcurrentExe(exe); err != nil {
-			return nil, err
-		}
-	case script.RequestCommand:
-		if err := appendNonConcurrentExe(exe); err != nil {
-			return nil, err
-		}
 	case script.ConcurrentCommand:
-		for _, exe := range cmd {
-			if err := appendNonConcurrentExe(exe); err != nil {
-				return nil, err
+		header, cmds = "CONCURRENT", cmd
+	case script.SequenceCommand:
+		header, cmds = "SEQUENCE", cmd
+	case script.RepeatCommand:
+		header, cmds = fmt.Sprintf("REPEAT %s", cmd), cmd.Body
+	case script.ChooseCommand:
+		lines := []string{prefix + "CHOOSE"}
+		for i, branch := range cmd {
+			branchPrefix := prefix + nestingPrefix
+			lines = append(lines, fmt.Sprintf(
+				"%sBRANCH %.0f%%", branchPrefix, 100*cmd.Probability(i)))
+			for _, exe := range branch.Script {
+				subLines, err := commandToLines(exe, branchPrefix+nestingPrefix)
+				if err != nil {
+					return nil, err
+				}
+				lines = append(lines, subLines...)
 			}
 		}
+		return lines, nil
 	default:
-		return nil, fmt.Errorf("unexpected type of executable %T", exe)
+		s, err := nonConcurrentCommandToString(exe)
+		if err != nil {
+			return nil, err
+		}
+		return []string{prefix + s}, nil
+	}
+
+	lines := []string{prefix + header}
+	for _, exe := range cmds {
+		subLines, err := commandToLines(exe, prefix+nestingPrefix)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, subLines...)
 	}
-	return slice, nil
+	return lines, nil
 }
@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
 	"istio.io/tools/isotope/convert/pkg/graph/script"
 	"istio.io/tools/isotope/convert/pkg/graph/svc"
 	"istio.io/tools/isotope/convert/pkg/graph/svctype"
@@ -175,6 +176,89 @@ func TestServiceGraphToGraph(t *testing.T) {
 	}
 }
 
+func TestExecutableToStringSlice_Nested(t *testing.T) {
+	exe := script.ConcurrentCommand{
+		script.RequestCommand{ServiceName: "a", Size: 1024},
+		script.SequenceCommand{
+			script.RequestCommand{ServiceName: "b", Size: 1024},
+			script.SleepCommand(10 * time.Millisecond),
+			script.ConcurrentCommand{
+				script.RequestCommand{ServiceName: "c", Size: 1024},
+				script.RequestCommand{ServiceName: "d", Size: 1024},
+			},
+		},
+	}
+	expected := []string{
+		"CALL \"a\" 1KiB",
+		"SEQUENCE",
+		"| CALL \"b\" 1KiB",
+		"| SLEEP 10ms",
+		"| CONCURRENT",
+		"| | CALL \"c\" 1KiB",
+		"| | CALL \"d\" 1KiB",
+	}
+	actual, err := executableToStringSlice(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v; actual %v", expected, actual)
+	}
+
+	edges := getEdgesFromExe(exe, 0, "x", Options{})
+	if len(edges) != 4 {
+		t.Errorf("expected 4 edges; actual %v", edges)
+	}
+}
+
+func TestExecutableToStringSlice_Loops(t *testing.T) {
+	exe := script.SequenceCommand{
+		script.RepeatCommand{
+			Count: 3,
+			Body: script.Script{
+				script.ComputeCommand{CPU: duration.Duration(time.Millisecond)},
+				script.AllocateCommand{Bytes: 1024},
+			},
+		},
+		script.ChooseCommand{
+			{Weight: 3, Script: script.Script{script.RequestCommand{ServiceName: "a"}}},
+			{Weight: 1},
+		},
+		script.FanoutCommand{
+			Call:        script.RequestCommand{ServiceName: "b", Size: 1024},
+			Count:       10,
+			Concurrency: 2,
+		},
+	}
+	expected := []string{
+		"SEQUENCE",
+		"| REPEAT 3",
+		"| | COMPUTE 1ms",
+		"| | ALLOCATE 1KiB",
+		"| CHOOSE",
+		"| | BRANCH 75%",
+		"| | | CALL \"a\" 0B",
+		"| | BRANCH 25%",
+		"| FANOUT 10 (CONCURRENCY 2) CALL \"b\" 1KiB",
+	}
+	actual, err := executableToStringSlice(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v; actual %v", expected, actual)
+	}
+
+	edges := getEdgesFromExe(exe, 0, "x", Options{EdgeLabels: true})
+	expectedEdges := []Edge{
+		{From: "x", To: "a", Label: "0B"},
+		{From: "x", To: "b", Label: "10x 1KiB"},
+	}
+	if !reflect.DeepEqual(expectedEdges, edges) {
+		t.Errorf("expected %v; actual %v", expectedEdges, edges)
+	}
+}
+
 func graphsAreEqual(left Graph, right Graph) bool {
 	return reflect.DeepEqual(left, right)
 }
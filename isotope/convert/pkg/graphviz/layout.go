@@ -0,0 +1,317 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphviz
+
+import (
+	"math"
+	"sort"
+)
+
+// Dimensions, in pixels, of the rendered text and layout.
+const (
+	charWidth   = 7
+	lineHeight  = 15
+	cellPadding = 4
+	nodeSep     = 30
+	rankSep     = 60
+	margin      = 20
+	// loopWidth is the room beside a node for edges leaving its steps.
+	loopWidth = 40
+)
+
+type point struct {
+	X, Y float64
+}
+
+// cell is a row of the table drawn for a node.
+type cell struct {
+	Lines []string
+	Y, H  float64
+}
+
+// nodeLayout places a node. X and Y are its top left corner.
+type nodeLayout struct {
+	Node       Node
+	X, Y, W, H float64
+	// Cells are the header of the node followed by a cell per step.
+	Cells []cell
+}
+
+// Center returns the x coordinate of the middle of n.
+func (n nodeLayout) Center() float64 {
+	return n.X + n.W/2
+}
+
+// edgeLayout routes an edge as a cubic Bézier curve.
+type edgeLayout struct {
+	Edge  Edge
+	Curve [4]point
+}
+
+// At returns the point of the curve of e at t in [0, 1].
+func (e edgeLayout) At(t float64) point {
+	u := 1 - t
+	a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+	p := e.Curve
+	return point{
+		X: a*p[0].X + b*p[1].X + c*p[2].X + d*p[3].X,
+		Y: a*p[0].Y + b*p[1].Y + c*p[2].Y + d*p[3].Y,
+	}
+}
+
+// layout is a graph placed for rendering.
+type layout struct {
+	Nodes         []nodeLayout
+	Edges         []edgeLayout
+	Width, Height float64
+}
+
+// layoutGraph places g top to bottom in layers, such that every edge which is
+// not part of a cycle points to a lower layer, and orders the nodes of each
+// layer to reduce crossings with the barycenter heuristic.
+func layoutGraph(g Graph) layout {
+	index := make(map[string]int, len(g.Nodes))
+	for i, node := range g.Nodes {
+		index[node.Name] = i
+	}
+	succs := make([][]int, len(g.Nodes))
+	linked := make(map[[2]int]bool, len(g.Edges))
+	for _, edge := range g.Edges {
+		from, okFrom := index[edge.From]
+		to, okTo := index[edge.To]
+		if !okFrom || !okTo || linked[[2]int{from, to}] {
+			continue
+		}
+		linked[[2]int{from, to}] = true
+		succs[from] = append(succs[from], to)
+	}
+
+	// Drop the edges closing cycles, found by a depth-first search, and sort
+	// the rest topologically.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(g.Nodes))
+	back := make(map[[2]int]bool)
+	postorder := make([]int, 0, len(g.Nodes))
+	var visit func(u int)
+	visit = func(u int) {
+		state[u] = visiting
+		for _, v := range succs[u] {
+			switch state[v] {
+			case visiting:
+				back[[2]int{u, v}] = true
+			case unvisited:
+				visit(v)
+			}
+		}
+		state[u] = visited
+		postorder = append(postorder, u)
+	}
+	for i := range g.Nodes {
+		if state[i] == unvisited {
+			visit(i)
+		}
+	}
+
+	preds := make([][]int, len(g.Nodes))
+	forward := make([][]int, len(g.Nodes))
+	for u, vs := range succs {
+		for _, v := range vs {
+			if !back[[2]int{u, v}] {
+				forward[u] = append(forward[u], v)
+				preds[v] = append(preds[v], u)
+			}
+		}
+	}
+
+	ranks := make([]int, len(g.Nodes))
+	numLayers := 0
+	for i := len(postorder) - 1; i >= 0; i-- {
+		u := postorder[i]
+		for _, v := range forward[u] {
+			if ranks[u]+1 > ranks[v] {
+				ranks[v] = ranks[u] + 1
+			}
+		}
+	}
+	for _, rank := range ranks {
+		if rank+1 > numLayers {
+			numLayers = rank + 1
+		}
+	}
+	layers := make([][]int, numLayers)
+	for i, rank := range ranks {
+		layers[rank] = append(layers[rank], i)
+	}
+	orderLayers(layers, preds, forward)
+
+	nodes := make([]nodeLayout, len(g.Nodes))
+	for i, node := range g.Nodes {
+		nodes[i] = sizeNode(node)
+	}
+
+	width := 0.0
+	for _, layer := range layers {
+		width = math.Max(width, layerWidth(layer, nodes))
+	}
+	y := float64(margin)
+	for _, layer := range layers {
+		x := margin + loopWidth + (width-layerWidth(layer, nodes))/2
+		height := 0.0
+		for _, i := range layer {
+			nodes[i].place(x, y)
+			x += nodes[i].W + nodeSep
+			height = math.Max(height, nodes[i].H)
+		}
+		y += height + rankSep
+	}
+
+	edges := make([]edgeLayout, 0, len(g.Edges))
+	for _, edge := range g.Edges {
+		from, okFrom := index[edge.From]
+		to, okTo := index[edge.To]
+		if !okFrom || !okTo {
+			continue
+		}
+		edges = append(edges, routeEdge(edge, nodes[from], nodes[to]))
+	}
+
+	return layout{
+		Nodes:  nodes,
+		Edges:  edges,
+		Width:  width + 2*(margin+loopWidth),
+		Height: y - rankSep + margin,
+	}
+}
+
+// orderLayers sorts each layer by the mean position of the neighbours of its
+// nodes in the layers above, then below, a few times over.
+func orderLayers(layers [][]int, preds [][]int, succs [][]int) {
+	position := make(map[int]float64)
+	setPositions := func(layer []int) {
+		for j, i := range layer {
+			position[i] = (float64(j) + 0.5) / float64(len(layer))
+		}
+	}
+	for _, layer := range layers {
+		setPositions(layer)
+	}
+	sortLayer := func(layer []int, neighbours [][]int) {
+		barycenter := make(map[int]float64, len(layer))
+		for _, i := range layer {
+			barycenter[i] = position[i]
+			if len(neighbours[i]) == 0 {
+				continue
+			}
+			sum := 0.0
+			for _, n := range neighbours[i] {
+				sum += position[n]
+			}
+			barycenter[i] = sum / float64(len(neighbours[i]))
+		}
+		sort.SliceStable(layer, func(a, b int) bool {
+			return barycenter[layer[a]] < barycenter[layer[b]]
+		})
+		setPositions(layer)
+	}
+
+	const sweeps = 4
+	for sweep := 0; sweep < sweeps; sweep++ {
+		for l := 1; l < len(layers); l++ {
+			sortLayer(layers[l], preds)
+		}
+		for l := len(layers) - 2; l >= 0; l-- {
+			sortLayer(layers[l], succs)
+		}
+	}
+}
+
+func layerWidth(layer []int, nodes []nodeLayout) float64 {
+	width := 0.0
+	for j, i := range layer {
+		if j > 0 {
+			width += nodeSep
+		}
+		width += nodes[i].W
+	}
+	return width
+}
+
+// sizeNode makes the cells of node and measures them.
+func sizeNode(node Node) nodeLayout {
+	n := nodeLayout{Node: node}
+	n.Cells = append(n.Cells, cell{Lines: []string{
+		node.Title(), "Type: " + node.Type, "Err: " + node.ErrorRate,
+	}})
+	for _, step := range node.Steps {
+		n.Cells = append(n.Cells, cell{Lines: step})
+	}
+	for i, c := range n.Cells {
+		n.Cells[i].H = float64(len(c.Lines)*lineHeight + 2*cellPadding)
+		n.H += n.Cells[i].H
+		for _, line := range c.Lines {
+			n.W = math.Max(n.W, float64(len(line)*charWidth+2*cellPadding))
+		}
+	}
+	return n
+}
+
+// place moves n, and its cells, to x and y.
+func (n *nodeLayout) place(x float64, y float64) {
+	n.X, n.Y = x, y
+	for i := range n.Cells {
+		n.Cells[i].Y = y
+		y += n.Cells[i].H
+	}
+}
+
+// routeEdge leaves the cell of the step of edge on the side facing to and
+// enters to from the top.
+func routeEdge(edge Edge, from nodeLayout, to nodeLayout) edgeLayout {
+	port := from.Cells[0]
+	if edge.StepIndex+1 < len(from.Cells) {
+		port = from.Cells[edge.StepIndex+1]
+	}
+	startY := port.Y + port.H/2
+
+	if from.Node.Name == to.Node.Name {
+		right := from.X + from.W
+		header := from.Cells[0]
+		endY := header.Y + header.H/2
+		return edgeLayout{Edge: edge, Curve: [4]point{
+			{right, startY},
+			{right + loopWidth, startY},
+			{right + loopWidth, endY},
+			{right, endY},
+		}}
+	}
+
+	start := point{from.X + from.W, startY}
+	direction := 1.0
+	if to.Center() < from.Center() {
+		start.X = from.X
+		direction = -1
+	}
+	end := point{to.Center(), to.Y}
+	return edgeLayout{Edge: edge, Curve: [4]point{
+		start,
+		{start.X + direction*loopWidth, start.Y},
+		{end.X, end.Y - rankSep/2},
+		end,
+	}}
+}
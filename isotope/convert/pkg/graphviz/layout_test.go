@@ -0,0 +1,90 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphviz
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+var cyclicGraph = Graph{
+	Nodes: []Node{
+		{Name: "a", Type: "HTTP", Steps: [][]string{{`CALL "b" 1KiB`}, {`CALL "a" 1KiB`}}},
+		{Name: "b", Type: "HTTP", Steps: [][]string{{`CALL "c" 1KiB`}}},
+		{Name: "c", Type: "HTTP", Steps: [][]string{{`CALL "a" 1KiB`}}},
+	},
+	Edges: []Edge{
+		{From: "a", To: "b", StepIndex: 0},
+		{From: "a", To: "a", StepIndex: 1},
+		{From: "b", To: "c", StepIndex: 0},
+		{From: "c", To: "a", StepIndex: 0, Label: "<1KiB>"},
+	},
+}
+
+func TestLayoutGraph(t *testing.T) {
+	l := layoutGraph(cyclicGraph)
+
+	if len(l.Nodes) != 3 || len(l.Edges) != 4 {
+		t.Fatalf("expected 3 nodes and 4 edges; actual %d and %d", len(l.Nodes), len(l.Edges))
+	}
+	a, b, c := l.Nodes[0], l.Nodes[1], l.Nodes[2]
+	if !(a.Y < b.Y && b.Y < c.Y) {
+		t.Errorf("expected a above b above c; actual y %v, %v, %v", a.Y, b.Y, c.Y)
+	}
+	for _, n := range l.Nodes {
+		if n.X < 0 || n.Y < 0 || n.X+n.W > l.Width || n.Y+n.H > l.Height {
+			t.Errorf("node %s at %+v is outside %vx%v", n.Node.Name, n, l.Width, l.Height)
+		}
+		if len(n.Cells) != len(n.Node.Steps)+1 {
+			t.Errorf("expected %d cells for %s; actual %d",
+				len(n.Node.Steps)+1, n.Node.Name, len(n.Cells))
+		}
+	}
+	for _, e := range l.Edges {
+		to := l.Nodes[map[string]int{"a": 0, "b": 1, "c": 2}[e.Edge.To]]
+		if end := e.At(1); end.Y < to.Y || end.Y > to.Y+to.H {
+			t.Errorf("edge %+v ends at %+v, not on %s", e.Edge, end, to.Node.Name)
+		}
+	}
+}
+
+func TestGraphToSVG(t *testing.T) {
+	svg, err := GraphToSVG(cyclicGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<svg", `<g id="a">`, "CALL &#34;b&#34; 1KiB", "&lt;1KiB&gt;"} {
+		if !strings.Contains(string(svg), want) {
+			t.Errorf("expected SVG to contain %q", want)
+		}
+	}
+}
+
+func TestGraphToPNG(t *testing.T) {
+	b, err := GraphToPNG(cyclicGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := layoutGraph(cyclicGraph)
+	if img.Bounds().Dx() < int(l.Width) || img.Bounds().Dy() < int(l.Height) {
+		t.Errorf("expected at least %vx%v; actual %v", l.Width, l.Height, img.Bounds())
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphviz
+
+import (
+	"fmt"
+	"sort"
+
+	"istio.io/tools/isotope/convert/pkg/graph/svc"
+)
+
+// palette holds the colours assigned, in order, to namespaces or clusters.
+var palette = []string{
+	"#8dd3c7", "#ffffb3", "#bebada", "#fb8072", "#80b1d3", "#fdb462",
+	"#b3de69", "#fccde5", "#d9d9d9", "#bc80bd", "#ccebc5", "#ffed6f",
+}
+
+// collapseReplicas merges the nodes of services in the same namespace and
+// cluster which only differ by name into the first of them, and merges the
+// edges which become identical.
+func collapseReplicas(g Graph, services []svc.Service) Graph {
+	placement := make(map[string]string, len(services))
+	for _, service := range services {
+		placement[service.Name] = service.Namespace + "/" + service.Cluster
+	}
+
+	representatives := make(map[string]int, len(g.Nodes))
+	renamed := make(map[string]string, len(g.Nodes))
+	nodes := make([]Node, 0, len(g.Nodes))
+	for _, node := range g.Nodes {
+		key := fmt.Sprintf("%q %q %q %q %q",
+			placement[node.Name], node.Type, node.ErrorRate, node.ResponseSize,
+			node.Steps)
+		if i, ok := representatives[key]; ok {
+			nodes[i].Collapsed++
+			renamed[node.Name] = nodes[i].Name
+			continue
+		}
+		representatives[key] = len(nodes)
+		nodes = append(nodes, node)
+	}
+
+	rename := func(name string) string {
+		if to, ok := renamed[name]; ok {
+			return to
+		}
+		return name
+	}
+	seen := make(map[Edge]bool, len(g.Edges))
+	edges := make([]Edge, 0, len(g.Edges))
+	for _, edge := range g.Edges {
+		edge.From = rename(edge.From)
+		edge.To = rename(edge.To)
+		if seen[edge] {
+			continue
+		}
+		seen[edge] = true
+		edges = append(edges, edge)
+	}
+	return Graph{Nodes: nodes, Edges: edges}
+}
+
+// colorNodes sets the colour of each node of g by the namespace or cluster of
+// its service, as chosen by by.
+func colorNodes(g Graph, services []svc.Service, by ColorBy) error {
+	attribute := func(service svc.Service) string { return service.Namespace }
+	switch by {
+	case ColorByNamespace:
+	case ColorByCluster:
+		attribute = func(service svc.Service) string { return service.Cluster }
+	default:
+		return fmt.Errorf(
+			"cannot color by %q (must be %q or %q)", by, ColorByNamespace, ColorByCluster)
+	}
+
+	attributes := make(map[string]string, len(services))
+	for _, service := range services {
+		attributes[service.Name] = attribute(service)
+	}
+
+	values := make([]string, 0, len(attributes))
+	colors := make(map[string]string)
+	for _, value := range attributes {
+		if _, ok := colors[value]; !ok {
+			colors[value] = ""
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	for i, value := range values {
+		colors[value] = palette[i%len(palette)]
+	}
+
+	for i := range g.Nodes {
+		g.Nodes[i].Color = colors[attributes[g.Nodes[i].Name]]
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphviz
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+	"istio.io/tools/isotope/convert/pkg/graph/svc"
+	"istio.io/tools/isotope/convert/pkg/graph/svctype"
+)
+
+func TestServiceGraphToGraphWithOptions(t *testing.T) {
+	call := func(name string, probability int) script.RequestCommand {
+		return script.RequestCommand{ServiceName: name, Size: 1024, Probability: probability}
+	}
+	serviceGraph := graph.ServiceGraph{
+		Services: []svc.Service{
+			{
+				Name:      "a",
+				Type:      svctype.ServiceHTTP,
+				Namespace: "front",
+				Script: []script.Command{
+					script.ConcurrentCommand{call("b-1", 0), call("b-2", 50)},
+				},
+			},
+			{Name: "b-1", Type: svctype.ServiceHTTP, Namespace: "back"},
+			{Name: "b-2", Type: svctype.ServiceHTTP, Namespace: "back"},
+			{Name: "c", Type: svctype.ServiceHTTP, Namespace: "front"},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		opts  Options
+		nodes []string
+		edges []Edge
+		color map[string]string
+	}{
+		{
+			name:  "default",
+			nodes: []string{"a", "b-1", "b-2", "c"},
+			edges: []Edge{
+				{From: "a", To: "b-1"},
+				{From: "a", To: "b-2"},
+			},
+		},
+		{
+			name:  "collapse replicas",
+			opts:  Options{CollapseReplicas: true},
+			nodes: []string{"a", "b-1 (+1)", "c"},
+			edges: []Edge{
+				{From: "a", To: "b-1"},
+			},
+		},
+		{
+			name:  "edge labels",
+			opts:  Options{EdgeLabels: true},
+			nodes: []string{"a", "b-1", "b-2", "c"},
+			edges: []Edge{
+				{From: "a", To: "b-1", Label: "1KiB"},
+				{From: "a", To: "b-2", Label: "1KiB 50%"},
+			},
+		},
+		{
+			name:  "color by namespace",
+			opts:  Options{ColorBy: ColorByNamespace},
+			nodes: []string{"a", "b-1", "b-2", "c"},
+			edges: []Edge{
+				{From: "a", To: "b-1"},
+				{From: "a", To: "b-2"},
+			},
+			color: map[string]string{
+				"a":   palette[1],
+				"b-1": palette[0],
+				"b-2": palette[0],
+				"c":   palette[1],
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			g, err := ServiceGraphToGraphWithOptions(serviceGraph, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes := make([]string, 0, len(g.Nodes))
+			for _, node := range g.Nodes {
+				nodes = append(nodes, node.Title())
+				if node.Color != test.color[node.Name] {
+					t.Errorf("expected color %q for %s; actual %q",
+						test.color[node.Name], node.Name, node.Color)
+				}
+			}
+			if !reflect.DeepEqual(test.nodes, nodes) {
+				t.Errorf("expected nodes %v; actual %v", test.nodes, nodes)
+			}
+			if !reflect.DeepEqual(test.edges, g.Edges) {
+				t.Errorf("expected edges %+v; actual %+v", test.edges, g.Edges)
+			}
+		})
+	}
+}
+
+func TestServiceGraphToGraphWithOptions_InvalidColorBy(t *testing.T) {
+	_, err := ServiceGraphToGraphWithOptions(graph.ServiceGraph{}, Options{ColorBy: "team"})
+	if err == nil {
+		t.Error("expected an error; actual nil")
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphviz
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// GraphToPNG renders a graphviz graph as a PNG image, laid out in layers
+// without Graphviz.
+func GraphToPNG(g Graph) ([]byte, error) {
+	l := layoutGraph(g)
+
+	img := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(l.Width)), int(math.Ceil(l.Height))))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	text := &font.Drawer{Dst: img, Src: image.Black, Face: basicfont.Face7x13}
+
+	for _, n := range l.Nodes {
+		for i, c := range n.Cells {
+			r := image.Rect(int(n.X), int(c.Y), int(n.X+n.W), int(c.Y+c.H))
+			if i == 0 && n.Node.Color != "" {
+				if fill, ok := parseHexColor(n.Node.Color); ok {
+					draw.Draw(img, r, image.NewUniform(fill), image.Point{}, draw.Src)
+				}
+			}
+			strokeRect(img, r)
+			for j, line := range c.Lines {
+				text.Dot = fixed.P(int(n.X+cellPadding), int(baseline(c, j)))
+				text.DrawString(line)
+			}
+		}
+	}
+
+	for _, e := range l.Edges {
+		const segments = 32
+		prev := e.At(0)
+		for s := 1; s <= segments; s++ {
+			next := e.At(float64(s) / segments)
+			strokeLine(img, prev, next)
+			prev = next
+		}
+		drawArrowHead(img, e.At(0.95), e.At(1))
+		if e.Edge.Label != "" {
+			mid := e.At(0.5)
+			text.Dot = fixed.P(int(mid.X+cellPadding), int(mid.Y))
+			text.DrawString(e.Edge.Label)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// parseHexColor parses a colour of the form "#rrggbb".
+func parseHexColor(s string) (color.Color, bool) {
+	if len(s) != 7 || s[0] != '#' {
+		return nil, false
+	}
+	rgb, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return nil, false
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}, true
+}
+
+func strokeRect(img *image.RGBA, r image.Rectangle) {
+	corners := []point{
+		{float64(r.Min.X), float64(r.Min.Y)},
+		{float64(r.Max.X), float64(r.Min.Y)},
+		{float64(r.Max.X), float64(r.Max.Y)},
+		{float64(r.Min.X), float64(r.Max.Y)},
+	}
+	for i := range corners {
+		strokeLine(img, corners[i], corners[(i+1)%len(corners)])
+	}
+}
+
+// strokeLine draws a black line from a to b with Bresenham's algorithm.
+func strokeLine(img *image.RGBA, a point, b point) {
+	x0, y0 := int(math.Round(a.X)), int(math.Round(a.Y))
+	x1, y1 := int(math.Round(b.X)), int(math.Round(b.Y))
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		img.Set(x0, y0, color.Black)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// drawArrowHead draws an arrow head at tip pointing away from from.
+func drawArrowHead(img *image.RGBA, from point, tip point) {
+	const length, spread = 8, 0.4
+	angle := math.Atan2(tip.Y-from.Y, tip.X-from.X)
+	for _, side := range []float64{-spread, spread} {
+		strokeLine(img, tip, point{
+			X: tip.X - length*math.Cos(angle+side),
+			Y: tip.Y - length*math.Sin(angle+side),
+		})
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
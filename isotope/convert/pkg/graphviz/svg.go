@@ -0,0 +1,81 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphviz
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+)
+
+// GraphToSVG renders a graphviz graph as an SVG image, laid out in layers
+// without Graphviz.
+func GraphToSVG(g Graph) ([]byte, error) {
+	l := layoutGraph(g)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f">`+"\n",
+		l.Width, l.Height, l.Width, l.Height)
+	b.WriteString(`<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" ` +
+		`markerWidth="8" markerHeight="8" orient="auto-start-reverse">` +
+		`<path d="M 0 0 L 10 5 L 0 10 z"/></marker></defs>` + "\n")
+	fmt.Fprintf(&b, `<style>text { font-family: monospace; font-size: %dpx; }</style>`+"\n",
+		lineHeight-3)
+	b.WriteString(`<rect width="100%" height="100%" fill="white"/>` + "\n")
+
+	for _, n := range l.Nodes {
+		fmt.Fprintf(&b, "<g id=%q>\n", n.Node.Name)
+		for i, c := range n.Cells {
+			fill := "white"
+			if i == 0 && n.Node.Color != "" {
+				fill = n.Node.Color
+			}
+			fmt.Fprintf(&b,
+				`<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill=%q stroke="black"/>`+"\n",
+				n.X, c.Y, n.W, c.H, fill)
+			for j, line := range c.Lines {
+				weight := ""
+				if i == 0 && j == 0 {
+					weight = ` font-weight="bold"`
+				}
+				fmt.Fprintf(&b, `<text x="%.1f" y="%.1f"%s>%s</text>`+"\n",
+					n.X+cellPadding, baseline(c, j), weight, html.EscapeString(line))
+			}
+		}
+		b.WriteString("</g>\n")
+	}
+
+	for _, e := range l.Edges {
+		p := e.Curve
+		fmt.Fprintf(&b,
+			`<path d="M %.1f %.1f C %.1f %.1f, %.1f %.1f, %.1f %.1f" `+
+				`fill="none" stroke="black" marker-end="url(#arrow)"/>`+"\n",
+			p[0].X, p[0].Y, p[1].X, p[1].Y, p[2].X, p[2].Y, p[3].X, p[3].Y)
+		if e.Edge.Label != "" {
+			mid := e.At(0.5)
+			fmt.Fprintf(&b, `<text x="%.1f" y="%.1f">%s</text>`+"\n",
+				mid.X+cellPadding, mid.Y, html.EscapeString(e.Edge.Label))
+		}
+	}
+
+	b.WriteString("</svg>\n")
+	return b.Bytes(), nil
+}
+
+// baseline returns the y coordinate of the baseline of line i of c.
+func baseline(c cell, i int) float64 {
+	return c.Y + cellPadding + float64((i+1)*lineHeight) - 4
+}
@@ -0,0 +1,185 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package histogram counts latencies in constant memory and summarizes them,
+// alike for load tests and simulations.
+package histogram
+
+import (
+	"math"
+	"math/bits"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+)
+
+// subBucketBits sets the precision of histograms: values are counted in
+// buckets 1/2^subBucketBits as wide as their magnitude, i.e. within 1%.
+const subBucketBits = 7
+
+// Percentiles are the percentiles of a Summary.
+var Percentiles = []float64{50, 75, 90, 99, 99.9, 99.99}
+
+// Histogram counts durations with a bounded relative error in constant
+// memory, like an HdrHistogram: values below 2^subBucketBits nanoseconds are
+// exact, and larger ones fall in one of 2^subBucketBits buckets per power of
+// two. The zero value is an empty histogram.
+type Histogram struct {
+	counts []uint64
+	// bounds count the values up to each bound of bucketBound, exactly.
+	bounds []uint64
+	count  uint64
+	sum    float64
+	min    time.Duration
+	max    time.Duration
+}
+
+func bucketIndex(v time.Duration) int {
+	if v < 1<<subBucketBits {
+		return int(max(v, 0))
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits - 1
+	return (shift+1)<<subBucketBits + int(uint64(v)>>shift) - 1<<subBucketBits
+}
+
+// highestEquivalent returns the largest value counted in the bucket at i.
+func highestEquivalent(i int) time.Duration {
+	if i < 1<<subBucketBits {
+		return time.Duration(i)
+	}
+	shift := i>>subBucketBits - 1
+	sub := i&(1<<subBucketBits-1) + 1<<subBucketBits
+	return time.Duration((uint64(sub)+1)<<shift - 1)
+}
+
+// bucketBound returns the i-th bound of the 1-2-5 series 1ms, 2ms, 5ms,
+// 10ms...
+func bucketBound(i int) time.Duration {
+	bound := []time.Duration{1, 2, 5}[i%3] * time.Millisecond
+	for range i / 3 {
+		bound *= 10
+	}
+	return bound
+}
+
+// boundIndex returns the index of the first bound of bucketBound greater or
+// equal to v.
+func boundIndex(v time.Duration) int {
+	i := 0
+	for bucketBound(i) < v {
+		i++
+	}
+	return i
+}
+
+// Record counts v.
+func (h *Histogram) Record(v time.Duration) {
+	i := bucketIndex(v)
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+	b := boundIndex(v)
+	if b >= len(h.bounds) {
+		h.bounds = append(h.bounds, make([]uint64, b+1-len(h.bounds))...)
+	}
+	h.bounds[b]++
+	if h.count == 0 || v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.count++
+	h.sum += float64(v)
+}
+
+// Count returns the number of values counted.
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Percentile returns the value below or equal to which p percent of the
+// values fall, up to the precision of h.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	target := uint64(math.Ceil(p / 100 * float64(h.count)))
+	target = max(target, 1)
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= target {
+			return min(max(highestEquivalent(i), h.min), h.max)
+		}
+	}
+	return h.max
+}
+
+// Summary summarizes a Histogram.
+type Summary struct {
+	Count       uint64            `json:"count"`
+	Min         duration.Duration `json:"min"`
+	Mean        duration.Duration `json:"mean"`
+	Max         duration.Duration `json:"max"`
+	Percentiles []Percentile      `json:"percentiles"`
+	// Buckets count the values between the upper bound of the previous
+	// bucket, exclusive, and their own, inclusive. Bounds follow a 1-2-5
+	// series from 1ms up to the maximum value.
+	Buckets []Bucket `json:"buckets,omitempty"`
+}
+
+// Percentile is the value below or equal to which Percentile percent of the
+// values fall.
+type Percentile struct {
+	Percentile float64           `json:"percentile"`
+	Value      duration.Duration `json:"value"`
+}
+
+// Bucket is a bucket of a Summary.
+type Bucket struct {
+	UpperBound duration.Duration `json:"le"`
+	Count      uint64            `json:"count"`
+}
+
+// Summary returns the summary of h, with the value of each of Percentiles.
+func (h *Histogram) Summary() Summary {
+	s := Summary{
+		Count: h.count,
+		Min:   duration.Duration(h.min),
+		Max:   duration.Duration(h.max),
+	}
+	if h.count > 0 {
+		s.Mean = duration.Duration(h.sum / float64(h.count))
+	}
+	for _, p := range Percentiles {
+		s.Percentiles = append(s.Percentiles, Percentile{p, duration.Duration(h.Percentile(p))})
+	}
+	for i, c := range h.bounds {
+		s.Buckets = append(s.Buckets, Bucket{duration.Duration(bucketBound(i)), c})
+	}
+	return s
+}
+
+// Percentile returns the value of percentile p, one of Percentiles, or 0 if
+// s has none.
+func (s Summary) Percentile(p float64) duration.Duration {
+	for _, percentile := range s.Percentiles {
+		if percentile.Percentile == p {
+			return percentile.Value
+		}
+	}
+	return 0
+}
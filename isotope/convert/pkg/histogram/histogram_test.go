@@ -0,0 +1,112 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package histogram
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+)
+
+func TestBucketIndex(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []time.Duration{
+		0, 1, 127, 128, 255, 256, 1000, time.Millisecond, 1234567, time.Minute,
+	} {
+		i := bucketIndex(v)
+		highest := highestEquivalent(i)
+		if highest < v || float64(highest-v) > 0.01*float64(v) {
+			t.Errorf("%v: expected a bucket within 1%%; actual highest %v", v, highest)
+		}
+		if i > 0 && highestEquivalent(i-1) >= v {
+			t.Errorf("%v: expected previous bucket below; actual %v", v, highestEquivalent(i-1))
+		}
+	}
+}
+
+func TestHistogram_Summary(t *testing.T) {
+	t.Parallel()
+
+	var h Histogram
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+	s := h.Summary()
+
+	if s.Count != 10000 {
+		t.Errorf("expected count %v; actual %v", 10000, s.Count)
+	}
+	if s.Min != duration.Duration(time.Microsecond) || s.Max != duration.Duration(10*time.Millisecond) {
+		t.Errorf("expected min 1µs and max 10ms; actual %v and %v", s.Min, s.Max)
+	}
+	if expected := duration.Duration(5000500 * time.Nanosecond); s.Mean != expected {
+		t.Errorf("expected mean %v; actual %v", expected, s.Mean)
+	}
+	if len(s.Percentiles) != len(Percentiles) {
+		t.Errorf("expected %v percentiles; actual %v", len(Percentiles), s.Percentiles)
+	}
+	for _, p := range s.Percentiles {
+		expected := p.Percentile / 100 * float64(10*time.Millisecond)
+		if float64(p.Value) < expected || float64(p.Value) > 1.01*expected {
+			t.Errorf("p%v: expected %v; actual %v", p.Percentile, time.Duration(expected), p.Value)
+		}
+	}
+
+	ms := func(f float64) duration.Duration {
+		return duration.Duration(f * float64(time.Millisecond))
+	}
+	expectedBuckets := []Bucket{
+		{ms(1), 1000},
+		{ms(2), 1000},
+		{ms(5), 3000},
+		{ms(10), 5000},
+	}
+	if !reflect.DeepEqual(expectedBuckets, s.Buckets) {
+		t.Errorf("expected %v; actual %v", expectedBuckets, s.Buckets)
+	}
+}
+
+func TestHistogram_Percentile(t *testing.T) {
+	t.Parallel()
+
+	// Percentiles are exact when every value is the same.
+	var h Histogram
+	for range 10 {
+		h.Record(17 * time.Millisecond)
+	}
+	for _, p := range Percentiles {
+		if actual := h.Percentile(p); actual != 17*time.Millisecond {
+			t.Errorf("p%v: expected %v; actual %v", p, 17*time.Millisecond, actual)
+		}
+	}
+}
+
+func TestHistogram_Summary_Empty(t *testing.T) {
+	t.Parallel()
+
+	var h Histogram
+	s := h.Summary()
+	if s.Count != 0 || s.Max != 0 || s.Buckets != nil {
+		t.Errorf("expected an empty summary; actual %v", s)
+	}
+	for _, p := range s.Percentiles {
+		if p.Value != 0 {
+			t.Errorf("p%v: expected 0; actual %v", p.Percentile, p.Value)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+	"istio.io/tools/isotope/convert/pkg/graph/pct"
+	"istio.io/tools/isotope/convert/pkg/graph/svc"
+)
+
+// The Istio resources below only declare the fields isotope sets, which
+// avoids depending on the Istio API modules.
+
+const (
+	istioNetworkingAPIVersion = "networking.istio.io/v1"
+	istioSecurityAPIVersion   = "security.istio.io/v1"
+
+	defaultNamespace = "default"
+)
+
+type istioResource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              interface{} `json:"spec"`
+}
+
+type virtualServiceSpec struct {
+	Hosts []string    `json:"hosts"`
+	HTTP  []httpRoute `json:"http"`
+}
+
+type httpRoute struct {
+	Route   []httpRouteDestination `json:"route"`
+	Timeout string                 `json:"timeout,omitempty"`
+	Retries *httpRetry             `json:"retries,omitempty"`
+	Fault   *httpFaultInjection    `json:"fault,omitempty"`
+}
+
+type httpRouteDestination struct {
+	Destination destination `json:"destination"`
+	Weight      int32       `json:"weight,omitempty"`
+}
+
+type destination struct {
+	Host   string `json:"host"`
+	Subset string `json:"subset,omitempty"`
+}
+
+type httpRetry struct {
+	Attempts      int32  `json:"attempts"`
+	PerTryTimeout string `json:"perTryTimeout,omitempty"`
+	RetryOn       string `json:"retryOn,omitempty"`
+}
+
+type httpFaultInjection struct {
+	Delay *faultDelay `json:"delay,omitempty"`
+	Abort *faultAbort `json:"abort,omitempty"`
+}
+
+type faultDelay struct {
+	Percentage percent `json:"percentage"`
+	FixedDelay string  `json:"fixedDelay"`
+}
+
+type faultAbort struct {
+	Percentage percent `json:"percentage"`
+	HTTPStatus int32   `json:"httpStatus"`
+}
+
+type percent struct {
+	Value float64 `json:"value"`
+}
+
+type destinationRuleSpec struct {
+	Host          string         `json:"host"`
+	TrafficPolicy *trafficPolicy `json:"trafficPolicy,omitempty"`
+	Subsets       []subset       `json:"subsets,omitempty"`
+}
+
+type trafficPolicy struct {
+	ConnectionPool   *connectionPoolSettings `json:"connectionPool,omitempty"`
+	OutlierDetection *outlierDetection       `json:"outlierDetection,omitempty"`
+	TLS              *clientTLSSettings      `json:"tls,omitempty"`
+}
+
+type connectionPoolSettings struct {
+	TCP  *tcpSettings  `json:"tcp,omitempty"`
+	HTTP *httpSettings `json:"http,omitempty"`
+}
+
+type tcpSettings struct {
+	MaxConnections int32  `json:"maxConnections,omitempty"`
+	ConnectTimeout string `json:"connectTimeout,omitempty"`
+}
+
+type httpSettings struct {
+	HTTP1MaxPendingRequests  int32 `json:"http1MaxPendingRequests,omitempty"`
+	HTTP2MaxRequests         int32 `json:"http2MaxRequests,omitempty"`
+	MaxRequestsPerConnection int32 `json:"maxRequestsPerConnection,omitempty"`
+	MaxRetries               int32 `json:"maxRetries,omitempty"`
+}
+
+type outlierDetection struct {
+	Consecutive5xxErrors int32  `json:"consecutive5xxErrors,omitempty"`
+	Interval             string `json:"interval,omitempty"`
+	BaseEjectionTime     string `json:"baseEjectionTime,omitempty"`
+	MaxEjectionPercent   int32  `json:"maxEjectionPercent,omitempty"`
+}
+
+type clientTLSSettings struct {
+	Mode string `json:"mode"`
+}
+
+type subset struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+}
+
+type serviceEntrySpec struct {
+	Hosts      []string        `json:"hosts"`
+	Addresses  []string        `json:"addresses"`
+	Ports      []istioPort     `json:"ports"`
+	Location   string          `json:"location"`
+	Resolution string          `json:"resolution"`
+	Endpoints  []workloadEntry `json:"endpoints"`
+}
+
+type istioPort struct {
+	Number   uint32 `json:"number"`
+	Protocol string `json:"protocol"`
+	Name     string `json:"name"`
+}
+
+type workloadEntry struct {
+	Address string            `json:"address"`
+	Ports   map[string]uint32 `json:"ports"`
+}
+
+type sidecarSpec struct {
+	WorkloadSelector workloadSelector      `json:"workloadSelector"`
+	Egress           []istioEgressListener `json:"egress"`
+}
+
+type workloadSelector struct {
+	Labels map[string]string `json:"labels"`
+}
+
+type istioEgressListener struct {
+	Hosts []string `json:"hosts"`
+}
+
+type authorizationPolicySpec struct {
+	Selector workloadSelector `json:"selector"`
+	Action   string           `json:"action"`
+	Rules    []rule           `json:"rules"`
+}
+
+type rule struct {
+	From []ruleFrom `json:"from,omitempty"`
+}
+
+type ruleFrom struct {
+	Source source `json:"source"`
+}
+
+type source struct {
+	Principals []string `json:"principals"`
+}
+
+// makeIstioManifests returns the Istio resources of service: a
+// VirtualService and a DestinationRule if its Istio settings need them, a
+// Sidecar limiting its egress to the services it calls, and an
+// AuthorizationPolicy allowing requests only from its callers, or from
+// anywhere for entrypoints.
+func makeIstioManifests(
+	service svc.Service, services map[string]svc.Service,
+	calls []string, callers []string) []interface{} {
+	var manifests []interface{}
+	if vs, ok := makeVirtualService(service); ok {
+		manifests = append(manifests, vs)
+	}
+	if dr, ok := makeDestinationRule(service); ok {
+		manifests = append(manifests, dr)
+	}
+	manifests = append(manifests, makeSidecar(service, services, calls))
+	manifests = append(manifests, makeAuthorizationPolicy(service, services, callers))
+	return manifests
+}
+
+func makeServiceAccount(service svc.Service) (serviceAccount apiv1.ServiceAccount) {
+	serviceAccount.APIVersion = "v1"
+	serviceAccount.Kind = "ServiceAccount"
+	serviceAccount.ObjectMeta = istioObjectMeta(service)
+	return
+}
+
+func makeVirtualService(service svc.Service) (istioResource, bool) {
+	settings := service.Istio
+	if settings == nil || (settings.Timeout == 0 && settings.Retries == nil &&
+		settings.Fault == nil && len(settings.TrafficSplit) == 0) {
+		return istioResource{}, false
+	}
+	host := hostname(service)
+	route := httpRoute{Timeout: istioDuration(settings.Timeout)}
+	for _, name := range subsetNames(settings.TrafficSplit) {
+		route.Route = append(route.Route, httpRouteDestination{
+			Destination: destination{Host: host, Subset: name},
+			Weight:      settings.TrafficSplit[name],
+		})
+	}
+	if len(route.Route) == 0 {
+		route.Route = []httpRouteDestination{{Destination: destination{Host: host}}}
+	}
+	if retries := settings.Retries; retries != nil {
+		route.Retries = &httpRetry{
+			Attempts:      retries.Attempts,
+			PerTryTimeout: istioDuration(retries.PerTryTimeout),
+			RetryOn:       retries.RetryOn,
+		}
+	}
+	if fault := settings.Fault; fault != nil {
+		route.Fault = &httpFaultInjection{}
+		if fault.Delay != nil {
+			route.Fault.Delay = &faultDelay{
+				Percentage: istioPercent(fault.Delay.Percentage),
+				FixedDelay: istioDuration(fault.Delay.FixedDelay),
+			}
+		}
+		if fault.Abort != nil {
+			route.Fault.Abort = &faultAbort{
+				Percentage: istioPercent(fault.Abort.Percentage),
+				HTTPStatus: fault.Abort.HTTPStatus,
+			}
+		}
+	}
+	return istioResource{
+		TypeMeta:   metav1.TypeMeta{APIVersion: istioNetworkingAPIVersion, Kind: "VirtualService"},
+		ObjectMeta: istioObjectMeta(service),
+		Spec: virtualServiceSpec{
+			Hosts: []string{host},
+			HTTP:  []httpRoute{route},
+		},
+	}, true
+}
+
+func makeDestinationRule(service svc.Service) (istioResource, bool) {
+	settings := service.Istio
+	if settings == nil || (settings.ConnectionPool == nil &&
+		settings.OutlierDetection == nil && settings.TLSMode == "" &&
+		len(settings.TrafficSplit) == 0) {
+		return istioResource{}, false
+	}
+	spec := destinationRuleSpec{Host: hostname(service)}
+	for _, name := range subsetNames(settings.TrafficSplit) {
+		spec.Subsets = append(spec.Subsets, subset{
+			Name:   name,
+			Labels: map[string]string{versionLabel: name},
+		})
+	}
+	policy := trafficPolicy{}
+	if pool := settings.ConnectionPool; pool != nil {
+		policy.ConnectionPool = &connectionPoolSettings{}
+		if pool.MaxConnections > 0 || pool.ConnectTimeout > 0 {
+			policy.ConnectionPool.TCP = &tcpSettings{
+				MaxConnections: pool.MaxConnections,
+				ConnectTimeout: istioDuration(pool.ConnectTimeout),
+			}
+		}
+		if pool.HTTP1MaxPendingRequests > 0 || pool.HTTP2MaxRequests > 0 ||
+			pool.MaxRequestsPerConnection > 0 || pool.MaxRetries > 0 {
+			policy.ConnectionPool.HTTP = &httpSettings{
+				HTTP1MaxPendingRequests:  pool.HTTP1MaxPendingRequests,
+				HTTP2MaxRequests:         pool.HTTP2MaxRequests,
+				MaxRequestsPerConnection: pool.MaxRequestsPerConnection,
+				MaxRetries:               pool.MaxRetries,
+			}
+		}
+	}
+	if detection := settings.OutlierDetection; detection != nil {
+		policy.OutlierDetection = &outlierDetection{
+			Consecutive5xxErrors: detection.Consecutive5xxErrors,
+			Interval:             istioDuration(detection.Interval),
+			BaseEjectionTime:     istioDuration(detection.BaseEjectionTime),
+			MaxEjectionPercent:   detection.MaxEjectionPercent,
+		}
+	}
+	if settings.TLSMode != "" {
+		policy.TLS = &clientTLSSettings{Mode: settings.TLSMode}
+	}
+	if policy != (trafficPolicy{}) {
+		spec.TrafficPolicy = &policy
+	}
+	return istioResource{
+		TypeMeta:   metav1.TypeMeta{APIVersion: istioNetworkingAPIVersion, Kind: "DestinationRule"},
+		ObjectMeta: istioObjectMeta(service),
+		Spec:       spec,
+	}, true
+}
+
+func makeSidecar(
+	service svc.Service, services map[string]svc.Service, calls []string) istioResource {
+	hosts := []string{"istio-system/*"}
+	for _, name := range uniqueSorted(calls) {
+		callee := services[name]
+		hosts = append(hosts, fmt.Sprintf("%s/%s", namespace(callee), hostname(callee)))
+	}
+	return istioResource{
+		TypeMeta:   metav1.TypeMeta{APIVersion: istioNetworkingAPIVersion, Kind: "Sidecar"},
+		ObjectMeta: istioObjectMeta(service),
+		Spec: sidecarSpec{
+			WorkloadSelector: workloadSelector{Labels: map[string]string{"name": service.Name}},
+			Egress:           []istioEgressListener{{Hosts: hosts}},
+		},
+	}
+}
+
+func makeAuthorizationPolicy(
+	service svc.Service, services map[string]svc.Service, callers []string) istioResource {
+	// An ALLOW policy without rules denies every request, and an empty rule
+	// allows every request.
+	rules := []rule{}
+	if service.IsEntrypoint {
+		rules = append(rules, rule{})
+	} else if len(callers) > 0 {
+		var principals []string
+		for _, name := range uniqueSorted(callers) {
+			principals = append(principals, principal(services[name]))
+		}
+		rules = append(rules, rule{From: []ruleFrom{{Source: source{Principals: principals}}}})
+	}
+	return istioResource{
+		TypeMeta:   metav1.TypeMeta{APIVersion: istioSecurityAPIVersion, Kind: "AuthorizationPolicy"},
+		ObjectMeta: istioObjectMeta(service),
+		Spec: authorizationPolicySpec{
+			Selector: workloadSelector{Labels: map[string]string{"name": service.Name}},
+			Action:   "ALLOW",
+			Rules:    rules,
+		},
+	}
+}
+
+func istioObjectMeta(service svc.Service) metav1.ObjectMeta {
+	objectMeta := metav1.ObjectMeta{
+		Name:      service.Name,
+		Namespace: service.Namespace,
+		Labels: combineLabels(
+			serviceGraphNodeLabels,
+			map[string]string{
+				"app": service.Name,
+			},
+			service.Labels),
+	}
+	timestamp(&objectMeta)
+	return objectMeta
+}
+
+// namespace returns the namespace of service, assuming services without one
+// are deployed to the default namespace.
+func namespace(service svc.Service) string {
+	if service.Namespace == "" {
+		return defaultNamespace
+	}
+	return service.Namespace
+}
+
+func hostname(service svc.Service) string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local", service.Name, namespace(service))
+}
+
+// principal is the identity of the replicas of service, which run as the
+// service account named after it.
+func principal(service svc.Service) string {
+	return fmt.Sprintf("cluster.local/ns/%s/sa/%s", namespace(service), service.Name)
+}
+
+func subsetNames(trafficSplit map[string]int32) []string {
+	names := make([]string, 0, len(trafficSplit))
+	for name := range trafficSplit {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func uniqueSorted(names []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// istioDuration formats d in seconds, e.g. "0.25s", which Istio accepts for
+// every duration. Zero durations are omitted.
+func istioDuration(d duration.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(time.Duration(d).Seconds(), 'f', -1, 64) + "s"
+}
+
+func istioPercent(p pct.Percentage) percent {
+	return percent{Value: float64(p) * 100}
+}
@@ -23,21 +23,30 @@ import (
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	apiv1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/yaml"
 
 	"istio.io/tools/isotope/convert/pkg/consts"
 	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
 	"istio.io/tools/isotope/convert/pkg/graph/svc"
+	"istio.io/tools/isotope/convert/pkg/graph/svctype"
 )
 
 const (
 	numConfigMaps          = 1
 	numManifestsPerService = 2
 
-	configVolume           = "config-volume"
+	configVolume = "config-volume"
+	tlsVolume    = "tls-volume"
+	// tlsSecretName is the TLS Secret services calling over TLS serve with.
+	tlsSecretName          = "isotope-tls"
 	serviceGraphConfigName = "service-graph-config"
+	versionLabel           = "version"
 )
 
 var (
@@ -49,7 +58,9 @@ var (
 )
 
 // ServiceGraphToKubernetesManifests converts a ServiceGraph to Kubernetes
-// manifests.
+// manifests. Unless clientDisabled, they include a fortio client or, with
+// loadClient, a Job running the load command of clientImage, the converter
+// image, with loadClientArgs.
 func ServiceGraphToKubernetesManifests(
 	serviceGraph graph.ServiceGraph,
 	serviceNodeSelector map[string]string,
@@ -60,7 +71,10 @@ func ServiceGraphToKubernetesManifests(
 	clientNamespace string,
 	environmentName string,
 	clusterName string,
-	clientDisabled bool) ([]byte, error) {
+	clientDisabled bool,
+	istio bool,
+	loadClient bool,
+	loadClientArgs []string) ([]byte, error) {
 	numServices := len(serviceGraph.Services)
 	numManifests := numManifestsPerService*numServices + numConfigMaps
 	manifests := make([]string, 0, numManifests)
@@ -90,7 +104,7 @@ func ServiceGraphToKubernetesManifests(
 				break
 			}
 		}
-		if ommit == false && service.Cluster == clusterName {
+		if ommit == false && (service.Cluster == clusterName || clusterName == "") {
 			namespaces = append(namespaces, service.Namespace)
 		}
 	}
@@ -106,7 +120,13 @@ func ServiceGraphToKubernetesManifests(
 	} else {
 
 		for _, namespace := range namespaces {
-			configMap, err := makeConfigMap(serviceGraph, namespace)
+			// Each namespace only gets the services deployed there, and the
+			// types of the services they call.
+			slice := serviceGraph.Slice(func(service svc.Service) bool {
+				return service.Namespace == namespace &&
+					(service.Cluster == clusterName || clusterName == "")
+			})
+			configMap, err := makeConfigMap(slice, namespace)
 			if err != nil {
 				return nil, err
 			}
@@ -116,25 +136,91 @@ func ServiceGraphToKubernetesManifests(
 		}
 	}
 
+	services := make(map[string]svc.Service, numServices)
+	for _, service := range serviceGraph.Services {
+		services[service.Name] = service
+	}
+	calls := serviceGraph.Calls()
+	callers := map[string][]string{}
+	for caller, callees := range calls {
+		for _, callee := range callees {
+			callers[callee] = append(callers[callee], caller)
+		}
+	}
+
 	for _, service := range serviceGraph.Services {
 		if service.Cluster == clusterName || clusterName == "" {
-			k8sDeployment := makeDeployment(
-				service, serviceNodeSelector, serviceImage,
-				serviceMaxIdleConnectionsPerHost)
-			innerErr := appendManifest(k8sDeployment)
-			if innerErr != nil {
-				return nil, innerErr
+			if istio {
+				innerErr := appendManifest(makeServiceAccount(service))
+				if innerErr != nil {
+					return nil, innerErr
+				}
+			}
+
+			for _, version := range versionNames(service) {
+				versioned, err := service.WithVersion(version)
+				if err != nil {
+					return nil, err
+				}
+				k8sDeployment := makeDeployment(
+					versioned, version, serviceNodeSelector, serviceImage,
+					serviceMaxIdleConnectionsPerHost)
+				if istio {
+					// Each service runs as its own service account, to be told
+					// apart by authorization policies.
+					k8sDeployment.Spec.Template.Spec.ServiceAccountName = service.Name
+				}
+				innerErr := appendManifest(k8sDeployment)
+				if innerErr != nil {
+					return nil, innerErr
+				}
+
+				if service.Autoscaling != nil {
+					innerErr = appendManifest(makeHorizontalPodAutoscaler(versioned, version))
+					if innerErr != nil {
+						return nil, innerErr
+					}
+				}
 			}
 
 			k8sService := makeService(service)
-			innerErr = appendManifest(k8sService)
+			innerErr := appendManifest(k8sService)
 			if innerErr != nil {
 				return nil, innerErr
 			}
+
+			if service.DisruptionBudget != nil {
+				innerErr = appendManifest(makePodDisruptionBudget(service))
+				if innerErr != nil {
+					return nil, innerErr
+				}
+			}
+
+			if istio {
+				for _, manifest := range makeIstioManifests(
+					service, services, calls[service.Name], callers[service.Name]) {
+					innerErr = appendManifest(manifest)
+					if innerErr != nil {
+						return nil, innerErr
+					}
+				}
+			}
 		}
 	}
 
-	if !clientDisabled {
+	if !clientDisabled && loadClient {
+		loadClientManifests, err := makeLoadClientManifests(
+			serviceGraph, clientNodeSelector, clientImage, clientNamespace,
+			loadClientArgs)
+		if err != nil {
+			return nil, err
+		}
+		for _, manifest := range loadClientManifests {
+			if err := appendManifest(manifest); err != nil {
+				return nil, err
+			}
+		}
+	} else if !clientDisabled {
 		fortioDeployment := makeFortioDeployment(
 			clientNodeSelector, clientImage, clientNamespace)
 		if err := appendManifest(fortioDeployment); err != nil {
@@ -206,32 +292,74 @@ func makeService(service svc.Service) (k8sService apiv1.Service) {
 		},
 		service.Labels)
 	timestamp(&k8sService.ObjectMeta)
-	k8sService.Spec.Ports = []apiv1.ServicePort{{Port: consts.ServicePort, Name: consts.ServicePortName}}
+	portName := consts.ServicePortName
+	if service.Type == svctype.ServiceGRPC {
+		portName = consts.ServiceGRPCPortName
+	}
+	k8sService.Spec.Ports = []apiv1.ServicePort{{Port: consts.ServicePort, Name: portName}}
 	k8sService.Spec.Selector = map[string]string{"name": service.Name}
 	return
 }
 
+// versionNames returns the names of the versions of service, or only "" if it
+// has none.
+func versionNames(service svc.Service) []string {
+	if len(service.Versions) == 0 {
+		return []string{""}
+	}
+	names := make([]string, 0, len(service.Versions))
+	for _, version := range service.Versions {
+		names = append(names, version.Name)
+	}
+	return names
+}
+
+// deploymentName returns the name of the Deployment of version of service.
+func deploymentName(service svc.Service, version string) string {
+	if version == "" {
+		return service.Name
+	}
+	return fmt.Sprintf("%s-%s", service.Name, version)
+}
+
+// versionLabels returns the labels identifying version, if any.
+func versionLabels(version string) map[string]string {
+	if version == "" {
+		return nil
+	}
+	return map[string]string{versionLabel: version}
+}
+
 func makeDeployment(
-	service svc.Service, nodeSelector map[string]string,
+	service svc.Service, version string, nodeSelector map[string]string,
 	serviceImage string, serviceMaxIdleConnectionsPerHost int) (
 	k8sDeployment appsv1.Deployment) {
 	k8sDeployment.APIVersion = "apps/v1"
 	k8sDeployment.Kind = "Deployment"
-	k8sDeployment.ObjectMeta.Name = service.Name
+	k8sDeployment.ObjectMeta.Name = deploymentName(service, version)
 	k8sDeployment.ObjectMeta.Namespace = service.Namespace
 	k8sDeployment.ObjectMeta.Labels = combineLabels(
 		serviceGraphNodeLabels,
 		map[string]string{
 			"app": service.Name,
 		},
+		versionLabels(version),
 		service.Labels)
 	timestamp(&k8sDeployment.ObjectMeta)
+	env := []apiv1.EnvVar{
+		{Name: consts.ServiceNameEnvKey, Value: service.Name},
+	}
+	if version != "" {
+		env = append(env, apiv1.EnvVar{Name: consts.ServiceVersionEnvKey, Value: version})
+	}
 	k8sDeployment.Spec = appsv1.DeploymentSpec{
 		Replicas: &service.NumReplicas,
 		Selector: &metav1.LabelSelector{
-			MatchLabels: map[string]string{
-				"name": service.Name,
-			},
+			MatchLabels: combineLabels(
+				map[string]string{
+					"name": service.Name,
+				},
+				versionLabels(version)),
 		},
 		Template: apiv1.PodTemplateSpec{
 			ObjectMeta: metav1.ObjectMeta{
@@ -240,6 +368,7 @@ func makeDeployment(
 					map[string]string{
 						"name": service.Name,
 					},
+					versionLabels(version),
 					service.Labels),
 				Annotations: prometheusScrapeAnnotations,
 			},
@@ -249,14 +378,9 @@ func makeDeployment(
 					{
 						Name:  consts.ServiceContainerName,
 						Image: serviceImage,
-						Args: []string{
-							fmt.Sprintf(
-								"--max-idle-connections-per-host=%v",
-								serviceMaxIdleConnectionsPerHost),
-						},
-						Env: []apiv1.EnvVar{
-							{Name: consts.ServiceNameEnvKey, Value: service.Name},
-						},
+						Args: transportArgs(
+							service.Transport, serviceMaxIdleConnectionsPerHost),
+						Env: env,
 						VolumeMounts: []apiv1.VolumeMount{
 							{
 								Name:      configVolume,
@@ -268,6 +392,9 @@ func makeDeployment(
 								ContainerPort: consts.ServicePort,
 							},
 						},
+						Resources:      resourceRequirements(service.Resources),
+						ReadinessProbe: readinessProbe(service.Probes),
+						LivenessProbe:  livenessProbe(service.Probes),
 					},
 				},
 				Volumes: []apiv1.Volume{
@@ -291,10 +418,210 @@ func makeDeployment(
 			},
 		},
 	}
+	if service.Transport != nil && service.Transport.TLS {
+		withTLS(&k8sDeployment.Spec.Template)
+	}
 	timestamp(&k8sDeployment.Spec.Template.ObjectMeta)
 	return
 }
 
+// withTLS makes the service container of template, which calls over TLS,
+// serve TLS too, with the certificate of the TLS Secret tlsSecretName.
+func withTLS(template *apiv1.PodTemplateSpec) {
+	template.Annotations = combineLabels(
+		template.Annotations, map[string]string{"prometheus.io/scheme": "https"})
+	container := &template.Spec.Containers[0]
+	container.Args = append(container.Args,
+		fmt.Sprintf("--tls-cert-file=%s/%s", consts.TLSPath, apiv1.TLSCertKey),
+		fmt.Sprintf("--tls-key-file=%s/%s", consts.TLSPath, apiv1.TLSPrivateKeyKey))
+	container.VolumeMounts = append(container.VolumeMounts, apiv1.VolumeMount{
+		Name:      tlsVolume,
+		MountPath: consts.TLSPath,
+		ReadOnly:  true,
+	})
+	for _, probe := range []*apiv1.Probe{container.ReadinessProbe, container.LivenessProbe} {
+		if probe != nil {
+			probe.HTTPGet.Scheme = apiv1.URISchemeHTTPS
+		}
+	}
+	template.Spec.Volumes = append(template.Spec.Volumes, apiv1.Volume{
+		Name: tlsVolume,
+		VolumeSource: apiv1.VolumeSource{
+			Secret: &apiv1.SecretVolumeSource{SecretName: tlsSecretName},
+		},
+	})
+}
+
+// resourceRequirements converts resources, if any, to the resource
+// requirements of the service container.
+func resourceRequirements(resources *svc.Resources) apiv1.ResourceRequirements {
+	if resources == nil {
+		return apiv1.ResourceRequirements{}
+	}
+	return apiv1.ResourceRequirements{
+		Requests: resourceList(resources.Requests),
+		Limits:   resourceList(resources.Limits),
+	}
+}
+
+func resourceList(list svc.ResourceList) apiv1.ResourceList {
+	k8sList := apiv1.ResourceList{}
+	if list.CPU != nil {
+		k8sList[apiv1.ResourceCPU] = *list.CPU
+	}
+	if list.Memory != nil {
+		k8sList[apiv1.ResourceMemory] = *list.Memory
+	}
+	if len(k8sList) == 0 {
+		return nil
+	}
+	return k8sList
+}
+
+func readinessProbe(probes *svc.Probes) *apiv1.Probe {
+	if probes == nil {
+		return nil
+	}
+	return makeProbe(probes.Readiness)
+}
+
+func livenessProbe(probes *svc.Probes) *apiv1.Probe {
+	if probes == nil {
+		return nil
+	}
+	return makeProbe(probes.Liveness)
+}
+
+// makeProbe converts probe, if any, to a probe of the health endpoint of the
+// service port.
+func makeProbe(probe *svc.Probe) *apiv1.Probe {
+	if probe == nil {
+		return nil
+	}
+	return &apiv1.Probe{
+		ProbeHandler: apiv1.ProbeHandler{
+			HTTPGet: &apiv1.HTTPGetAction{
+				Path: consts.ServiceHealthPath,
+				Port: intstr.FromInt32(consts.ServicePort),
+			},
+		},
+		InitialDelaySeconds: seconds(probe.InitialDelay),
+		PeriodSeconds:       seconds(probe.Period),
+		TimeoutSeconds:      seconds(probe.Timeout),
+		FailureThreshold:    probe.FailureThreshold,
+	}
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d duration.Duration) int32 {
+	return int32((time.Duration(d) + time.Second - 1) / time.Second)
+}
+
+func makeHorizontalPodAutoscaler(
+	service svc.Service, version string) (hpa autoscalingv2.HorizontalPodAutoscaler) {
+	hpa.APIVersion = "autoscaling/v2"
+	hpa.Kind = "HorizontalPodAutoscaler"
+	hpa.ObjectMeta.Name = deploymentName(service, version)
+	hpa.ObjectMeta.Namespace = service.Namespace
+	hpa.ObjectMeta.Labels = combineLabels(
+		serviceGraphNodeLabels,
+		map[string]string{
+			"app": service.Name,
+		},
+		versionLabels(version),
+		service.Labels)
+	timestamp(&hpa.ObjectMeta)
+	minReplicas := service.Autoscaling.MinReplicas
+	if minReplicas == 0 {
+		minReplicas = service.NumReplicas
+	}
+	targetCPUUtilization := service.Autoscaling.TargetCPUUtilization
+	hpa.Spec = autoscalingv2.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       deploymentName(service, version),
+		},
+		MinReplicas: &minReplicas,
+		MaxReplicas: service.Autoscaling.MaxReplicas,
+		Metrics: []autoscalingv2.MetricSpec{
+			{
+				Type: autoscalingv2.ResourceMetricSourceType,
+				Resource: &autoscalingv2.ResourceMetricSource{
+					Name: apiv1.ResourceCPU,
+					Target: autoscalingv2.MetricTarget{
+						Type:               autoscalingv2.UtilizationMetricType,
+						AverageUtilization: &targetCPUUtilization,
+					},
+				},
+			},
+		},
+	}
+	return
+}
+
+func makePodDisruptionBudget(service svc.Service) (pdb policyv1.PodDisruptionBudget) {
+	pdb.APIVersion = "policy/v1"
+	pdb.Kind = "PodDisruptionBudget"
+	pdb.ObjectMeta.Name = service.Name
+	pdb.ObjectMeta.Namespace = service.Namespace
+	pdb.ObjectMeta.Labels = combineLabels(
+		serviceGraphNodeLabels,
+		map[string]string{
+			"app": service.Name,
+		},
+		service.Labels)
+	timestamp(&pdb.ObjectMeta)
+	pdb.Spec = policyv1.PodDisruptionBudgetSpec{
+		MinAvailable:   service.DisruptionBudget.MinAvailable,
+		MaxUnavailable: service.DisruptionBudget.MaxUnavailable,
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"name": service.Name,
+			},
+		},
+	}
+	return
+}
+
+// transportArgs converts transport, if any, to the arguments of the service
+// container. Its MaxIdleConnectionsPerHost overrides
+// serviceMaxIdleConnectionsPerHost.
+func transportArgs(
+	transport *svc.Transport, serviceMaxIdleConnectionsPerHost int) []string {
+	var t svc.Transport
+	if transport != nil {
+		t = *transport
+	}
+	if t.MaxIdleConnectionsPerHost > 0 {
+		serviceMaxIdleConnectionsPerHost = t.MaxIdleConnectionsPerHost
+	}
+	args := []string{
+		fmt.Sprintf(
+			"--max-idle-connections-per-host=%v", serviceMaxIdleConnectionsPerHost),
+	}
+	if t.HTTP2 {
+		args = append(args, "--http2")
+	}
+	if t.KeepAlive != nil {
+		args = append(args, fmt.Sprintf("--keep-alive=%t", *t.KeepAlive))
+	}
+	if t.MaxConnectionsPerHost > 0 {
+		args = append(args, fmt.Sprintf(
+			"--max-connections-per-host=%d", t.MaxConnectionsPerHost))
+	}
+	if t.IdleTimeout > 0 {
+		args = append(args, fmt.Sprintf("--idle-connection-timeout=%s", t.IdleTimeout))
+	}
+	if t.TLS {
+		args = append(args, "--tls")
+	}
+	if t.TLSInsecureSkipVerify {
+		args = append(args, "--tls-insecure-skip-verify")
+	}
+	return args
+}
+
 func timestamp(objectMeta *metav1.ObjectMeta) {
 	objectMeta.CreationTimestamp = metav1.Time{Time: time.Now()}
 }
@@ -0,0 +1,190 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/tools/isotope/convert/pkg/consts"
+	"istio.io/tools/isotope/convert/pkg/graph"
+)
+
+// toManifests converts the service graph in graphYAML to manifests, with a
+// load client running loadClientArgs if loadClient is set.
+func toManifests(
+	t *testing.T, graphYAML string, loadClient bool, loadClientArgs ...string) (
+	string, error) {
+	t.Helper()
+	var g graph.ServiceGraph
+	if err := yaml.Unmarshal([]byte(graphYAML), &g); err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := ServiceGraphToKubernetesManifests(
+		g, nil, "isotope-service", 32, nil, "isotope-converter", "default",
+		"NONE", "", false, false, loadClient, loadClientArgs)
+	return string(manifests), err
+}
+
+func TestServiceGraphToKubernetesManifests_Transport(t *testing.T) {
+	t.Parallel()
+
+	manifests, err := toManifests(t, `
+services:
+- name: a
+  transport:
+    http2: true
+    maxIdleConnectionsPerHost: 4
+`, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, arg := range []string{"--max-idle-connections-per-host=4", "--http2"} {
+		if !strings.Contains(manifests, arg) {
+			t.Errorf("expected %s; actual %s", arg, manifests)
+		}
+	}
+
+}
+
+func TestServiceGraphToKubernetesManifests_TLS(t *testing.T) {
+	t.Parallel()
+
+	manifests, err := toManifests(t, `
+defaults:
+  transport:
+    tls: true
+    tlsInsecureSkipVerify: true
+services:
+- name: a
+  probes:
+    readiness: {}
+`, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deployment appsv1.Deployment
+	for _, doc := range strings.Split(manifests, "---\n") {
+		if strings.Contains(doc, "kind: Deployment") && strings.Contains(doc, "name: a\n") {
+			if err := yaml.Unmarshal([]byte(doc), &deployment); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	template := deployment.Spec.Template
+	if len(template.Spec.Containers) == 0 {
+		t.Fatalf("expected the deployment of a; actual %s", manifests)
+	}
+	container := template.Spec.Containers[0]
+	for _, arg := range []string{
+		"--tls", "--tls-insecure-skip-verify",
+		"--tls-cert-file=/etc/tls/tls.crt", "--tls-key-file=/etc/tls/tls.key",
+	} {
+		if !slices.Contains(container.Args, arg) {
+			t.Errorf("expected %s; actual %v", arg, container.Args)
+		}
+	}
+	expectedMount := apiv1.VolumeMount{Name: tlsVolume, MountPath: consts.TLSPath, ReadOnly: true}
+	if !slices.Contains(container.VolumeMounts, expectedMount) {
+		t.Errorf("expected %v; actual %v", expectedMount, container.VolumeMounts)
+	}
+	secretMounted := false
+	for _, volume := range template.Spec.Volumes {
+		if volume.Secret != nil && volume.Secret.SecretName == tlsSecretName {
+			secretMounted = volume.Name == tlsVolume
+		}
+	}
+	if !secretMounted {
+		t.Errorf("expected the %s secret; actual %v", tlsSecretName, template.Spec.Volumes)
+	}
+	if scheme := container.ReadinessProbe.HTTPGet.Scheme; scheme != apiv1.URISchemeHTTPS {
+		t.Errorf("expected %v; actual %v", apiv1.URISchemeHTTPS, scheme)
+	}
+}
+
+func TestServiceGraphToKubernetesManifests_LoadClient(t *testing.T) {
+	t.Parallel()
+
+	manifests, err := toManifests(t, `
+services:
+- name: a
+  isEntrypoint: true
+  script:
+  - call: b
+- name: b
+`, true, "--qps", "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var job batchv1.Job
+	var configMap apiv1.ConfigMap
+	for _, doc := range strings.Split(manifests, "---\n") {
+		var meta metav1.TypeMeta
+		if err := yaml.Unmarshal([]byte(doc), &meta); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case meta.Kind == "Job":
+			err = yaml.Unmarshal([]byte(doc), &job)
+		case meta.Kind == "ConfigMap" && strings.Contains(doc, loadClientConfigName):
+			err = yaml.Unmarshal([]byte(doc), &configMap)
+		case meta.Kind == "Deployment" && strings.Contains(doc, "fortio"):
+			t.Errorf("expected no fortio client; actual %s", doc)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if job.Name != "client" || job.Namespace != "default" {
+		t.Fatalf("expected the client Job; actual %v", job.ObjectMeta)
+	}
+	if *job.Spec.BackoffLimit != 0 ||
+		job.Spec.Template.Spec.RestartPolicy != apiv1.RestartPolicyNever {
+		t.Errorf("expected a Job run once; actual %v", job.Spec)
+	}
+	container := job.Spec.Template.Spec.Containers[0]
+	if container.Image != "isotope-converter" {
+		t.Errorf("expected isotope-converter; actual %v", container.Image)
+	}
+	expectedArgs := []string{"load", "/etc/config/service-graph.yaml", "--qps", "100"}
+	if !reflect.DeepEqual(expectedArgs, container.Args) {
+		t.Errorf("expected %v; actual %v", expectedArgs, container.Args)
+	}
+	volume := job.Spec.Template.Spec.Volumes[0]
+	if volume.ConfigMap == nil || volume.ConfigMap.Name != loadClientConfigName {
+		t.Errorf("expected the %s volume; actual %v", loadClientConfigName, volume)
+	}
+
+	// The client reads the whole graph, to find its entrypoints.
+	var g graph.ServiceGraph
+	if err := yaml.Unmarshal(
+		[]byte(configMap.Data[consts.ServiceGraphConfigMapKey]), &g); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Services) != 2 || !g.Services[0].IsEntrypoint {
+		t.Errorf("expected services a and b; actual %v", g.Services)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"path"
+
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+
+	"istio.io/tools/isotope/convert/pkg/consts"
+	"istio.io/tools/isotope/convert/pkg/graph"
+)
+
+// loadClientConfigName is the name of the config map holding the whole graph
+// for the load client, which may share its namespace with services whose
+// config map only holds their slice of the graph.
+const loadClientConfigName = "client-service-graph-config"
+
+// makeLoadClientManifests returns a Job running the load command of the
+// converter image against the entrypoints of serviceGraph, instead of
+// fortio, and the config map it reads the graph from.
+func makeLoadClientManifests(
+	serviceGraph graph.ServiceGraph,
+	nodeSelector map[string]string,
+	clientImage string,
+	namespace string,
+	args []string) ([]interface{}, error) {
+	configMap, err := makeConfigMap(serviceGraph, namespace)
+	if err != nil {
+		return nil, err
+	}
+	configMap.ObjectMeta.Name = loadClientConfigName
+	configMap.ObjectMeta.Labels = fortioClientLabels
+
+	var backoffLimit int32
+	var job batchv1.Job
+	job.APIVersion = "batch/v1"
+	job.Kind = "Job"
+	job.ObjectMeta.Name = "client"
+	job.ObjectMeta.Namespace = namespace
+	job.ObjectMeta.Labels = fortioClientLabels
+	timestamp(&job.ObjectMeta)
+	job.Spec = batchv1.JobSpec{
+		BackoffLimit: &backoffLimit,
+		Template: apiv1.PodTemplateSpec{
+			Spec: apiv1.PodSpec{
+				NodeSelector:  nodeSelector,
+				RestartPolicy: apiv1.RestartPolicyNever,
+				Containers: []apiv1.Container{
+					{
+						Name:  "load-client",
+						Image: clientImage,
+						Args: append([]string{
+							"load", path.Join(consts.ConfigPath, consts.ServiceGraphYAMLFileName),
+						}, args...),
+						VolumeMounts: []apiv1.VolumeMount{
+							{
+								Name:      configVolume,
+								MountPath: consts.ConfigPath,
+							},
+						},
+					},
+				},
+				Volumes: []apiv1.Volume{
+					{
+						Name: configVolume,
+						VolumeSource: apiv1.VolumeSource{
+							ConfigMap: &apiv1.ConfigMapVolumeSource{
+								LocalObjectReference: apiv1.LocalObjectReference{
+									Name: loadClientConfigName,
+								},
+								Items: []apiv1.KeyToPath{
+									{
+										Key:  consts.ServiceGraphConfigMapKey,
+										Path: consts.ServiceGraphYAMLFileName,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	job.Spec.Template.ObjectMeta.Labels = fortioClientLabels
+	timestamp(&job.Spec.Template.ObjectMeta)
+	return []interface{}{configMap, job}, nil
+}
@@ -0,0 +1,232 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/tools/isotope/convert/pkg/consts"
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/svc"
+	"istio.io/tools/isotope/convert/pkg/graph/svctype"
+)
+
+// multiclusterGatewayPort is the port on which the gateway of each cluster
+// routes calls from other clusters, by SNI, to its services.
+const multiclusterGatewayPort = 15443
+
+// maxServiceEntries is the number of hosts, each with its own 240.0.x.y
+// address, the services of a cluster can call in other clusters.
+const maxServiceEntries = 1<<16 - 1
+
+// ServiceGraphToClusterManifests converts a ServiceGraph to the Kubernetes
+// manifests of each of its clusters, by cluster name. Every service must have
+// a cluster. The manifests of clusters calling services in other clusters
+// include ServiceEntries sending those calls to the gateways of the other
+// clusters, whose addresses are given by cluster in clusterGateways. Only the
+// manifests of the cluster hosting the entrypoints include the client.
+func ServiceGraphToClusterManifests(
+	serviceGraph graph.ServiceGraph,
+	serviceNodeSelector map[string]string,
+	serviceImage string,
+	serviceMaxIdleConnectionsPerHost int,
+	clientNodeSelector map[string]string,
+	clientImage string,
+	clientNamespace string,
+	environmentName string,
+	clientDisabled bool,
+	istio bool,
+	loadClient bool,
+	loadClientArgs []string,
+	clusterGateways map[string]string) (map[string][]byte, error) {
+	services := make(map[string]svc.Service, len(serviceGraph.Services))
+	for _, service := range serviceGraph.Services {
+		if service.Cluster == "" {
+			return nil, fmt.Errorf("service %s has no cluster", service.Name)
+		}
+		services[service.Name] = service
+	}
+	clientCluster, err := entrypointCluster(serviceGraph, services)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterManifests := map[string][]byte{}
+	for _, service := range serviceGraph.Services {
+		if _, ok := clusterManifests[service.Cluster]; ok {
+			continue
+		}
+
+		manifests, err := ServiceGraphToKubernetesManifests(
+			serviceGraph, serviceNodeSelector, serviceImage,
+			serviceMaxIdleConnectionsPerHost, clientNodeSelector, clientImage,
+			clientNamespace, environmentName, service.Cluster,
+			clientDisabled || service.Cluster != clientCluster,
+			istio, loadClient, loadClientArgs)
+		if err != nil {
+			return nil, err
+		}
+
+		serviceEntries, err := makeServiceEntries(
+			serviceGraph, service.Cluster, clusterGateways)
+		if err != nil {
+			return nil, err
+		}
+		for _, serviceEntry := range serviceEntries {
+			yamlDoc, err := yaml.Marshal(serviceEntry)
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, "---\n"...)
+			manifests = append(manifests, yamlDoc...)
+		}
+		clusterManifests[service.Cluster] = manifests
+	}
+	return clusterManifests, nil
+}
+
+// entrypointCluster returns the cluster of the entrypoints of serviceGraph,
+// which the client calls by their names in that cluster.
+func entrypointCluster(
+	serviceGraph graph.ServiceGraph, services map[string]svc.Service) (
+	string, error) {
+	cluster := ""
+	for _, name := range serviceGraph.Entrypoints() {
+		entrypoint := services[name]
+		if cluster != "" && entrypoint.Cluster != cluster {
+			return "", fmt.Errorf(
+				"entrypoints are in clusters %s and %s; the client can only call one",
+				cluster, entrypoint.Cluster)
+		}
+		cluster = entrypoint.Cluster
+	}
+	return cluster, nil
+}
+
+// makeServiceEntries returns a ServiceEntry for each host the services in
+// cluster call services in other clusters with, sending the calls to the
+// gateway of the other cluster. The gateways route them by the host, which
+// must be "<name>.<namespace>.global".
+func makeServiceEntries(
+	serviceGraph graph.ServiceGraph, cluster string,
+	clusterGateways map[string]string) ([]istioResource, error) {
+	services := make(map[string]svc.Service, len(serviceGraph.Services))
+	for _, service := range serviceGraph.Services {
+		services[service.Name] = service
+	}
+	requests := serviceGraph.Requests()
+
+	var serviceEntries []istioResource
+	hosts := map[string]bool{}
+	for _, caller := range serviceGraph.Services {
+		if caller.Cluster != cluster {
+			continue
+		}
+		for _, request := range requests[caller.Name] {
+			callee := services[request.ServiceName]
+			if callee.Cluster == cluster {
+				continue
+			}
+			host, port, err := splitHostname(request.Hostname)
+			if err != nil || host != globalHost(callee) {
+				return nil, fmt.Errorf(
+					"%s calls %s in cluster %s with hostname %q instead of %s:<port>",
+					caller.Name, callee.Name, callee.Cluster, request.Hostname,
+					globalHost(callee))
+			}
+			if hosts[host] {
+				continue
+			}
+			if len(hosts) == maxServiceEntries {
+				return nil, fmt.Errorf(
+					"services in cluster %s call more than %d hosts in other clusters",
+					cluster, maxServiceEntries)
+			}
+			hosts[host] = true
+
+			gateway, ok := clusterGateways[callee.Cluster]
+			if !ok {
+				return nil, fmt.Errorf(
+					"no gateway address for cluster %s, called by %s",
+					callee.Cluster, caller.Name)
+			}
+			portName, protocol := consts.ServicePortName, "HTTP"
+			if callee.Type == svctype.ServiceGRPC {
+				portName, protocol = consts.ServiceGRPCPortName, "GRPC"
+			}
+			serviceEntries = append(serviceEntries, istioResource{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: istioNetworkingAPIVersion, Kind: "ServiceEntry"},
+				ObjectMeta: serviceEntryObjectMeta(host, caller.Namespace),
+				Spec: serviceEntrySpec{
+					Hosts: []string{host},
+					// Each host needs its own address, which is never routed:
+					// the proxies intercept the calls to it.
+					Addresses: []string{
+						fmt.Sprintf("240.0.%d.%d", len(hosts)/256, len(hosts)%256)},
+					Ports: []istioPort{
+						{Number: port, Protocol: protocol, Name: portName}},
+					Location:   "MESH_INTERNAL",
+					Resolution: "DNS",
+					Endpoints: []workloadEntry{{
+						Address: gateway,
+						Ports:   map[string]uint32{portName: multiclusterGatewayPort},
+					}},
+				},
+			})
+		}
+	}
+	return serviceEntries, nil
+}
+
+// globalHost returns the host with which services in other clusters call
+// service, e.g. "b.demo.global".
+func globalHost(service svc.Service) string {
+	namespace := service.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	return fmt.Sprintf("%s.%s.global", service.Name, namespace)
+}
+
+func serviceEntryObjectMeta(host string, namespace string) metav1.ObjectMeta {
+	objectMeta := metav1.ObjectMeta{
+		Name:      strings.ReplaceAll(host, ".", "-"),
+		Namespace: namespace,
+		Labels:    serviceGraphAppLabels,
+	}
+	timestamp(&objectMeta)
+	return objectMeta
+}
+
+// splitHostname splits the hostname of a call, e.g. "b.demo.global:8080", into
+// host and port.
+func splitHostname(hostname string) (string, uint32, error) {
+	host, portString, err := net.SplitHostPort(hostname)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.ParseUint(portString, 10, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port in %s: %v", hostname, err)
+	}
+	return host, uint32(port), nil
+}
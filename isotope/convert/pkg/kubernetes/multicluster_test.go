@@ -0,0 +1,117 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+)
+
+// toClusterManifests converts the service graph in graphYAML to the manifests
+// of each of its clusters, with a load client.
+func toClusterManifests(t *testing.T, graphYAML string) (map[string][]byte, error) {
+	t.Helper()
+	var g graph.ServiceGraph
+	if err := yaml.Unmarshal([]byte(graphYAML), &g); err != nil {
+		t.Fatal(err)
+	}
+	return ServiceGraphToClusterManifests(
+		g, nil, "isotope-service", 32, nil, "isotope-converter", "default",
+		"NONE", false, true, true, nil,
+		map[string]string{"cluster1": "10.0.0.1", "cluster2": "10.0.0.2"})
+}
+
+func TestServiceGraphToClusterManifests_Client(t *testing.T) {
+	clusterManifests, err := toClusterManifests(t, `
+services:
+- name: a
+  cluster: cluster1
+  script:
+  - call:
+      service: b
+      hostname: b.default.global:8080
+- name: b
+  cluster: cluster2
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for cluster, expected := range map[string]bool{"cluster1": true, "cluster2": false} {
+		actual := strings.Contains(string(clusterManifests[cluster]), "kind: Job")
+		if actual != expected {
+			t.Errorf("expected client in %s %v; actual %v", cluster, expected, actual)
+		}
+	}
+}
+
+func TestServiceGraphToClusterManifests_EntrypointsInClusters(t *testing.T) {
+	_, err := toClusterManifests(t, `
+services:
+- name: a
+  cluster: cluster1
+  isEntrypoint: true
+- name: b
+  cluster: cluster2
+  isEntrypoint: true
+`)
+	if err == nil {
+		t.Error("expected an error for entrypoints in two clusters")
+	}
+}
+
+func TestServiceGraphToClusterManifests_Hostname(t *testing.T) {
+	tests := []struct {
+		hostname string
+		valid    bool
+	}{
+		{"b.demo.global:8080", true},
+		{"b.global:8080", false},
+		{"b:8080", false},
+		{"b.demo.svc.cluster.local:8080", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.hostname, func(t *testing.T) {
+			t.Parallel()
+
+			clusterManifests, err := toClusterManifests(t, `
+services:
+- name: a
+  cluster: cluster1
+  script:
+  - call:
+      service: b
+      hostname: "`+test.hostname+`"
+- name: b
+  namespace: demo
+  cluster: cluster2
+`)
+			if (err == nil) != test.valid {
+				t.Fatalf("expected valid %v; actual error %v", test.valid, err)
+			}
+			if test.valid && !strings.Contains(
+				string(clusterManifests["cluster1"]), "- b.demo.global") {
+				t.Errorf("expected a ServiceEntry for b.demo.global; actual %s",
+					clusterManifests["cluster1"])
+			}
+		})
+	}
+}
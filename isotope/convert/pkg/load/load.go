@@ -0,0 +1,382 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package load sends requests to the entrypoints of a service graph, at a
+// scheduled rate or in a closed loop, and reports their latency.
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+	"istio.io/tools/isotope/convert/pkg/histogram"
+)
+
+// idlePoll is how often idle closed-loop connections check the schedule.
+const idlePoll = 10 * time.Millisecond
+
+// Options configure a load test.
+type Options struct {
+	// Schedule gives the rate of requests, per second, over time or, in
+	// closed-loop mode, the number of connections sending requests.
+	Schedule Schedule
+	// ClosedLoop sends requests back to back from each connection, instead
+	// of at the rate of the schedule.
+	ClosedLoop bool
+	// Connections is the number of connections sending requests at the rate
+	// of the schedule. Requests due while all of them are busy wait for one,
+	// and their latency counts from when they were due.
+	Connections int
+	// Timeout bounds each request; 0 means no timeout.
+	Timeout time.Duration
+
+	// Seeds sends each request with a random seed, which services draw the
+	// random decisions of the request from.
+	Seeds bool
+	// Record, if set, receives the Arrival of each request as a JSON line.
+	Record io.Writer
+	// Replay sends the requests of a recording at the same times from
+	// Connections connections, instead of following the schedule.
+	Replay []Arrival
+	// ReplayDecisions sends the replayed requests with their recorded seeds,
+	// so that services make the same decisions as when they were recorded.
+	ReplayDecisions bool
+}
+
+// Arrival is a request of a load test, as recorded in Options.Record.
+type Arrival struct {
+	// At is when the request was due, from the start of the test.
+	At      duration.Duration `json:"at"`
+	Service string            `json:"service"`
+	// Seed is the seed sent with the request, if any.
+	Seed    string            `json:"seed,omitempty"`
+	Latency duration.Duration `json:"latency"`
+	Code    string            `json:"code"`
+}
+
+// ReadArrivals reads the JSON lines of a recording.
+func ReadArrivals(r io.Reader) ([]Arrival, error) {
+	var arrivals []Arrival
+	decoder := json.NewDecoder(r)
+	for {
+		var arrival Arrival
+		err := decoder.Decode(&arrival)
+		if err == io.EOF {
+			return arrivals, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		arrivals = append(arrivals, arrival)
+	}
+}
+
+// ErrEmptySchedule is returned when the schedule has no duration.
+var ErrEmptySchedule = errors.New("schedule must have a positive duration")
+
+// ErrNonPositiveConnections is returned when requests are sent at a rate from
+// no connections.
+var ErrNonPositiveConnections = errors.New("connections must be positive")
+
+// Report is the outcome of a load test.
+type Report struct {
+	// Duration is how long the test took, including requests in flight at
+	// the end of the schedule.
+	Duration duration.Duration `json:"duration"`
+	Requests uint64            `json:"requests"`
+	Errors   uint64            `json:"errors"`
+	// QPS is the number of requests completed per second.
+	QPS float64 `json:"qps"`
+	// Codes counts the HTTP statuses and gRPC code names of responses, and
+	// requests without response as "none".
+	Codes   map[string]uint64 `json:"codes"`
+	Latency histogram.Summary `json:"latency"`
+	Targets []TargetReport    `json:"targets"`
+}
+
+// TargetReport describes the requests sent to a target.
+type TargetReport struct {
+	Service  string            `json:"service"`
+	Requests uint64            `json:"requests"`
+	Errors   uint64            `json:"errors"`
+	Latency  histogram.Summary `json:"latency"`
+}
+
+// Run sends requests to targets as configured by opts until the end of the
+// schedule, or of the replayed requests, or until ctx is done.
+func Run(ctx context.Context, targets []Target, opts Options) (Report, error) {
+	replay := len(opts.Replay) > 0
+	if !replay && opts.Schedule.Duration() <= 0 {
+		return Report{}, ErrEmptySchedule
+	}
+	closedLoop := opts.ClosedLoop && !replay
+	connections := opts.Connections
+	if closedLoop {
+		connections = int(math.Ceil(maxTarget(opts.Schedule)))
+	} else if connections <= 0 {
+		return Report{}, ErrNonPositiveConnections
+	}
+	var replayed []request
+	if replay {
+		var err error
+		if replayed, err = replayRequests(targets, opts.Replay, opts.ReplayDecisions); err != nil {
+			return Report{}, err
+		}
+	}
+
+	senders := make([]sender, 0, len(targets))
+	defer func() {
+		for _, s := range senders {
+			s.close()
+		}
+	}()
+	for _, target := range targets {
+		s, err := newSender(target, connections)
+		if err != nil {
+			return Report{}, err
+		}
+		senders = append(senders, s)
+	}
+
+	r := newRecorder(targets, opts.Record)
+	start := time.Now()
+	// next makes a request due at offset, to a random target.
+	next := func(random *rand.Rand, offset time.Duration) request {
+		req := request{at: offset, target: choose(targets, random)}
+		if opts.Seeds {
+			req.seed = strconv.FormatUint(random.Uint64(), 10)
+		}
+		return req
+	}
+	// send sends req and records its latency since it was due.
+	send := func(req request) {
+		requestCtx := ctx
+		if opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			requestCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+		}
+		code, err := senders[req.target].send(requestCtx, req.seed)
+		r.record(req, time.Since(start.Add(req.at)), code, err != nil)
+	}
+
+	var wg sync.WaitGroup
+	if closedLoop {
+		for c := 0; c < connections; c++ {
+			wg.Add(1)
+			go func(c int) {
+				defer wg.Done()
+				runClosedLoop(ctx, opts.Schedule, start, c, next, send)
+			}(c)
+		}
+	} else {
+		due := make(chan request)
+		for c := 0; c < connections; c++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for req := range due {
+					send(req)
+				}
+			}()
+		}
+		if replay {
+			dispatch(ctx, start, due, func(n int) (request, bool) {
+				if n >= len(replayed) {
+					return request{}, false
+				}
+				return replayed[n], true
+			})
+		} else {
+			random := newRand(connections)
+			dispatch(ctx, start, due, func(n int) (request, bool) {
+				at, ok := opts.Schedule.Arrival(n)
+				if !ok {
+					return request{}, false
+				}
+				return next(random, at), true
+			})
+		}
+		close(due)
+	}
+	wg.Wait()
+
+	return r.report(time.Since(start)), nil
+}
+
+// request is a request to send to a target, due at an offset from the start
+// of a test.
+type request struct {
+	at     time.Duration
+	target int
+	// seed is sent in consts.ServiceSeedHeader if set.
+	seed string
+}
+
+// replayRequests returns the requests to send to targets to replay arrivals,
+// in order, with their seeds if decisions is set.
+func replayRequests(targets []Target, arrivals []Arrival, decisions bool) ([]request, error) {
+	indexes := make(map[string]int, len(targets))
+	for i, target := range targets {
+		indexes[target.Service] = i
+	}
+	requests := make([]request, 0, len(arrivals))
+	for _, arrival := range arrivals {
+		i, ok := indexes[arrival.Service]
+		if !ok {
+			return nil, UnknownServiceError{arrival.Service}
+		}
+		req := request{at: time.Duration(arrival.At), target: i}
+		if decisions {
+			req.seed = arrival.Seed
+		}
+		requests = append(requests, req)
+	}
+	sort.SliceStable(requests, func(i, j int) bool { return requests[i].at < requests[j].at })
+	return requests, nil
+}
+
+// dispatch sends the n-th request made by next to due when it is due, until
+// next returns false.
+func dispatch(
+	ctx context.Context, start time.Time, due chan<- request,
+	next func(n int) (request, bool)) {
+	for n := 0; ; n++ {
+		req, ok := next(n)
+		if !ok {
+			return
+		}
+		select {
+		case <-time.After(time.Until(start.Add(req.at))):
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case due <- req:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runClosedLoop sends requests back to back from connection c while the
+// schedule has more than c connections.
+func runClosedLoop(
+	ctx context.Context, s Schedule, start time.Time, c int,
+	next func(*rand.Rand, time.Duration) request, send func(request)) {
+	random := newRand(c)
+	end := start.Add(s.Duration())
+	for ctx.Err() == nil {
+		now := time.Now()
+		if !now.Before(end) {
+			return
+		}
+		if float64(c) >= s.At(now.Sub(start)) {
+			select {
+			case <-time.After(min(idlePoll, end.Sub(now))):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		send(next(random, now.Sub(start)))
+	}
+}
+
+func newRand(c int) *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano() + int64(c)))
+}
+
+func maxTarget(s Schedule) (target float64) {
+	for _, stage := range s {
+		target = max(target, stage.Target)
+	}
+	return
+}
+
+// recorder records the outcome of requests from concurrent connections.
+type recorder struct {
+	mu      sync.Mutex
+	targets []Target
+	total   histogram.Histogram
+	latency []histogram.Histogram
+	errors  []uint64
+	codes   map[string]uint64
+	// arrivals receives the Arrival of each request, if set.
+	arrivals *json.Encoder
+}
+
+func newRecorder(targets []Target, arrivals io.Writer) *recorder {
+	r := &recorder{
+		targets: targets,
+		latency: make([]histogram.Histogram, len(targets)),
+		errors:  make([]uint64, len(targets)),
+		codes:   make(map[string]uint64),
+	}
+	if arrivals != nil {
+		r.arrivals = json.NewEncoder(arrivals)
+	}
+	return r
+}
+
+func (r *recorder) record(req request, latency time.Duration, code string, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.total.Record(latency)
+	r.latency[req.target].Record(latency)
+	r.codes[code]++
+	if failed {
+		r.errors[req.target]++
+	}
+	if r.arrivals != nil {
+		// A failure to record is not worth interrupting the test for.
+		_ = r.arrivals.Encode(Arrival{
+			At:      duration.Duration(req.at),
+			Service: r.targets[req.target].Service,
+			Seed:    req.seed,
+			Latency: duration.Duration(latency),
+			Code:    code,
+		})
+	}
+}
+
+func (r *recorder) report(elapsed time.Duration) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := Report{
+		Duration: duration.Duration(elapsed),
+		Requests: r.total.Count(),
+		QPS:      float64(r.total.Count()) / elapsed.Seconds(),
+		Codes:    r.codes,
+		Latency:  r.total.Summary(),
+	}
+	for i, target := range r.targets {
+		report.Errors += r.errors[i]
+		report.Targets = append(report.Targets, TargetReport{
+			Service:  target.Service,
+			Requests: r.latency[i].Count(),
+			Errors:   r.errors[i],
+			Latency:  r.latency[i].Summary(),
+		})
+	}
+	return report
+}
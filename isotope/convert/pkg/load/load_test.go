@@ -0,0 +1,248 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/tools/isotope/convert/pkg/consts"
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+)
+
+func TestRun_FixedRate(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1)%4 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	targets := []Target{{
+		Service: "a",
+		Address: strings.TrimPrefix(server.URL, "http://"),
+		Weight:  1,
+	}}
+	report, err := Run(context.Background(), targets, Options{
+		Schedule:    ConstantSchedule(200, 0, 500*time.Millisecond),
+		Connections: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Requests != 100 {
+		t.Errorf("expected 100 requests; actual %v", report.Requests)
+	}
+	if report.Errors != 25 || report.Codes["500"] != 25 || report.Codes["200"] != 75 {
+		t.Errorf("expected 25 errors; actual %v (codes %v)", report.Errors, report.Codes)
+	}
+	if len(report.Targets) != 1 || report.Targets[0].Requests != 100 {
+		t.Errorf("expected 100 requests to a; actual %v", report.Targets)
+	}
+}
+
+func TestRun_ClosedLoop(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	targets := []Target{{
+		Service: "a",
+		Address: strings.TrimPrefix(server.URL, "http://"),
+		Weight:  1,
+	}}
+	report, err := Run(context.Background(), targets, Options{
+		Schedule:   ConstantSchedule(2, 0, 300*time.Millisecond),
+		ClosedLoop: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two connections send a request every 10ms or so.
+	if report.Requests < 20 || report.Requests > 60 {
+		t.Errorf("expected about 60 requests; actual %v", report.Requests)
+	}
+	if report.Latency.Min < duration.Duration(10*time.Millisecond) {
+		t.Errorf("expected latencies of at least 10ms; actual %v", report.Latency.Min)
+	}
+}
+
+func TestRun_GRPC(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var methods atomic.Int64
+	server := grpc.NewServer(grpc.UnknownServiceHandler(
+		func(_ interface{}, stream grpc.ServerStream) error {
+			if method, _ := grpc.MethodFromServerStream(stream); method == consts.ServiceGRPCMethod {
+				methods.Add(1)
+			}
+			in := new(wrapperspb.BytesValue)
+			if err := stream.RecvMsg(in); err != nil {
+				return err
+			}
+			return stream.SendMsg(&wrapperspb.BytesValue{})
+		}))
+	go server.Serve(listener)
+	defer server.Stop()
+
+	targets := []Target{{Service: "a", Address: listener.Addr().String(), GRPC: true, Weight: 1}}
+	report, err := Run(context.Background(), targets, Options{
+		Schedule:    ConstantSchedule(100, 0, 200*time.Millisecond),
+		Connections: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Requests != 20 || report.Codes["OK"] != 20 || methods.Load() != 20 {
+		t.Errorf("expected 20 OK calls; actual %v (codes %v, calls %v)",
+			report.Requests, report.Codes, methods.Load())
+	}
+}
+
+func TestRun_RecordReplay(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var seeds []string
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		seeds = append(seeds, r.Header.Get(consts.ServiceSeedHeader))
+	}))
+	defer server.Close()
+	// sent returns the seeds received since the last call, sorted.
+	sent := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		s := seeds
+		seeds = nil
+		sort.Strings(s)
+		return s
+	}
+
+	address := strings.TrimPrefix(server.URL, "http://")
+	targets := []Target{{Service: "a", Address: address, Weight: 1}, {Service: "b", Address: address, Weight: 1}}
+	var recording bytes.Buffer
+	if _, err := Run(context.Background(), targets, Options{
+		Schedule:    ConstantSchedule(100, 0, 200*time.Millisecond),
+		Connections: 2,
+		Seeds:       true,
+		Record:      &recording,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	recorded := sent()
+
+	arrivals, err := ReadArrivals(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arrivals) != 20 {
+		t.Fatalf("expected 20 arrivals; actual %v", len(arrivals))
+	}
+	for i, arrival := range arrivals {
+		if arrival.Seed == "" || arrival.Code != "200" {
+			t.Errorf("expected a seeded 200 at %d; actual %v", i, arrival)
+		}
+	}
+
+	report, err := Run(context.Background(), targets, Options{
+		Connections:     2,
+		Replay:          arrivals,
+		ReplayDecisions: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, target := range report.Targets {
+		var expected uint64
+		for _, arrival := range arrivals {
+			if arrival.Service == target.Service {
+				expected++
+			}
+		}
+		if target.Requests != expected {
+			t.Errorf("expected %v requests to %s; actual %v", expected, targets[i].Service, target.Requests)
+		}
+	}
+	if replayed := sent(); !reflect.DeepEqual(recorded, replayed) {
+		t.Errorf("expected seeds %v; actual %v", recorded, replayed)
+	}
+
+	if _, err := Run(context.Background(), targets, Options{
+		Connections: 2,
+		Replay:      arrivals,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, seed := range sent() {
+		if seed != "" {
+			t.Errorf("expected no seed without ReplayDecisions; actual %v", seed)
+		}
+	}
+}
+
+func TestRun_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		opts Options
+		err  error
+	}{
+		{Options{Connections: 1}, ErrEmptySchedule},
+		{Options{Schedule: ConstantSchedule(1, 0, time.Second)}, ErrNonPositiveConnections},
+		{
+			Options{Connections: 1, Replay: []Arrival{{Service: "a"}}},
+			UnknownServiceError{"a"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := Run(context.Background(), nil, test.opts); test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+		})
+	}
+}
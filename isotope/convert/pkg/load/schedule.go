@@ -0,0 +1,122 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stage ramps the target of a Schedule linearly, over Duration, from the
+// target of the previous stage, or 0 for the first one, to Target. A stage
+// with no duration steps to its target.
+type Stage struct {
+	Duration time.Duration `json:"duration"`
+	Target   float64       `json:"target"`
+}
+
+// Schedule is a sequence of stages giving a target, a request rate or a
+// number of connections, over time.
+type Schedule []Stage
+
+// ConstantSchedule ramps up to target over rampUp then holds it for d.
+func ConstantSchedule(target float64, rampUp time.Duration, d time.Duration) Schedule {
+	return Schedule{{Duration: rampUp, Target: target}, {Duration: d, Target: target}}
+}
+
+// ParseSchedule parses stages written as comma-separated duration:target
+// pairs, e.g. "30s:100,5m:100,30s:0".
+func ParseSchedule(s string) (Schedule, error) {
+	var schedule Schedule
+	for _, field := range strings.Split(s, ",") {
+		d, target, ok := strings.Cut(strings.TrimSpace(field), ":")
+		if !ok {
+			return nil, InvalidStageError{field, "must be duration:target"}
+		}
+		duration, err := time.ParseDuration(d)
+		if err != nil || duration < 0 {
+			return nil, InvalidStageError{field, "duration must be non-negative"}
+		}
+		t, err := strconv.ParseFloat(target, 64)
+		if err != nil || t < 0 || math.IsInf(t, 0) {
+			return nil, InvalidStageError{field, "target must be a non-negative number"}
+		}
+		schedule = append(schedule, Stage{duration, t})
+	}
+	return schedule, nil
+}
+
+// InvalidStageError is returned when a stage of a schedule cannot be parsed.
+type InvalidStageError struct {
+	Stage  string
+	Reason string
+}
+
+func (e InvalidStageError) Error() string {
+	return fmt.Sprintf("invalid stage %q: %s", e.Stage, e.Reason)
+}
+
+// Duration returns the total duration of the stages of s.
+func (s Schedule) Duration() (total time.Duration) {
+	for _, stage := range s {
+		total += stage.Duration
+	}
+	return
+}
+
+// At returns the target at t, or 0 after the end of s.
+func (s Schedule) At(t time.Duration) float64 {
+	from := 0.0
+	for _, stage := range s {
+		if t < stage.Duration {
+			return from + (stage.Target-from)*float64(t)/float64(stage.Duration)
+		}
+		t -= stage.Duration
+		from = stage.Target
+	}
+	return 0
+}
+
+// Arrival returns when the n-th request is due, counting from 0, if the
+// targets of s are rates in requests per second, or false if it is due after
+// the end of s.
+func (s Schedule) Arrival(n int) (time.Duration, bool) {
+	var start time.Duration
+	from := 0.0
+	remaining := float64(n)
+	for _, stage := range s {
+		seconds := stage.Duration.Seconds()
+		// Requests due during the stage.
+		due := (from + stage.Target) / 2 * seconds
+		if remaining < due {
+			// Solve a*t^2 + b*t = remaining for the time t into the stage,
+			// in a form which holds when a is 0.
+			a := (stage.Target - from) / (2 * seconds)
+			b := from
+			t := 0.0
+			if remaining > 0 {
+				t = 2 * remaining / (b + math.Sqrt(b*b+4*a*remaining))
+			}
+			return start + time.Duration(t*float64(time.Second)), true
+		}
+		remaining -= due
+		start += stage.Duration
+		from = stage.Target
+	}
+	return 0, false
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input    string
+		schedule Schedule
+		err      error
+	}{
+		{
+			"30s:100, 1m:100,10s:0",
+			Schedule{{30 * time.Second, 100}, {time.Minute, 100}, {10 * time.Second, 0}},
+			nil,
+		},
+		{"0s:5.5", Schedule{{0, 5.5}}, nil},
+		{"30s", nil, InvalidStageError{"30s", "must be duration:target"}},
+		{"-1s:10", nil, InvalidStageError{"-1s:10", "duration must be non-negative"}},
+		{"1s:x", nil, InvalidStageError{"1s:x", "target must be a non-negative number"}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.input, func(t *testing.T) {
+			t.Parallel()
+
+			schedule, err := ParseSchedule(test.input)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if !reflect.DeepEqual(test.schedule, schedule) {
+				t.Errorf("expected %v; actual %v", test.schedule, schedule)
+			}
+		})
+	}
+}
+
+func TestSchedule_At(t *testing.T) {
+	t.Parallel()
+
+	s := Schedule{{time.Second, 100}, {time.Second, 100}, {0, 10}, {time.Second, 30}}
+	tests := []struct {
+		at     time.Duration
+		target float64
+	}{
+		{0, 0},
+		{250 * time.Millisecond, 25},
+		{1500 * time.Millisecond, 100},
+		{2 * time.Second, 10},
+		{2500 * time.Millisecond, 20},
+		{3 * time.Second, 0},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.at.String(), func(t *testing.T) {
+			t.Parallel()
+
+			if target := s.At(test.at); math.Abs(test.target-target) > 1e-9 {
+				t.Errorf("expected %v; actual %v", test.target, target)
+			}
+		})
+	}
+}
+
+func TestSchedule_Arrival(t *testing.T) {
+	t.Parallel()
+
+	// 50 requests during the ramp, then 100 per second.
+	s := ConstantSchedule(100, time.Second, time.Second)
+	tests := []struct {
+		n  int
+		at time.Duration
+		ok bool
+	}{
+		{0, 0, true},
+		{8, 400 * time.Millisecond, true},
+		{50, time.Second, true},
+		{100, 1500 * time.Millisecond, true},
+		{149, 1990 * time.Millisecond, true},
+		{150, 0, false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			at, ok := s.Arrival(test.n)
+			if ok != test.ok || (at-test.at).Abs() > time.Microsecond {
+				t.Errorf("expected %v, %v; actual %v, %v", test.at, test.ok, at, ok)
+			}
+		})
+	}
+}
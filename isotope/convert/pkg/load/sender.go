@@ -0,0 +1,119 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/tools/isotope/convert/pkg/consts"
+)
+
+// codeNoResponse is the code of requests which got no response.
+const codeNoResponse = "none"
+
+// sender sends requests to a target.
+type sender interface {
+	// send sends a request, with seed if set, and returns its code, an HTTP
+	// status or a gRPC code name, and an error if it failed.
+	send(ctx context.Context, seed string) (string, error)
+	close() error
+}
+
+// newSender returns a sender to target, opening at most connections
+// connections to it.
+func newSender(target Target, connections int) (sender, error) {
+	if target.GRPC {
+		conn, err := grpc.NewClient(
+			target.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, err
+		}
+		return grpcSender{conn}, nil
+	}
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxConnsPerHost:     connections,
+		MaxIdleConnsPerHost: connections,
+	}
+	return httpSender{
+		client: &http.Client{Transport: transport},
+		url:    fmt.Sprintf("http://%s/", target.Address),
+	}, nil
+}
+
+type httpSender struct {
+	client *http.Client
+	url    string
+}
+
+func (s httpSender) send(ctx context.Context, seed string) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return codeNoResponse, err
+	}
+	if seed != "" {
+		request.Header.Set(consts.ServiceSeedHeader, seed)
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return codeNoResponse, err
+	}
+	defer response.Body.Close()
+	// Drain the body to let the Transport reuse the connection.
+	if _, err := io.Copy(io.Discard, response.Body); err != nil {
+		return codeNoResponse, err
+	}
+	code := strconv.Itoa(response.StatusCode)
+	if response.StatusCode != http.StatusOK {
+		return code, fmt.Errorf("%s responded with %s", s.url, response.Status)
+	}
+	return code, nil
+}
+
+func (s httpSender) close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// grpcSender calls the gRPC method of a service over a single HTTP/2
+// connection, like a gRPC client would.
+type grpcSender struct {
+	conn *grpc.ClientConn
+}
+
+func (s grpcSender) send(ctx context.Context, seed string) (string, error) {
+	if seed != "" {
+		ctx = metadata.AppendToOutgoingContext(
+			ctx, strings.ToLower(consts.ServiceSeedHeader), seed)
+	}
+	err := s.conn.Invoke(
+		ctx, consts.ServiceGRPCMethod, &wrapperspb.BytesValue{}, new(wrapperspb.BytesValue))
+	return status.Code(err).String(), err
+}
+
+func (s grpcSender) close() error {
+	return s.conn.Close()
+}
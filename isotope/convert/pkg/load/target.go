@@ -0,0 +1,125 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"istio.io/tools/isotope/convert/pkg/consts"
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/svctype"
+)
+
+// Target is an entrypoint of a service graph to send requests to.
+type Target struct {
+	Service string
+	// Address is the host and port of the service.
+	Address string
+	// GRPC is set to call the gRPC method of the service instead of sending
+	// "GET /".
+	GRPC bool
+	// Weight is the share of requests sent to the target.
+	Weight float64
+}
+
+// Targets returns the targets to load g with. Requests are split between the
+// services in weights, or evenly between the entrypoints of g if weights is
+// empty. Services are reached at their address in addresses or, by default,
+// on the service port of their name qualified by their namespace.
+func Targets(
+	g graph.ServiceGraph, weights map[string]float64,
+	addresses map[string]string) ([]Target, error) {
+	names := make(map[string]bool, len(g.Services))
+	for _, service := range g.Services {
+		names[service.Name] = true
+	}
+	for name, weight := range weights {
+		if !names[name] {
+			return nil, UnknownServiceError{name}
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("weight of %s must be non-negative", name)
+		}
+	}
+	for name := range addresses {
+		if !names[name] {
+			return nil, UnknownServiceError{name}
+		}
+	}
+
+	if len(weights) == 0 {
+		weights = make(map[string]float64)
+		for _, name := range g.Entrypoints() {
+			weights[name] = 1
+		}
+	}
+	var targets []Target
+	var total float64
+	for _, service := range g.Services {
+		weight, ok := weights[service.Name]
+		if !ok {
+			continue
+		}
+		address, ok := addresses[service.Name]
+		if !ok {
+			host := service.Name
+			if service.Namespace != "" {
+				host += "." + service.Namespace
+			}
+			address = fmt.Sprintf("%s:%d", host, consts.ServicePort)
+		}
+		targets = append(targets, Target{
+			Service: service.Name,
+			Address: address,
+			GRPC:    service.Type == svctype.ServiceGRPC,
+			Weight:  weight,
+		})
+		total += weight
+	}
+	if total == 0 {
+		return nil, ErrNoTargets
+	}
+	return targets, nil
+}
+
+// ErrNoTargets is returned when no service has a positive weight.
+var ErrNoTargets = errors.New("no service to send requests to")
+
+// UnknownServiceError is returned when a target is not a service of the graph.
+type UnknownServiceError struct {
+	Name string
+}
+
+func (e UnknownServiceError) Error() string {
+	return fmt.Sprintf("service %s is not in the graph", e.Name)
+}
+
+// choose picks one of targets by weight.
+func choose(targets []Target, r *rand.Rand) int {
+	var total float64
+	for _, t := range targets {
+		total += t.Weight
+	}
+	x := r.Float64() * total
+	for i, t := range targets {
+		if x < t.Weight {
+			return i
+		}
+		x -= t.Weight
+	}
+	return len(targets) - 1
+}
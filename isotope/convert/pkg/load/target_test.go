@@ -0,0 +1,99 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+)
+
+func TestTargets(t *testing.T) {
+	t.Parallel()
+
+	var g graph.ServiceGraph
+	if err := yaml.Unmarshal([]byte(`
+services:
+- name: a
+  namespace: ns1
+  script:
+  - call: c
+- name: b
+  type: grpc
+  script:
+  - call: c
+- name: c
+`), &g); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		weights   map[string]float64
+		addresses map[string]string
+		targets   []Target
+		err       error
+	}{
+		{
+			name: "entrypoints",
+			targets: []Target{
+				{Service: "a", Address: "a.ns1:8080", Weight: 1},
+				{Service: "b", Address: "b:8080", GRPC: true, Weight: 1},
+			},
+		},
+		{
+			name:      "weights",
+			weights:   map[string]float64{"a": 3, "c": 1},
+			addresses: map[string]string{"c": "127.0.0.1:9000"},
+			targets: []Target{
+				{Service: "a", Address: "a.ns1:8080", Weight: 3},
+				{Service: "c", Address: "127.0.0.1:9000", Weight: 1},
+			},
+		},
+		{
+			name:    "unknown weight",
+			weights: map[string]float64{"d": 1},
+			err:     UnknownServiceError{"d"},
+		},
+		{
+			name:      "unknown address",
+			addresses: map[string]string{"d": "127.0.0.1:9000"},
+			err:       UnknownServiceError{"d"},
+		},
+		{
+			name:    "no weight",
+			weights: map[string]float64{"a": 0},
+			err:     ErrNoTargets,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			targets, err := Targets(g, test.weights, test.addresses)
+			if test.err != err {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+			if !reflect.DeepEqual(test.targets, targets) {
+				t.Errorf("expected %v; actual %v", test.targets, targets)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulate
+
+import (
+	"container/heap"
+	"time"
+)
+
+// event is a function to run at a point of simulated time. Events at the same
+// time run in the order they were scheduled.
+type event struct {
+	at  time.Duration
+	seq uint64
+	run func()
+}
+
+// eventQueue is a min-heap of events ordered by time.
+type eventQueue []event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(event)) }
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// clock advances simulated time from event to event.
+type clock struct {
+	now    time.Duration
+	seq    uint64
+	events eventQueue
+}
+
+// after schedules run to happen d after the current time.
+func (c *clock) after(d time.Duration, run func()) {
+	c.seq++
+	heap.Push(&c.events, event{at: c.now + d, seq: c.seq, run: run})
+}
+
+// run runs events until none are left.
+func (c *clock) run() {
+	for len(c.events) > 0 {
+		e := heap.Pop(&c.events).(event)
+		c.now = e.at
+		e.run()
+	}
+}
@@ -0,0 +1,581 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package simulate predicts the latency of requests to a service graph, and
+// the load they put on its services, with a discrete-event simulation.
+//
+// Services run their scripts as the isotope service does: sleeps and calls
+// take simulated time, compute commands hold a CPU core of the service,
+// allocations are free, and requests fail at the service's error rate, during
+// its error bursts, when a call fails or when it times out. Network time is
+// ignored except for a fixed proxy overhead on each hop, so a simulation with
+// no overhead models the graph without a mesh.
+package simulate
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/analysis"
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/pct"
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+	"istio.io/tools/isotope/convert/pkg/graph/svc"
+	"istio.io/tools/isotope/convert/pkg/histogram"
+)
+
+// Options configure a simulation.
+type Options struct {
+	// Rate is the number of requests per second sent to the entrypoints,
+	// split evenly between them. Requests arrive as a Poisson process.
+	Rate float64
+	// Duration is the simulated time during which requests arrive. Requests
+	// still in flight at the end run to completion.
+	Duration time.Duration
+	// ProxyOverhead is the latency added to each request from the client to
+	// an entrypoint and between two services, e.g. by their sidecars.
+	ProxyOverhead time.Duration
+	// Seed seeds the random decisions, so that a simulation can be repeated.
+	Seed int64
+}
+
+// ErrNonPositiveRate is returned when the arrival rate is not positive.
+var ErrNonPositiveRate = errors.New("rate must be positive")
+
+// ErrNonPositiveDuration is returned when the duration is not positive.
+var ErrNonPositiveDuration = errors.New("duration must be positive")
+
+// ErrNegativeProxyOverhead is returned when the proxy overhead is negative.
+var ErrNegativeProxyOverhead = errors.New("proxy overhead must be non-negative")
+
+// CycleError is returned when services of the graph call each other in a
+// cycle, which a simulation could follow forever.
+type CycleError struct {
+	Services []string
+}
+
+func (e CycleError) Error() string {
+	return "services call each other in a cycle: " + strings.Join(e.Services, ", ")
+}
+
+// Result is the outcome of a simulation. Rates are per second of the
+// duration of the simulation.
+type Result struct {
+	Entrypoints []EntrypointResult `json:"entrypoints"`
+	Services    []ServiceResult    `json:"services"`
+	Edges       []EdgeResult       `json:"edges"`
+}
+
+// EntrypointResult describes the requests sent by the client to an
+// entrypoint. Latencies include failed requests.
+type EntrypointResult struct {
+	Service  string            `json:"service"`
+	Requests int               `json:"requests"`
+	Errors   int               `json:"errors"`
+	Latency  histogram.Summary `json:"latency"`
+}
+
+// ServiceResult describes the requests received by a service.
+type ServiceResult struct {
+	Service string `json:"service"`
+	// Rate is the number of requests received per second.
+	Rate float64 `json:"rate"`
+	// ErrorRate is the fraction of requests which failed.
+	ErrorRate pct.Percentage `json:"errorRate"`
+	// CPUUtilization is the fraction of the CPU limit of the replicas used by
+	// compute commands. It is left unset for services without a CPU limit,
+	// whose compute commands never wait for a core.
+	CPUUtilization float64 `json:"cpuUtilization,omitempty"`
+}
+
+// EdgeResult describes the calls from a service to another, counting every
+// attempt.
+type EdgeResult struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Rate is the number of attempts per second.
+	Rate float64 `json:"rate"`
+	// ErrorRate is the fraction of attempts which failed or timed out.
+	ErrorRate pct.Percentage `json:"errorRate"`
+}
+
+// Simulate sends requests to the entrypoints of g, which must be valid, as
+// configured by opts.
+func Simulate(g graph.ServiceGraph, opts Options) (Result, error) {
+	if opts.Rate <= 0 {
+		return Result{}, ErrNonPositiveRate
+	}
+	if opts.Duration <= 0 {
+		return Result{}, ErrNonPositiveDuration
+	}
+	if opts.ProxyOverhead < 0 {
+		return Result{}, ErrNegativeProxyOverhead
+	}
+	if cycles := analysis.Analyze(g).Cycles; len(cycles) > 0 {
+		return Result{}, CycleError{cycles[0]}
+	}
+
+	s := simulator{
+		opts:      opts,
+		rand:      rand.New(rand.NewSource(opts.Seed)),
+		services:  make(map[string]*service, len(g.Services)),
+		edges:     make(map[edgeKey]*counter),
+		latencies: make(map[string]*histogram.Histogram),
+	}
+	for _, service := range g.Services {
+		simulated, err := newService(service)
+		if err != nil {
+			return Result{}, err
+		}
+		s.services[service.Name] = simulated
+	}
+	entrypoints := g.Entrypoints()
+	if len(entrypoints) == 0 {
+		return Result{}, nil
+	}
+
+	s.clock.after(s.interarrival(), func() { s.arrive(entrypoints) })
+	s.clock.run()
+	return s.result(g, entrypoints), nil
+}
+
+// service is a service of the graph with the state of its simulation.
+type service struct {
+	name     string
+	versions []version
+	requests counter
+}
+
+// version is a deployment of a service: the service itself or one of its
+// versions, sharing the CPU cores of its replicas.
+type version struct {
+	service svc.Service
+	weight  float64
+	cpu     *cpuPool
+}
+
+func newService(s svc.Service) (*service, error) {
+	simulated := &service{name: s.Name}
+	if len(s.Versions) == 0 {
+		simulated.versions = []version{{service: s, weight: 1, cpu: newCPUPool(s)}}
+		return simulated, nil
+	}
+	for _, v := range s.Versions {
+		versioned, err := s.WithVersion(v.Name)
+		if err != nil {
+			return nil, err
+		}
+		weight := float64(v.NumReplicas)
+		if s.Istio != nil && len(s.Istio.TrafficSplit) > 0 {
+			weight = float64(s.Istio.TrafficSplit[v.Name])
+		}
+		simulated.versions = append(simulated.versions,
+			version{service: versioned, weight: weight, cpu: newCPUPool(versioned)})
+	}
+	return simulated, nil
+}
+
+// cpuPool is the CPU cores of the replicas of a version, which compute
+// commands hold in turn.
+type cpuPool struct {
+	// cores is the number of cores, rounded to the nearest whole core, or 0
+	// if the replicas have no CPU limit.
+	cores   int
+	busy    int
+	waiting []func()
+	// used is the CPU time used during the simulation.
+	used time.Duration
+}
+
+// newCPUPool returns the cores of the replicas of s left by their background
+// CPU usage.
+func newCPUPool(s svc.Service) *cpuPool {
+	if s.Resources == nil || s.Resources.Limits.CPU == nil {
+		return &cpuPool{}
+	}
+	limit := float64(s.Resources.Limits.CPU.MilliValue()) / 1000
+	replicas := math.Max(float64(s.NumReplicas), 1)
+	cores := int(math.Round(replicas * math.Max(limit-s.BackgroundCPU, 0)))
+	return &cpuPool{cores: max(cores, 1)}
+}
+
+// counter counts requests and those which failed.
+type counter struct {
+	requests int
+	errors   int
+}
+
+func (c *counter) record(failed bool) {
+	if failed {
+		c.errors++
+	}
+}
+
+type edgeKey struct {
+	from string
+	to   string
+}
+
+type simulator struct {
+	opts     Options
+	rand     *rand.Rand
+	clock    clock
+	services map[string]*service
+	edges    map[edgeKey]*counter
+	// entrypoints counts the requests of the client to each entrypoint, and
+	// latencies records how long they took.
+	entrypoints map[string]*counter
+	latencies   map[string]*histogram.Histogram
+}
+
+// interarrival draws the time until the next request of the client.
+func (s *simulator) interarrival() time.Duration {
+	return time.Duration(s.rand.ExpFloat64() / s.opts.Rate * float64(time.Second))
+}
+
+// arrive sends a request to one of entrypoints and schedules the next
+// arrival.
+func (s *simulator) arrive(entrypoints []string) {
+	if s.entrypoints == nil {
+		s.entrypoints = make(map[string]*counter, len(entrypoints))
+		for _, name := range entrypoints {
+			s.entrypoints[name] = &counter{}
+			s.latencies[name] = &histogram.Histogram{}
+		}
+	}
+	name := entrypoints[s.rand.Intn(len(entrypoints))]
+	c := s.entrypoints[name]
+	c.requests++
+	start := s.clock.now
+	s.clock.after(s.opts.ProxyOverhead, func() {
+		s.serve(s.services[name], http.MethodGet, "/", func(failed bool) {
+			c.record(failed)
+			s.latencies[name].Record(s.clock.now - start)
+		})
+	})
+
+	if next := s.interarrival(); s.clock.now+next < s.opts.Duration {
+		s.clock.after(next, func() { s.arrive(entrypoints) })
+	}
+}
+
+// serve handles a request to method and path of service, calling done once it
+// responds.
+func (s *simulator) serve(service *service, method string, path string, done func(failed bool)) {
+	service.requests.requests++
+	v := s.chooseVersion(service)
+	respond := func(failed bool) {
+		service.requests.record(failed)
+		done(failed)
+	}
+
+	behaviour, errorRate := v.service.Script, v.service.ErrorRate
+	if key, ok := v.service.MatchRoute(method, path); ok {
+		route := v.service.Routes[key]
+		behaviour, errorRate = route.Script, route.ErrorRate
+	}
+	if s.shouldFail(v.service, errorRate) {
+		respond(true)
+		return
+	}
+	s.runScript(v, behaviour, respond)
+}
+
+// chooseVersion picks the version serving a request, weighted by the traffic
+// split or the replicas of the versions.
+func (s *simulator) chooseVersion(service *service) *version {
+	if len(service.versions) == 1 {
+		return &service.versions[0]
+	}
+	var total float64
+	for _, v := range service.versions {
+		total += v.weight
+	}
+	x := s.rand.Float64() * total
+	for i := range service.versions {
+		if x < service.versions[i].weight {
+			return &service.versions[i]
+		}
+		x -= service.versions[i].weight
+	}
+	return &service.versions[len(service.versions)-1]
+}
+
+// shouldFail returns true if a request to s should fail, during an error
+// burst or at errorRate. Deterministic error injection fails the same
+// fraction of requests as random injection, so both are simulated randomly.
+func (s *simulator) shouldFail(service svc.Service, errorRate pct.Percentage) bool {
+	if service.ErrorInjection != nil {
+		now := time.Unix(0, int64(s.clock.now))
+		if burst, ok := service.ErrorInjection.ActiveBurst(now); ok {
+			return s.rand.Float64() < float64(burst.Rate)
+		}
+	}
+	return errorRate > 0 && s.rand.Float64() < float64(errorRate)
+}
+
+// runScript runs cmds one after the other, stopping at the first failure.
+func (s *simulator) runScript(v *version, cmds []script.Command, done func(failed bool)) {
+	var next func(i int)
+	next = func(i int) {
+		if i == len(cmds) {
+			done(false)
+			return
+		}
+		s.runCommand(v, cmds[i], func(failed bool) {
+			if failed {
+				done(true)
+				return
+			}
+			next(i + 1)
+		})
+	}
+	next(0)
+}
+
+func (s *simulator) runCommand(v *version, cmd script.Command, done func(failed bool)) {
+	switch cmd := cmd.(type) {
+	case script.SleepCommand:
+		s.clock.after(time.Duration(cmd), func() { done(false) })
+	case script.SleepDistributionCommand:
+		s.clock.after(cmd.Distribution.Sample(s.rand), func() { done(false) })
+	case script.ComputeCommand:
+		s.compute(v.cpu, time.Duration(cmd.CPU), done)
+	case script.RequestCommand:
+		s.call(v.service.Name, cmd, done)
+	case script.ConcurrentCommand:
+		s.parallel(len(cmd), len(cmd), func(i int, done func(bool)) {
+			s.runCommand(v, cmd[i], done)
+		}, done)
+	case script.SequenceCommand:
+		s.runScript(v, cmd, done)
+	case script.RepeatCommand:
+		body := make([]script.Command, 0, len(cmd.Body))
+		for times := cmd.Times(s.rand); times > 0; times-- {
+			body = append(body, script.SequenceCommand(cmd.Body))
+		}
+		s.runScript(v, body, done)
+	case script.ChooseCommand:
+		s.runScript(v, cmd.Choose(s.rand.Float64()), done)
+	case script.FanoutCommand:
+		concurrency := cmd.Concurrency
+		if concurrency <= 0 {
+			concurrency = cmd.Count
+		}
+		s.parallel(cmd.Count, concurrency, func(_ int, done func(bool)) {
+			s.call(v.service.Name, cmd.Call, done)
+		}, done)
+	default:
+		done(false)
+	}
+}
+
+// compute holds a core of cpu for d, waiting for one to be free if needed.
+func (s *simulator) compute(cpu *cpuPool, d time.Duration, done func(failed bool)) {
+	if cpu.cores == 0 {
+		s.clock.after(d, func() { done(false) })
+		return
+	}
+	start := func() {
+		cpu.busy++
+		// Only count the time used while requests arrive.
+		cpu.used += min(s.clock.now+d, s.opts.Duration) - min(s.clock.now, s.opts.Duration)
+		s.clock.after(d, func() {
+			cpu.busy--
+			if len(cpu.waiting) > 0 {
+				next := cpu.waiting[0]
+				cpu.waiting = cpu.waiting[1:]
+				next()
+			}
+			done(false)
+		})
+	}
+	if cpu.busy < cpu.cores {
+		start()
+		return
+	}
+	cpu.waiting = append(cpu.waiting, start)
+}
+
+// parallel runs n tasks, at most limit at a time, and calls done once all of
+// them are done, with whether any failed.
+func (s *simulator) parallel(
+	n int, limit int, run func(i int, done func(failed bool)), done func(failed bool)) {
+	if n == 0 {
+		done(false)
+		return
+	}
+	started, finished, anyFailed := 0, 0, false
+	var start func()
+	start = func() {
+		i := started
+		started++
+		run(i, func(failed bool) {
+			anyFailed = anyFailed || failed
+			finished++
+			if finished == n {
+				done(anyFailed)
+			} else if started < n {
+				start()
+			}
+		})
+	}
+	for started < min(n, limit) {
+		start()
+	}
+}
+
+// call sends cmd from the service named from, like the isotope service: it
+// may skip the call, retries failed attempts and then falls back or
+// continues as cmd says.
+func (s *simulator) call(from string, cmd script.RequestCommand, done func(failed bool)) {
+	if cmd.Probability != 0 && s.rand.Intn(100) < 100-cmd.Probability {
+		done(false)
+		return
+	}
+	s.callWithRetries(from, cmd, func(failed bool) {
+		if !failed {
+			done(false)
+			return
+		}
+		if fallback, ok := cmd.FallbackCommand(); ok {
+			s.callWithRetries(from, fallback, done)
+			return
+		}
+		done(cmd.OnError != script.ErrorPolicyContinue)
+	})
+}
+
+// callWithRetries sends cmd, retrying up to cmd.Retries.Attempts times with
+// an exponential backoff.
+func (s *simulator) callWithRetries(from string, cmd script.RequestCommand, done func(failed bool)) {
+	var attempts int
+	var backoff time.Duration
+	if cmd.Retries != nil {
+		attempts = cmd.Retries.Attempts
+		backoff = time.Duration(cmd.Retries.Backoff)
+	}
+	var attempt func(n int, backoff time.Duration)
+	attempt = func(n int, backoff time.Duration) {
+		s.attempt(from, cmd, func(failed bool) {
+			if !failed || n >= attempts {
+				done(failed)
+				return
+			}
+			s.clock.after(backoff, func() { attempt(n+1, 2*backoff) })
+		})
+	}
+	attempt(0, backoff)
+}
+
+// attempt sends a single request for cmd, which fails after cmd.Timeout. The
+// callee keeps serving a request which timed out.
+func (s *simulator) attempt(from string, cmd script.RequestCommand, done func(failed bool)) {
+	edge := s.edges[edgeKey{from, cmd.ServiceName}]
+	if edge == nil {
+		edge = &counter{}
+		s.edges[edgeKey{from, cmd.ServiceName}] = edge
+	}
+	edge.requests++
+
+	answered := false
+	respond := func(failed bool) {
+		if answered {
+			return
+		}
+		answered = true
+		edge.record(failed)
+		done(failed)
+	}
+
+	callee, ok := s.services[cmd.ServiceName]
+	if !ok {
+		respond(true)
+		return
+	}
+	if timeout := time.Duration(cmd.Timeout); timeout > 0 {
+		s.clock.after(timeout, func() { respond(true) })
+	}
+	path := cmd.Path.Render(s.rand.Intn)
+	if path == "" {
+		path = "/"
+	}
+	s.clock.after(s.opts.ProxyOverhead, func() {
+		s.serve(callee, cmd.HTTPMethod(), path, respond)
+	})
+}
+
+func (s *simulator) result(g graph.ServiceGraph, entrypoints []string) Result {
+	seconds := s.opts.Duration.Seconds()
+	var result Result
+	for _, name := range entrypoints {
+		c, latencies := s.entrypoints[name], s.latencies[name]
+		if c == nil {
+			c, latencies = &counter{}, &histogram.Histogram{}
+		}
+		result.Entrypoints = append(result.Entrypoints, EntrypointResult{
+			Service:  name,
+			Requests: c.requests,
+			Errors:   c.errors,
+			Latency:  latencies.Summary(),
+		})
+	}
+
+	for _, service := range g.Services {
+		simulated := s.services[service.Name]
+		r := ServiceResult{
+			Service:   service.Name,
+			Rate:      float64(simulated.requests.requests) / seconds,
+			ErrorRate: errorRate(simulated.requests),
+		}
+		var cores int
+		var used time.Duration
+		for _, v := range simulated.versions {
+			cores += v.cpu.cores
+			used += v.cpu.used
+		}
+		if cores > 0 {
+			r.CPUUtilization = used.Seconds() / (float64(cores) * seconds)
+		}
+		result.Services = append(result.Services, r)
+	}
+
+	for key, c := range s.edges {
+		result.Edges = append(result.Edges, EdgeResult{
+			From:      key.from,
+			To:        key.to,
+			Rate:      float64(c.requests) / seconds,
+			ErrorRate: errorRate(*c),
+		})
+	}
+	sort.Slice(result.Edges, func(i, j int) bool {
+		if result.Edges[i].From != result.Edges[j].From {
+			return result.Edges[i].From < result.Edges[j].From
+		}
+		return result.Edges[i].To < result.Edges[j].To
+	})
+	return result
+}
+
+func errorRate(c counter) pct.Percentage {
+	if c.requests == 0 {
+		return 0
+	}
+	return pct.Percentage(float64(c.errors) / float64(c.requests))
+}
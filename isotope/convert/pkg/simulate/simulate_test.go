@@ -0,0 +1,329 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulate
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+)
+
+func simulateYAML(t *testing.T, graphYAML string, opts Options) Result {
+	t.Helper()
+	var g graph.ServiceGraph
+	if err := yaml.Unmarshal([]byte(graphYAML), &g); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Simulate(g, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func approximately(expected float64, actual float64, tolerance float64) bool {
+	return math.Abs(expected-actual) <= tolerance*expected
+}
+
+func TestSimulate(t *testing.T) {
+	t.Parallel()
+
+	result := simulateYAML(t, `
+services:
+- name: a
+  script:
+  - sleep: 10ms
+  - - call: b
+    - call:
+        service: c
+        probability: 25
+- name: b
+  script:
+  - sleep: 5ms
+- name: c
+  script:
+  - sleep: 20ms
+`, Options{Rate: 100, Duration: 100 * time.Second, ProxyOverhead: time.Millisecond})
+
+	if len(result.Entrypoints) != 1 || result.Entrypoints[0].Service != "a" {
+		t.Fatalf("expected a single entrypoint a; actual %v", result.Entrypoints)
+	}
+	e := result.Entrypoints[0]
+	if !approximately(10000, float64(e.Requests), 0.05) {
+		t.Errorf("expected about 10000 requests; actual %v", e.Requests)
+	}
+	if e.Errors != 0 {
+		t.Errorf("expected no errors; actual %v", e.Errors)
+	}
+	// Each hop takes 1ms; c is called by a quarter of the requests.
+	// Latencies are counted within 1%.
+	if p50 := e.Latency.Percentile(50); !approximately(float64(17*time.Millisecond), float64(p50), 0.01) {
+		t.Errorf("expected p50 %v; actual %v", 17*time.Millisecond, p50)
+	}
+	if expected := duration.Duration(32 * time.Millisecond); e.Latency.Max != expected {
+		t.Errorf("expected max %v; actual %v", expected, e.Latency.Max)
+	}
+
+	expectedRates := map[string]float64{"a": 100, "b": 100, "c": 25}
+	for _, s := range result.Services {
+		if !approximately(expectedRates[s.Service], s.Rate, 0.05) {
+			t.Errorf("%s: expected rate %v; actual %v", s.Service, expectedRates[s.Service], s.Rate)
+		}
+	}
+	if len(result.Edges) != 2 ||
+		result.Edges[0].From != "a" || result.Edges[0].To != "b" ||
+		result.Edges[1].From != "a" || result.Edges[1].To != "c" {
+		t.Fatalf("expected edges a->b and a->c; actual %v", result.Edges)
+	}
+	if !approximately(25, result.Edges[1].Rate, 0.05) {
+		t.Errorf("expected a->c rate 25; actual %v", result.Edges[1].Rate)
+	}
+}
+
+func TestSimulate_Failures(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		call      string
+		errorRate float64
+		latency   time.Duration
+		edgeRate  float64
+	}{
+		{
+			name:      "fail",
+			call:      "call: b",
+			errorRate: 0.5,
+			latency:   0,
+			edgeRate:  100,
+		},
+		{
+			name:      "continue",
+			call:      "call: {service: b, onError: continue}",
+			errorRate: 0,
+			latency:   0,
+			edgeRate:  100,
+		},
+		{
+			name:      "retries",
+			call:      "call: {service: b, retries: {attempts: 2}}",
+			errorRate: 0.125,
+			latency:   0,
+			edgeRate:  175,
+		},
+		{
+			name:      "timeout",
+			call:      "call: {service: c, timeout: 10ms, retries: {attempts: 2, backoff: 5ms}}",
+			errorRate: 1,
+			latency:   45 * time.Millisecond,
+			edgeRate:  300,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := simulateYAML(t, `
+services:
+- name: a
+  isEntrypoint: true
+  script:
+  - `+test.call+`
+- name: b
+  errorRate: 50%
+- name: c
+  script:
+  - sleep: 100ms
+`, Options{Rate: 100, Duration: 100 * time.Second})
+
+			e := result.Entrypoints[0]
+			errorRate := float64(e.Errors) / float64(e.Requests)
+			if math.Abs(test.errorRate-errorRate) > 0.02 {
+				t.Errorf("expected error rate %v; actual %v", test.errorRate, errorRate)
+			}
+			if expected := duration.Duration(test.latency); e.Latency.Max != expected {
+				t.Errorf("expected latency %v; actual %v", expected, e.Latency.Max)
+			}
+			if !approximately(test.edgeRate, result.Edges[0].Rate, 0.05) {
+				t.Errorf("expected edge rate %v; actual %v", test.edgeRate, result.Edges[0].Rate)
+			}
+		})
+	}
+}
+
+func TestSimulate_Concurrency(t *testing.T) {
+	t.Parallel()
+
+	result := simulateYAML(t, `
+services:
+- name: a
+  script:
+  - - sleep: 10ms
+    - sleep: 30ms
+  - fanout:
+      service: b
+      count: 5
+      concurrency: 2
+- name: b
+  script:
+  - sleep: 10ms
+`, Options{Rate: 10, Duration: 10 * time.Second})
+
+	// The fan-out takes three batches.
+	h := result.Entrypoints[0].Latency
+	if expected := duration.Duration(60 * time.Millisecond); h.Percentile(50) != expected || h.Max != expected {
+		t.Errorf("expected %v; actual p50 %v and max %v", expected, h.Percentile(50), h.Max)
+	}
+}
+
+func TestSimulate_CPU(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rate        float64
+		utilization float64
+		saturated   bool
+	}{
+		{rate: 20, utilization: 0.1},
+		{rate: 150, utilization: 0.75},
+		{rate: 400, utilization: 1, saturated: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			result := simulateYAML(t, `
+services:
+- name: a
+  numReplicas: 2
+  resources:
+    limits: {cpu: 1}
+  script:
+  - compute: {cpu: 10ms}
+`, Options{Rate: test.rate, Duration: 100 * time.Second})
+
+			utilization := result.Services[0].CPUUtilization
+			if !approximately(test.utilization, utilization, 0.05) {
+				t.Errorf("expected utilization %v; actual %v", test.utilization, utilization)
+			}
+			p99 := result.Entrypoints[0].Latency.Percentile(99)
+			saturated := p99 > duration.Duration(time.Second)
+			if saturated != test.saturated {
+				t.Errorf("expected saturated %v; actual p99 %v", test.saturated, p99)
+			}
+		})
+	}
+}
+
+func TestSimulate_Versions(t *testing.T) {
+	t.Parallel()
+
+	result := simulateYAML(t, `
+services:
+- name: a
+  istio:
+    trafficSplit: {v1: 80, v2: 20}
+  versions:
+  - name: v1
+  - name: v2
+    script:
+    - call: b
+- name: b
+`, Options{Rate: 100, Duration: 100 * time.Second})
+
+	if !approximately(20, result.Edges[0].Rate, 0.1) {
+		t.Errorf("expected a->b rate 20; actual %v", result.Edges[0].Rate)
+	}
+}
+
+func TestSimulate_Seed(t *testing.T) {
+	t.Parallel()
+
+	const graphYAML = `
+services:
+- name: a
+  script:
+  - sleep:
+      distribution: lognormal
+      median: 10ms
+      p99: 100ms
+  - call: {service: b, probability: 50}
+- name: b
+  errorRate: 10%
+`
+	opts := Options{Rate: 50, Duration: 10 * time.Second, Seed: 42}
+	first := simulateYAML(t, graphYAML, opts)
+	second := simulateYAML(t, graphYAML, opts)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("expected %v; actual %v", first, second)
+	}
+}
+
+func TestSimulate_Invalid(t *testing.T) {
+	t.Parallel()
+
+	var acyclic, cyclic graph.ServiceGraph
+	if err := yaml.Unmarshal([]byte(`
+services:
+- name: a
+`), &acyclic); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(`
+services:
+- name: a
+  isEntrypoint: true
+  script:
+  - call: b
+- name: b
+  script:
+  - call: a
+`), &cyclic); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		graph graph.ServiceGraph
+		opts  Options
+		err   error
+	}{
+		{acyclic, Options{Duration: time.Second}, ErrNonPositiveRate},
+		{acyclic, Options{Rate: 1}, ErrNonPositiveDuration},
+		{acyclic, Options{Rate: 1, Duration: time.Second, ProxyOverhead: -1}, ErrNegativeProxyOverhead},
+		{cyclic, Options{Rate: 1, Duration: time.Second}, CycleError{[]string{"a", "b"}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Simulate(test.graph, test.opts)
+			if !reflect.DeepEqual(test.err, err) {
+				t.Errorf("expected %v; actual %v", test.err, err)
+			}
+		})
+	}
+}
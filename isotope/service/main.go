@@ -15,23 +15,28 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"path"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"google.golang.org/grpc"
 
 	"istio.io/pkg/log"
 
 	"istio.io/tools/isotope/convert/pkg/consts"
 	"istio.io/tools/isotope/service/pkg/srv"
 	"istio.io/tools/isotope/service/pkg/srv/prometheus"
-)
-
-const (
-	promEndpoint    = "/metrics"
-	defaultEndpoint = "/"
+	"istio.io/tools/isotope/service/pkg/srv/tracing"
 )
 
 var (
@@ -43,10 +48,68 @@ var (
 		"max-idle-connections-per-host", 0,
 		"maximum number of TCP connections to keep open per host")
 
+	// Transport of outgoing calls, set from the service's transport in the
+	// graph by the kubernetes converter.
+	http2Flag = flag.Bool(
+		"http2", false,
+		"send HTTP calls over HTTP/2 (h2c without --tls)")
+	keepAliveFlag = flag.Bool(
+		"keep-alive", true,
+		"reuse connections between calls")
+	maxConnectionsPerHostFlag = flag.Int(
+		"max-connections-per-host", 0,
+		"maximum number of connections per host (0 for no limit)")
+	idleConnectionTimeoutFlag = flag.Duration(
+		"idle-connection-timeout", 0,
+		"close connections idle for this long (0 for the Go default)")
+	tlsFlag = flag.Bool(
+		"tls", false,
+		"call other services over TLS")
+	tlsInsecureSkipVerifyFlag = flag.Bool(
+		"tls-insecure-skip-verify", false,
+		"accept any certificate from other services")
+
+	// Serve over TLS when both are set.
+	tlsCertFile = flag.String(
+		"tls-cert-file", "",
+		"certificate to serve TLS with")
+	tlsKeyFile = flag.String(
+		"tls-key-file", "",
+		"private key to serve TLS with")
+
 	// Set log levels
 	logLevel = flag.String(
 		"log-level", "info",
 		"log level")
+
+	otlpEndpoint = flag.String(
+		"otlp-endpoint", "",
+		"OTLP/HTTP collector (host:port or URL) to export spans to; empty disables tracing")
+	traceSampling = flag.Float64(
+		"trace-sampling", 1,
+		"fraction of new traces to sample")
+
+	watchConfig = flag.Bool(
+		"watch-config", true,
+		"reload the service graph when the config file changes")
+
+	durationBuckets = flag.String(
+		"duration-buckets", "",
+		"comma-separated upper bounds, in seconds, of the duration histogram buckets")
+
+	recordRequests = flag.String(
+		"record-requests", "",
+		"append a JSON line for each request served, with its random decisions, to this file")
+	replayRequests = flag.String(
+		"replay-requests", "",
+		"file recorded by --record-requests to force the decisions of requests with the same seeds from")
+
+	allInOne = flag.Bool(
+		"all-in-one", false,
+		"serve every service of the graph from this process on loopback ports, ignoring SERVICE_NAME")
+	basePort = flag.Int(
+		"base-port", consts.ServicePort,
+		"with --all-in-one, the port of the first service, the next ones using the following ports (0 for free ports)")
 )
 
 var stringToLevel = map[string]log.Level{
@@ -64,12 +127,46 @@ func main() {
 		s.SetOutputLevel(stringToLevel[*logLevel])
 	}
 
+	if *durationBuckets != "" {
+		buckets, err := parseBuckets(*durationBuckets)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		prometheus.SetDurationBuckets(buckets)
+	}
+
 	setMaxProcs()
-	setMaxIdleConnectionsPerHost(*maxIdleConnectionsPerHostFlag)
+	transportOptions := srv.TransportOptions{
+		HTTP2:                     *http2Flag,
+		KeepAlive:                 *keepAliveFlag,
+		MaxConnectionsPerHost:     *maxConnectionsPerHostFlag,
+		MaxIdleConnectionsPerHost: *maxIdleConnectionsPerHostFlag,
+		IdleConnectionTimeout:     *idleConnectionTimeoutFlag,
+		TLS:                       *tlsFlag,
+		TLSInsecureSkipVerify:     *tlsInsecureSkipVerifyFlag,
+	}
 
-	serviceName, ok := os.LookupEnv(consts.ServiceNameEnvKey)
-	if !ok {
-		log.Fatalf(`env var "%s" is not set`, consts.ServiceNameEnvKey)
+	if *recordRequests != "" {
+		f, err := os.OpenFile(*recordRequests, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		defer f.Close()
+		srv.RecordRequests(f)
+		log.Infof(`recording requests to "%s"`, *recordRequests)
+	}
+
+	if *replayRequests != "" {
+		f, err := os.Open(*replayRequests)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		err = srv.ReplayRequests(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		log.Infof(`replaying requests from "%s"`, *replayRequests)
 	}
 
 	configPath, ok := os.LookupEnv(consts.ConfigPathEnvKey)
@@ -78,31 +175,150 @@ func main() {
 	}
 	log.Infof(`Config file path: "%s"`, serviceGraphYAMLFilePath)
 
+	// Interrupting the service lets the requests in flight finish.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *allInOne {
+		if err := serveAllInOne(ctx, transportOptions); err != nil {
+			log.Fatalf("%s", err)
+		}
+		return
+	}
+
+	srv.ConfigureTransport(transportOptions)
+
+	serviceName, ok := os.LookupEnv(consts.ServiceNameEnvKey)
+	if !ok {
+		log.Fatalf(`env var "%s" is not set`, consts.ServiceNameEnvKey)
+	}
+
+	serviceVersion := os.Getenv(consts.ServiceVersionEnvKey)
+
+	if *otlpEndpoint != "" {
+		shutdown, err := tracing.Setup(
+			context.Background(), *otlpEndpoint, serviceName, *traceSampling)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		defer shutdown(context.Background())
+		log.Infof(`exporting spans to "%s"`, *otlpEndpoint)
+	}
+
 	defaultHandler, err := srv.HandlerFromServiceGraphYAML(
-		serviceGraphYAMLFilePath, serviceName)
+		serviceGraphYAMLFilePath, serviceName, serviceVersion)
 	if err != nil {
 		log.Fatalf("%s", err)
 	}
 
-	err = serveWithPrometheus(defaultHandler)
+	if *watchConfig {
+		go func() {
+			err := defaultHandler.WatchServiceGraphYAML(
+				ctx, serviceGraphYAMLFilePath, serviceName,
+				serviceVersion)
+			if err != nil {
+				log.Errorf("not watching config: %s", err)
+			}
+		}()
+	}
+
+	defaultHandler.StartBackgroundCPU(ctx)
+
+	grpcServer := srv.NewGRPCServer(defaultHandler)
+
+	err = serveWithPrometheus(ctx, defaultHandler, grpcServer)
 	if err != nil {
 		log.Fatalf("%s", err)
 	}
 }
 
-func serveWithPrometheus(defaultHandler http.Handler) error {
-	log.Infof(`exposing Prometheus endpoint "%s"`, promEndpoint)
-	http.Handle(promEndpoint, prometheus.Handler())
+// serveWithPrometheus serves defaultHandler and grpcServer until ctx is done,
+// then waits for the requests in flight.
+func serveWithPrometheus(
+	ctx context.Context, defaultHandler http.Handler, grpcServer *grpc.Server) error {
+	log.Infof(`exposing Prometheus endpoint "%s"`, srv.PrometheusEndpoint)
+	log.Infof(`exposing health endpoint "%s"`, consts.ServiceHealthPath)
+	log.Infof(`exposing gRPC method "%s"`, srv.GRPCCallMethod)
+	handler := srv.NewServeMux(defaultHandler, grpcServer)
 
-	log.Infof(`exposing default endpoint "%s"`, defaultEndpoint)
-	http.Handle(defaultEndpoint, defaultHandler)
+	server := &http.Server{Addr: fmt.Sprintf(":%d", consts.ServicePort), Handler: handler}
+	shutdown := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		log.Info("shutting down")
+		shutdown <- server.Shutdown(context.Background())
+	}()
 
-	addr := fmt.Sprintf(":%d", consts.ServicePort)
 	log.Infof("listening on port %v\n", consts.ServicePort)
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	var err error
+	if *tlsCertFile != "" && *tlsKeyFile != "" {
+		log.Info("serving TLS")
+		err = server.ListenAndServeTLS(*tlsCertFile, *tlsKeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
-	return nil
+	err = <-shutdown
+	srv.CloseGRPCConns()
+	return err
+}
+
+// serveAllInOne serves every service of the graph, calling each other through
+// a transport configured by transportOptions, until ctx is done.
+func serveAllInOne(ctx context.Context, transportOptions srv.TransportOptions) error {
+	localGraph, err := srv.LocalGraphFromServiceGraphYAML(
+		serviceGraphYAMLFilePath, *basePort)
+	if err != nil {
+		return err
+	}
+	defer localGraph.Close()
+	localGraph.StartBackgroundCPU(ctx, os.Getenv(consts.ServiceVersionEnvKey))
+
+	transportOptions.Resolve = localGraph.Resolve
+	srv.ConfigureTransport(transportOptions)
+
+	if *otlpEndpoint != "" {
+		shutdown, err := tracing.Setup(
+			context.Background(), *otlpEndpoint, "isotope", *traceSampling)
+		if err != nil {
+			return err
+		}
+		defer shutdown(context.Background())
+		log.Infof(`exporting spans to "%s"`, *otlpEndpoint)
+	}
+
+	names := make([]string, 0, len(localGraph.Addresses))
+	for name := range localGraph.Addresses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Infof("serving %s on %s", name, localGraph.Addresses[name])
+	}
+	go func() {
+		<-ctx.Done()
+		localGraph.Close()
+	}()
+	return localGraph.Serve()
+}
+
+// parseBuckets parses a comma-separated list of increasing bucket bounds.
+func parseBuckets(s string) ([]float64, error) {
+	fields := strings.Split(s, ",")
+	buckets := make([]float64, 0, len(fields))
+	for _, field := range fields {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q: %v", field, err)
+		}
+		if n := len(buckets); n > 0 && bound <= buckets[n-1] {
+			return nil, fmt.Errorf("buckets must be in increasing order: %s", s)
+		}
+		buckets = append(buckets, bound)
+	}
+	return buckets, nil
 }
 
 func setMaxProcs() {
@@ -113,7 +329,3 @@ func setMaxProcs() {
 		runtime.GOMAXPROCS(numCPU)
 	}
 }
-
-func setMaxIdleConnectionsPerHost(n int) {
-	http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost = n
-}
@@ -0,0 +1,218 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"istio.io/tools/isotope/convert/pkg/consts"
+	"istio.io/tools/isotope/convert/pkg/graph/size"
+)
+
+// decider makes the random decisions of a request. A request carrying a seed
+// in consts.ServiceSeedHeader draws its decisions, and the seeds of the calls
+// it makes, from that seed, so requests with the same seed make the same
+// decisions down the whole graph. A service with a seed mixes it in, and
+// seeds requests without one from their ID or, without an ID, from their
+// order. Other requests draw from the global source.
+//
+// A decider may also be forced to make the decisions recorded for a request,
+// still drawing as usual so that the seeds of its calls do not change.
+//
+// A decider is used by one goroutine at a time: concurrent branches get
+// their own with children.
+type decider struct {
+	rand *rand.Rand
+	// decisions are recorded, if recording, to be traced.
+	recording bool
+	decisions []Decision
+	// forced are the decisions left to make, if forcing.
+	forcing bool
+	forced  []Decision
+}
+
+type deciderKey struct{}
+
+// newDecider returns the decider of a request with header to a service with
+// seed, which may be nil, counting the requests it seeds by their order in
+// requests.
+func newDecider(
+	header http.Header, seed *uint64, requests *uint64, recording bool) *decider {
+	d := &decider{recording: recording}
+	called, err := strconv.ParseUint(header.Get(consts.ServiceSeedHeader), 10, 64)
+	requestID := header.Get(consts.ServiceRequestIDHeader)
+	switch {
+	case seed == nil && err == nil:
+		d.rand = newSeededRand(called)
+	case seed != nil && err == nil:
+		d.rand = newSeededRand(mixSeed(*seed, strconv.FormatUint(called, 10)))
+	case seed != nil && requestID != "":
+		d.rand = newSeededRand(mixSeed(*seed, requestID))
+	case seed != nil:
+		n := atomic.AddUint64(requests, 1)
+		d.rand = newSeededRand(mixSeed(*seed, "request "+strconv.FormatUint(n, 10)))
+	}
+	return d
+}
+
+// payloadRand returns the source of the response payload of the route with
+// key, or "" for the service, of a service with seed, or nil if seed is nil.
+func payloadRand(seed *uint64, key string) *rand.Rand {
+	if seed == nil {
+		return nil
+	}
+	return newSeededRand(mixSeed(*seed, "payload "+key))
+}
+
+// newSeededRand returns a source seeded with seed. PCG keeps 16 bytes of
+// state, so that seeding every request and branch is cheap.
+func newSeededRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, 0))
+}
+
+// mixSeed returns a seed derived from seed and key.
+func mixSeed(seed uint64, key string) uint64 {
+	h := fnv.New64a()
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], seed)
+	h.Write(b[:])
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+func withDecider(ctx context.Context, d *decider) context.Context {
+	return context.WithValue(ctx, deciderKey{}, d)
+}
+
+// deciderFrom returns the decider of ctx, or an unseeded one.
+func deciderFrom(ctx context.Context) *decider {
+	if d, ok := ctx.Value(deciderKey{}).(*decider); ok {
+		return d
+	}
+	return &decider{}
+}
+
+func (d *decider) seeded() bool {
+	return d.rand != nil
+}
+
+func (d *decider) Float64() float64 {
+	if d.rand == nil {
+		return rand.Float64()
+	}
+	return d.rand.Float64()
+}
+
+func (d *decider) NormFloat64() float64 {
+	if d.rand == nil {
+		return rand.NormFloat64()
+	}
+	return d.rand.NormFloat64()
+}
+
+func (d *decider) ExpFloat64() float64 {
+	if d.rand == nil {
+		return rand.ExpFloat64()
+	}
+	return d.rand.ExpFloat64()
+}
+
+func (d *decider) Intn(n int) int {
+	if d.rand == nil {
+		return rand.IntN(n)
+	}
+	return d.rand.IntN(n)
+}
+
+// seed draws the seed of a call, to be sent in consts.ServiceSeedHeader, or
+// returns false if d is not seeded.
+func (d *decider) seed() (string, bool) {
+	if d.rand == nil {
+		return "", false
+	}
+	return strconv.FormatUint(d.rand.Uint64(), 10), true
+}
+
+// payload makes a request payload of n bytes.
+func (d *decider) payload(n size.ByteSize) ([]byte, error) {
+	return makeRandomByteArray(n, d.rand)
+}
+
+// children returns the deciders of n concurrent branches, forced to make the
+// decisions of the next forced step of concurrent branches if it has n.
+func (d *decider) children(n int) []*decider {
+	var branches [][]Decision
+	if len(d.forced) > 0 && len(d.forced[0].Branches) == n && n > 0 {
+		branches = d.forced[0].Branches
+		d.forced = d.forced[1:]
+	}
+	children := make([]*decider, n)
+	for i := range children {
+		children[i] = &decider{recording: d.recording}
+		if d.rand != nil {
+			children[i].rand = newSeededRand(d.rand.Uint64())
+		}
+		if branches != nil {
+			children[i].force(branches[i])
+		}
+	}
+	return children
+}
+
+// join records the decisions of children, as a step of concurrent branches.
+func (d *decider) join(children []*decider) {
+	if !d.recording {
+		return
+	}
+	branches := make([][]Decision, len(children))
+	for i, child := range children {
+		branches[i] = append([]Decision{}, child.decisions...)
+	}
+	d.decisions = append(d.decisions, Decision{Branches: branches})
+}
+
+func (d *decider) record(format string, args ...interface{}) {
+	if d.recording {
+		d.decisions = append(d.decisions, Decision{Choice: fmt.Sprintf(format, args...)})
+	}
+}
+
+// force makes d make decisions, as recorded, instead of the ones it draws.
+func (d *decider) force(decisions []Decision) {
+	d.forcing = true
+	d.forced = decisions
+}
+
+// forcedChoice returns the value of the next forced decision, e.g. "1" for
+// "choose 1", if it is of kind, and consumes it.
+func (d *decider) forcedChoice(kind string) (string, bool) {
+	if len(d.forced) == 0 {
+		return "", false
+	}
+	k, value, _ := strings.Cut(d.forced[0].Choice, " ")
+	if k != kind {
+		return "", false
+	}
+	d.forced = d.forced[1:]
+	return value, true
+}
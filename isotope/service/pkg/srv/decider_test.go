@@ -0,0 +1,143 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"istio.io/tools/isotope/convert/pkg/consts"
+)
+
+// draws returns the next n draws of d.
+func draws(d *decider, n int) []float64 {
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = d.Float64()
+	}
+	return x
+}
+
+func TestNewDecider(t *testing.T) {
+	t.Parallel()
+
+	seed, otherSeed := uint64(42), uint64(43)
+	header := func(key, value string) http.Header {
+		h := http.Header{}
+		h.Set(key, value)
+		return h
+	}
+
+	tests := []struct {
+		name      string
+		header    http.Header
+		seed      *uint64
+		other     http.Header
+		otherSeed *uint64
+		same      bool
+		unseeded  bool
+	}{
+		{"same request ID", header(consts.ServiceRequestIDHeader, "a"), &seed,
+			header(consts.ServiceRequestIDHeader, "a"), &seed, true, false},
+		{"other request ID", header(consts.ServiceRequestIDHeader, "a"), &seed,
+			header(consts.ServiceRequestIDHeader, "b"), &seed, false, false},
+		{"other service seed", header(consts.ServiceRequestIDHeader, "a"), &seed,
+			header(consts.ServiceRequestIDHeader, "a"), &otherSeed, false, false},
+		{"same called seed", header(consts.ServiceSeedHeader, "7"), nil,
+			header(consts.ServiceSeedHeader, "7"), nil, true, false},
+		{"other called seed", header(consts.ServiceSeedHeader, "7"), nil,
+			header(consts.ServiceSeedHeader, "8"), nil, false, false},
+		{"called seed mixed with service seed", header(consts.ServiceSeedHeader, "7"), nil,
+			header(consts.ServiceSeedHeader, "7"), &seed, false, false},
+		{"unseeded", header(consts.ServiceRequestIDHeader, "a"), nil,
+			header(consts.ServiceRequestIDHeader, "a"), nil, false, true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := newDecider(test.header, test.seed, new(uint64), false)
+			other := newDecider(test.other, test.otherSeed, new(uint64), false)
+			if test.unseeded {
+				if d.seeded() || other.seeded() {
+					t.Errorf("expected unseeded deciders")
+				}
+				return
+			}
+			same := reflect.DeepEqual(draws(d, 4), draws(other, 4))
+			if test.same != same {
+				t.Errorf("expected %v; actual %v", test.same, same)
+			}
+		})
+	}
+}
+
+func TestNewDecider_Order(t *testing.T) {
+	t.Parallel()
+
+	// Without an ID, the Nth request to a seeded service makes the same
+	// decisions, and the next one other decisions.
+	seed := uint64(42)
+	var requests, otherRequests uint64
+	first := draws(newDecider(http.Header{}, &seed, &requests, false), 4)
+	second := draws(newDecider(http.Header{}, &seed, &requests, false), 4)
+	if reflect.DeepEqual(first, second) {
+		t.Errorf("expected different decisions; actual %v", second)
+	}
+	if actual := draws(newDecider(http.Header{}, &seed, &otherRequests, false), 4); !reflect.DeepEqual(first, actual) {
+		t.Errorf("expected %v; actual %v", first, actual)
+	}
+}
+
+func TestDecider_Children(t *testing.T) {
+	t.Parallel()
+
+	children := func() [][]float64 {
+		d := &decider{rand: newSeededRand(42)}
+		var x [][]float64
+		for _, child := range d.children(3) {
+			x = append(x, draws(child, 4))
+		}
+		return x
+	}
+
+	expected, actual := children(), children()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v; actual %v", expected, actual)
+	}
+	for i := 1; i < len(expected); i++ {
+		if reflect.DeepEqual(expected[0], expected[i]) {
+			t.Errorf("expected branch %v to differ from branch 0; actual %v", i, expected[i])
+		}
+	}
+
+	if unseeded := (&decider{}).children(2); unseeded[0].seeded() || unseeded[1].seeded() {
+		t.Errorf("expected unseeded children")
+	}
+}
+
+func TestMixSeed(t *testing.T) {
+	t.Parallel()
+
+	if mixSeed(1, "a") != mixSeed(1, "a") {
+		t.Errorf("expected the same seed")
+	}
+	if mixSeed(1, "a") == mixSeed(2, "a") || mixSeed(1, "a") == mixSeed(1, "b") {
+		t.Errorf("expected different seeds")
+	}
+}
@@ -15,81 +15,264 @@
 package srv
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"google.golang.org/grpc/status"
+
 	"istio.io/pkg/log"
+	"istio.io/tools/isotope/convert/pkg/consts"
 	"istio.io/tools/isotope/convert/pkg/graph/script"
 	"istio.io/tools/isotope/convert/pkg/graph/svctype"
 	"istio.io/tools/isotope/service/pkg/srv/prometheus"
+	"istio.io/tools/isotope/service/pkg/srv/tracing"
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
 func execute(
+	ctx context.Context,
 	step interface{},
 	forwardableHeader http.Header,
 	serviceTypes map[string]svctype.ServiceType) error {
 	switch cmd := step.(type) {
 	case script.SleepCommand:
 		executeSleepCommand(cmd)
+	case script.SleepDistributionCommand:
+		executeSleepDistributionCommand(ctx, cmd)
 	case script.RequestCommand:
 		if err := executeRequestCommand(
-			cmd, forwardableHeader, serviceTypes); err != nil {
+			ctx, cmd, forwardableHeader, serviceTypes); err != nil {
 			return err
 		}
 	case script.ConcurrentCommand:
 		if err := executeConcurrentCommand(
-			cmd, forwardableHeader, serviceTypes); err != nil {
+			ctx, cmd, forwardableHeader, serviceTypes); err != nil {
+			return err
+		}
+	case script.SequenceCommand:
+		if err := executeSequenceCommand(
+			ctx, cmd, forwardableHeader, serviceTypes); err != nil {
+			return err
+		}
+	case script.RepeatCommand:
+		if err := executeRepeatCommand(
+			ctx, cmd, forwardableHeader, serviceTypes); err != nil {
 			return err
 		}
+	case script.ChooseCommand:
+		d := deciderFrom(ctx)
+		i := cmd.ChooseIndex(d.Float64())
+		if forced, ok := d.forcedChoice("choose"); ok {
+			if n, err := strconv.Atoi(forced); err == nil && n >= -1 && n < len(cmd) {
+				i = n
+			}
+		}
+		d.record("choose %d", i)
+		if i < 0 {
+			break
+		}
+		if err := executeSequenceCommand(
+			ctx, script.SequenceCommand(cmd[i].Script),
+			forwardableHeader, serviceTypes); err != nil {
+			return err
+		}
+	case script.FanoutCommand:
+		if err := executeFanoutCommand(
+			ctx, cmd, forwardableHeader, serviceTypes); err != nil {
+			return err
+		}
+	case script.ComputeCommand:
+		executeComputeCommand(cmd)
+	case script.AllocateCommand:
+		executeAllocateCommand(ctx, cmd)
 	default:
 		log.Fatalf("unknown command type in script: %T", cmd)
 	}
 	return nil
 }
 
+// commandName names the type of step in metrics.
+func commandName(step interface{}) string {
+	switch step.(type) {
+	case script.SleepCommand, script.SleepDistributionCommand:
+		return "sleep"
+	case script.RequestCommand:
+		return "call"
+	case script.ConcurrentCommand:
+		return "concurrent"
+	case script.SequenceCommand:
+		return "sequence"
+	case script.RepeatCommand:
+		return "repeat"
+	case script.ChooseCommand:
+		return "choose"
+	case script.FanoutCommand:
+		return "fanout"
+	case script.ComputeCommand:
+		return "compute"
+	case script.AllocateCommand:
+		return "allocate"
+	default:
+		return "unknown"
+	}
+}
+
 func executeSleepCommand(cmd script.SleepCommand) {
 	time.Sleep(time.Duration(cmd))
 }
 
-func shouldSkipRequest(cmd script.RequestCommand) bool {
+// executeSleepDistributionCommand draws a new duration from the command's
+// distribution for every request and sleeps for it.
+func executeSleepDistributionCommand(ctx context.Context, cmd script.SleepDistributionCommand) {
+	d := deciderFrom(ctx)
+	duration := cmd.Distribution.Sample(d)
+	if forced, ok := d.forcedChoice("sleep"); ok {
+		if forcedDuration, err := time.ParseDuration(forced); err == nil {
+			duration = forcedDuration
+		}
+	}
+	d.record("sleep %s", duration)
+	time.Sleep(duration)
+}
+
+func shouldSkipRequest(cmd script.RequestCommand, d *decider) bool {
 	// Probability not set, always send a request
 	if cmd.Probability == 0 {
 		return false
 	}
-	return rand.Intn(100) < (100 - cmd.Probability)
+	skip := d.Intn(100) < (100 - cmd.Probability)
+	if d.forcing {
+		// Only skipped requests are recorded.
+		forced, ok := d.forcedChoice("skip")
+		skip = ok && forced == cmd.ServiceName
+	}
+	if skip {
+		d.record("skip %s", cmd.ServiceName)
+	}
+	return skip
 }
 
-// Execute sends an HTTP request to another service. Assumes DNS is available
-// which maps exe.ServiceName to the relevant URL to reach the service.
+// Execute sends an HTTP or gRPC request, depending on the type of the
+// destination, to another service. Assumes DNS is available which maps
+// exe.ServiceName to the relevant URL to reach the service. Failed attempts
+// are retried and, once retries are exhausted, handled by cmd.OnError.
 func executeRequestCommand(
+	ctx context.Context,
 	cmd script.RequestCommand,
 	forwardableHeader http.Header,
 	serviceTypes map[string]svctype.ServiceType,
 ) error {
-	if shouldSkipRequest(cmd) {
+	if shouldSkipRequest(cmd, deciderFrom(ctx)) {
+		return nil
+	}
+
+	err := executeRequestWithRetries(ctx, cmd, forwardableHeader, serviceTypes)
+	if err == nil {
 		return nil
 	}
 
+	if fallback, ok := cmd.FallbackCommand(); ok {
+		log.Debugf("falling back to %s: %s", fallback.ServiceName, err)
+		return executeRequestWithRetries(ctx, fallback, forwardableHeader, serviceTypes)
+	}
+	if cmd.OnError == script.ErrorPolicyContinue {
+		log.Debugf("continuing after failed call: %s", err)
+		return nil
+	}
+	return err
+}
+
+// executeRequestWithRetries sends the request, retrying up to
+// cmd.Retries.Attempts times with an exponential backoff, unless ctx is done.
+func executeRequestWithRetries(
+	ctx context.Context,
+	cmd script.RequestCommand,
+	forwardableHeader http.Header,
+	serviceTypes map[string]svctype.ServiceType,
+) error {
+	var attempts int
+	var backoff time.Duration
+	if cmd.Retries != nil {
+		attempts = cmd.Retries.Attempts
+		backoff = time.Duration(cmd.Retries.Backoff)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := executeRequestAttempt(ctx, cmd, forwardableHeader, serviceTypes)
+		if err == nil || attempt >= attempts {
+			return err
+		}
+		log.Debugf("retrying (%d/%d) after %s: %s", attempt+1, attempts, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// executeRequestAttempt sends a single request, bounded by cmd.Timeout, in a
+// client span.
+func executeRequestAttempt(
+	ctx context.Context,
+	cmd script.RequestCommand,
+	forwardableHeader http.Header,
+	serviceTypes map[string]svctype.ServiceType,
+) (err error) {
 	destName := cmd.Hostname
 	extraHeader := cmd.ExtraHeader
 
-	_, ok := serviceTypes[cmd.ServiceName]
+	serviceType, ok := serviceTypes[cmd.ServiceName]
 	if !ok {
 		return fmt.Errorf("service %s does not exist", destName)
 	}
-	response, err := sendRequest(destName, cmd.Size, extraHeader, forwardableHeader)
+
+	if cmd.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cmd.Timeout))
+		defer cancel()
+	}
+
+	requestHeader := forwardableHeader.Clone()
+	if seed, ok := deciderFrom(ctx).seed(); ok {
+		requestHeader.Set(consts.ServiceSeedHeader, seed)
+	}
+	ctx, span := tracing.StartClientSpan(ctx, cmd.ServiceName, requestHeader)
+	statusCode := 0
+	defer func() {
+		tracing.EndSpan(span, statusCode, err)
+	}()
+
+	startTime := time.Now()
+	recordResponse := prometheus.RecordRequestStarted(destName)
+	code := prometheus.CodeNoResponse
+	defer func() {
+		recordResponse(time.Since(startTime), code)
+	}()
+
+	if serviceType == svctype.ServiceGRPC {
+		err := sendGRPCRequest(ctx, destName, cmd.Size, extraHeader, requestHeader)
+		prometheus.RecordRequestSent(destName, uint64(cmd.Size))
+		code = status.Code(err).String()
+		if err != nil {
+			return fmt.Errorf("service %s responded with %v", destName, err)
+		}
+		log.Debugf("%s responded with OK", destName)
+		return nil
+	}
+
+	response, err := sendRequest(ctx, cmd, requestHeader)
 	if err != nil {
 		return err
 	}
+	statusCode = response.StatusCode
+	code = strconv.Itoa(statusCode)
 
 	// Necessary for reusing HTTP/1.x "keep-alive" TCP connections.
 	// https://golang.org/pkg/net/http/#Response
@@ -113,27 +296,117 @@ func executeRequestCommand(
 	return nil
 }
 
-// executeConcurrentCommand calls each command in exe.Commands asynchronously
-// and waits for each to complete.
+// executeConcurrentCommand calls each command in cmd asynchronously and waits
+// for each to complete.
 func executeConcurrentCommand(
+	ctx context.Context,
 	cmd script.ConcurrentCommand,
 	forwardableHeader http.Header,
 	serviceTypes map[string]svctype.ServiceType) error {
 	numSubCmds := len(cmd)
 	wg := sync.WaitGroup{}
 	wg.Add(numSubCmds)
-	var errs []string
-	for _, subCmd := range cmd {
-		go func(step interface{}) {
+	var (
+		errs      []string
+		errsMutex sync.Mutex
+	)
+	d := deciderFrom(ctx)
+	children := d.children(numSubCmds)
+	for i, subCmd := range cmd {
+		go func(ctx context.Context, step interface{}) {
+			defer wg.Done()
+
+			err := execute(ctx, step, forwardableHeader, serviceTypes)
+			if err != nil {
+				errsMutex.Lock()
+				errs = append(errs, err.Error())
+				errsMutex.Unlock()
+			}
+		}(withDecider(ctx, children[i]), subCmd)
+	}
+	wg.Wait()
+	d.join(children)
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d errors occurred: %v", len(errs), strings.Join(errs, ", "))
+}
+
+// executeSequenceCommand calls each command in cmd in order, stopping at the
+// first which fails.
+func executeSequenceCommand(
+	ctx context.Context,
+	cmd script.SequenceCommand,
+	forwardableHeader http.Header,
+	serviceTypes map[string]svctype.ServiceType) error {
+	for _, step := range cmd {
+		if err := execute(ctx, step, forwardableHeader, serviceTypes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeRepeatCommand calls the body of cmd as many times as it draws,
+// stopping at the first failure.
+func executeRepeatCommand(
+	ctx context.Context,
+	cmd script.RepeatCommand,
+	forwardableHeader http.Header,
+	serviceTypes map[string]svctype.ServiceType) error {
+	d := deciderFrom(ctx)
+	times := cmd.Times(d)
+	if forced, ok := d.forcedChoice("repeat"); ok {
+		if n, err := strconv.Atoi(forced); err == nil && n >= 0 {
+			times = n
+		}
+	}
+	d.record("repeat %d", times)
+	for i := 0; i < times; i++ {
+		if err := executeSequenceCommand(
+			ctx, script.SequenceCommand(cmd.Body), forwardableHeader, serviceTypes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeFanoutCommand sends the request of cmd cmd.Count times, with at most
+// cmd.Concurrency in flight, and waits for each to complete.
+func executeFanoutCommand(
+	ctx context.Context,
+	cmd script.FanoutCommand,
+	forwardableHeader http.Header,
+	serviceTypes map[string]svctype.ServiceType) error {
+	concurrency := cmd.Concurrency
+	if concurrency <= 0 || concurrency > cmd.Count {
+		concurrency = cmd.Count
+	}
+	slots := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	wg.Add(cmd.Count)
+	var (
+		errs      []string
+		errsMutex sync.Mutex
+	)
+	d := deciderFrom(ctx)
+	children := d.children(cmd.Count)
+	for i := 0; i < cmd.Count; i++ {
+		slots <- struct{}{}
+		go func(ctx context.Context) {
 			defer wg.Done()
+			defer func() { <-slots }()
 
-			err := execute(step, forwardableHeader, serviceTypes)
+			err := executeRequestCommand(ctx, cmd.Call, forwardableHeader, serviceTypes)
 			if err != nil {
+				errsMutex.Lock()
 				errs = append(errs, err.Error())
+				errsMutex.Unlock()
 			}
-		}(subCmd)
+		}(withDecider(ctx, children[i]))
 	}
 	wg.Wait()
+	d.join(children)
 	if len(errs) == 0 {
 		return nil
 	}
@@ -0,0 +1,143 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+)
+
+// failingServer serves requests, failing the first failures of them with a
+// 503, and counts them in requests.
+func failingServer(t *testing.T, failures int32, requests *int32) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, _ *http.Request) {
+			if atomic.AddInt32(requests, 1) <= failures {
+				writer.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestExecuteRequestCommand_OnError(t *testing.T) {
+	// The transport is global.
+	defer ConfigureTransport(TransportOptions{KeepAlive: true})
+
+	tests := []struct {
+		name      string
+		call      string
+		failuresB int32
+		failuresC int32
+		code      int
+		requestsB int32
+		requestsC int32
+	}{
+		{"retried", "{service: b, retries: 2}", 2, 0, http.StatusOK, 3, 0},
+		{"retries exhausted", "{service: b, retries: {attempts: 1, backoff: 1ms}}", 3, 0,
+			http.StatusInternalServerError, 2, 0},
+		{"fail", "{service: b, onError: fail}", 1, 0, http.StatusInternalServerError, 1, 0},
+		{"continue", "{service: b, retries: 1, onError: continue}", 2, 0, http.StatusOK, 2, 0},
+		{"fallback", "{service: b, onError: 'fallback:c'}", 1, 0, http.StatusOK, 1, 1},
+		{"fallback retried", "{service: b, retries: 1, onError: 'fallback:c'}", 2, 1,
+			http.StatusOK, 2, 2},
+		{"fallback failed", "{service: b, onError: 'fallback:c'}", 1, 1,
+			http.StatusInternalServerError, 1, 1},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var requestsB, requestsC int32
+			addresses := map[string]string{
+				"b:8080": failingServer(t, test.failuresB, &requestsB),
+				"c:8080": failingServer(t, test.failuresC, &requestsC),
+			}
+			ConfigureTransport(TransportOptions{
+				KeepAlive: true,
+				Resolve: func(address string) string {
+					if resolved, ok := addresses[address]; ok {
+						return resolved
+					}
+					return address
+				},
+			})
+
+			h := newTestHandler(t, `
+services:
+- name: a
+  script:
+  - call: `+test.call+`
+- name: b
+- name: c
+`, "a")
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			if recorder.Code != test.code {
+				t.Errorf("expected %v; actual %v", test.code, recorder.Code)
+			}
+			if requestsB := atomic.LoadInt32(&requestsB); requestsB != test.requestsB {
+				t.Errorf("b: expected %v; actual %v", test.requestsB, requestsB)
+			}
+			if requestsC := atomic.LoadInt32(&requestsC); requestsC != test.requestsC {
+				t.Errorf("c: expected %v; actual %v", test.requestsC, requestsC)
+			}
+		})
+	}
+}
+
+func TestExecuteRequestWithRetries_Cancel(t *testing.T) {
+	// The transport is global.
+	defer ConfigureTransport(TransportOptions{KeepAlive: true})
+
+	var requests int32
+	address := failingServer(t, 2, &requests)
+	ConfigureTransport(TransportOptions{
+		KeepAlive: true,
+		Resolve:   func(string) string { return address },
+	})
+
+	h := newTestHandler(t, `
+services:
+- name: a
+  script:
+  - call: {service: b, retries: {attempts: 1, backoff: 1h}}
+- name: b
+`, "a")
+	cmd, ok := h.Service().Script[0].(script.RequestCommand)
+	if !ok {
+		t.Fatalf("expected a call; actual %v", h.Service().Script[0])
+	}
+
+	// The backoff is cut short when the request is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := executeRequestWithRetries(ctx, cmd, http.Header{}, h.ServiceTypes())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v; actual %v", context.DeadlineExceeded, err)
+	}
+	if requests := atomic.LoadInt32(&requests); requests != 1 {
+		t.Errorf("expected %v; actual %v", 1, requests)
+	}
+}
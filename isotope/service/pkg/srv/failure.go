@@ -0,0 +1,112 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"istio.io/pkg/log"
+
+	"istio.io/tools/isotope/convert/pkg/graph/svc"
+)
+
+// injectedFailure returns the failure to simulate for a request with
+// behaviour b received at now, if the request should fail, drawn by d.
+func (h *Handler) injectedFailure(
+	b behaviour, now time.Time, d *decider) (svc.Failure, bool) {
+	var injection svc.ErrorInjection
+	if b.errorInjection != nil {
+		injection = *b.errorInjection
+	}
+	fail := shouldFail(b, injection, now, d)
+	forced, forcedFail := d.forcedChoice("fail")
+	if d.forcing {
+		// Only failed requests are recorded, whichever mode selected them.
+		fail = forcedFail
+	}
+	if !fail {
+		return svc.Failure{}, false
+	}
+	failure := injection.ChooseFailure(d.Intn)
+	if forcedFail {
+		if code, err := strconv.Atoi(forced); err == nil {
+			failure = svc.Failure{Code: code}
+		} else {
+			failure = svc.Failure{Connection: svc.ConnectionFailure(forced)}
+		}
+	}
+	if failure.Connection != "" {
+		d.record("fail %s", failure.Connection)
+	} else {
+		d.record("fail %d", failure.Code)
+	}
+	return failure, true
+}
+
+func shouldFail(b behaviour, injection svc.ErrorInjection, now time.Time, d *decider) bool {
+	// Drawn whether or not it is used, so that the draws of a request do not
+	// depend on bursts or the mode, and are replayed alike.
+	x := d.Float64()
+	if burst, ok := injection.ActiveBurst(now); ok {
+		return x < float64(burst.Rate)
+	}
+	if b.errorRate <= 0 {
+		return false
+	}
+
+	switch injection.Mode {
+	case svc.ErrorModeRandom:
+		return x < float64(b.errorRate)
+	default:
+		// Simulate failure based on the error percentage
+		reqCount := atomic.AddUint64(b.counter, 1)
+		if reqCount >= (10000 / uint64(b.errorRate*10000)) {
+			atomic.StoreUint64(b.counter, 0) // atomic.CompareAndSwap potentially collides and never resolves to true
+			return true
+		}
+		return false
+	}
+}
+
+// errConnectionFailure describes an injected connection failure.
+func errConnectionFailure(kind svc.ConnectionFailure) error {
+	return fmt.Errorf("simulated connection %s", kind)
+}
+
+// breakConnection fails a request without responding. HTTP/1 connections are
+// hijacked and closed, with a TCP reset if kind is svc.ConnectionReset. HTTP/2
+// connections cannot be hijacked, so the stream is aborted instead.
+func breakConnection(writer http.ResponseWriter, kind svc.ConnectionFailure) {
+	if hijacker, ok := writer.(http.Hijacker); ok {
+		conn, _, err := hijacker.Hijack()
+		if err == nil {
+			if tcpConn, ok := conn.(*net.TCPConn); ok && kind == svc.ConnectionReset {
+				if err := tcpConn.SetLinger(0); err != nil {
+					log.Errorf("%s", err)
+				}
+			}
+			if err := conn.Close(); err != nil {
+				log.Errorf("%s", err)
+			}
+			return
+		}
+	}
+	panic(http.ErrAbortHandler)
+}
@@ -15,9 +15,11 @@
 package srv
 
 import (
+	"encoding/binary"
 	"fmt"
-	"math/rand"
+	"math/rand/v2"
 	"os"
+	"path/filepath"
 
 	"sigs.k8s.io/yaml"
 
@@ -30,39 +32,60 @@ import (
 )
 
 // HandlerFromServiceGraphYAML makes a handler to emulate the service with name
-// serviceName in the service graph represented by the YAML file at path.
+// serviceName, as its version serviceVersion if not empty, in the service graph
+// represented by the YAML file at path.
 func HandlerFromServiceGraphYAML(
-	path string, serviceName string) (*Handler, error,
+	path string, serviceName string, serviceVersion string) (*Handler, error,
 ) {
-	serviceGraph, err := serviceGraphFromYAMLFile(path)
+	graphYAML, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	service, err := extractService(serviceGraph, serviceName)
+	service, serviceTypes, err := serviceFromYAML(
+		graphYAML, filepath.Dir(path), serviceName, serviceVersion)
 	if err != nil {
 		return nil, err
 	}
-	_ = logService(service)
 
-	serviceTypes := extractServiceTypes(serviceGraph)
+	return NewHandler(service, serviceTypes)
+}
 
-	responsePayload, err := makeRandomByteArray(service.ResponseSize)
+// serviceFromYAML extracts the service with name serviceName, as its version
+// serviceVersion if not empty, and the type of every service, from the service
+// graph in graphYAML, whose relative files are in dir.
+func serviceFromYAML(
+	graphYAML []byte, dir string, serviceName string, serviceVersion string) (
+	svc.Service, map[string]svctype.ServiceType, error) {
+	serviceGraph, err := serviceGraphFromYAML(graphYAML, dir)
 	if err != nil {
-		return nil, err
+		return svc.Service{}, nil, err
+	}
+
+	service, err := extractService(serviceGraph, serviceName)
+	if err != nil {
+		return svc.Service{}, nil, err
+	}
+	service, err = service.WithVersion(serviceVersion)
+	if err != nil {
+		return svc.Service{}, nil, err
 	}
+	_ = logService(service)
 
-	return &Handler{
-		Service:         service,
-		ServiceTypes:    serviceTypes,
-		responsePayload: responsePayload,
-	}, nil
+	return service, extractServiceTypes(serviceGraph), nil
 }
 
-func makeRandomByteArray(n size.ByteSize) ([]byte, error) {
+// makeRandomByteArray makes n random bytes, drawn from r if not nil.
+func makeRandomByteArray(n size.ByteSize, r *rand.Rand) ([]byte, error) {
 	arr := make([]byte, n)
-	if _, err := rand.Read(arr); err != nil {
-		return nil, err
+	next := rand.Uint64
+	if r != nil {
+		next = r.Uint64
+	}
+	var b [8]byte
+	for i := 0; i < len(arr); i += len(b) {
+		binary.LittleEndian.PutUint64(b[:], next())
+		copy(arr[i:], b[:])
 	}
 	return arr, nil
 }
@@ -78,19 +101,12 @@ func logService(service svc.Service) error {
 	return nil
 }
 
-// serviceGraphFromYAMLFile unmarshals the ServiceGraph from the YAML at path.
-func serviceGraphFromYAMLFile(
-	path string) (serviceGraph graph.ServiceGraph, err error) {
-	graphYAML, err := os.ReadFile(path)
-	if err != nil {
-		return
-	}
+// serviceGraphFromYAML unmarshals the ServiceGraph from graphYAML, whose
+// relative files, like those of empirical distributions, are in dir.
+func serviceGraphFromYAML(
+	graphYAML []byte, dir string) (graph.ServiceGraph, error) {
 	log.Debugf("unmarshalling\n%s", graphYAML)
-	err = yaml.Unmarshal(graphYAML, &serviceGraph)
-	if err != nil {
-		return
-	}
-	return
+	return graph.UnmarshalServiceGraphYAML(graphYAML, dir)
 }
 
 // extractService finds the service in serviceGraph with the specified name.
@@ -0,0 +1,58 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/dist"
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+)
+
+func TestHandlerFromServiceGraphYAML_File(t *testing.T) {
+	t.Parallel()
+
+	// The histogram is next to the graph, not in the working directory.
+	dir := t.TempDir()
+	files := map[string]string{
+		"histogram.txt": "5ms 1\n",
+		"service-graph.yaml": `
+services:
+- name: a
+  script:
+  - sleep: {distribution: empirical, file: histogram.txt}
+`,
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := HandlerFromServiceGraphYAML(filepath.Join(dir, "service-graph.yaml"), "a", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := script.Script{script.SleepDistributionCommand{
+		Distribution: dist.Empirical{Buckets: []dist.Bucket{{Value: 5 * time.Millisecond, Weight: 1}}},
+	}}
+	if !reflect.DeepEqual(expected, h.Service().Script) {
+		t.Errorf("expected %v; actual %v", expected, h.Service().Script)
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/pkg/log"
+
+	"istio.io/tools/isotope/convert/pkg/consts"
+	"istio.io/tools/isotope/convert/pkg/graph/size"
+	"istio.io/tools/isotope/service/pkg/srv/prometheus"
+	"istio.io/tools/isotope/service/pkg/srv/tracing"
+)
+
+const (
+	// GRPCServiceName is the fully-qualified name of the generic gRPC service
+	// exposed by every mock service.
+	GRPCServiceName = consts.ServiceGRPCName
+	// GRPCCallMethod is the full name of the unary method which emulates the
+	// service. Requests and responses are google.protobuf.BytesValue messages
+	// whose values are the request and response payloads.
+	GRPCCallMethod = consts.ServiceGRPCMethod
+)
+
+// mockServiceServer is the server API for the generic gRPC mock service.
+type mockServiceServer interface {
+	Call(context.Context, *wrapperspb.BytesValue) (*wrapperspb.BytesValue, error)
+}
+
+// mockServiceDesc describes the generic gRPC mock service. It is written by
+// hand so no generated code is needed for a single method with well-known
+// message types.
+var mockServiceDesc = grpc.ServiceDesc{
+	ServiceName: GRPCServiceName,
+	HandlerType: (*mockServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Call",
+			Handler:    mockServiceCallHandler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "isotope",
+}
+
+func mockServiceCallHandler(
+	srv interface{}, ctx context.Context, dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrapperspb.BytesValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(mockServiceServer).Call(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GRPCCallMethod,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(mockServiceServer).Call(ctx, req.(*wrapperspb.BytesValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// NewGRPCServer returns a gRPC server which emulates the Service of h through
+// the generic Call method.
+func NewGRPCServer(h *Handler) *grpc.Server {
+	return newGRPCServer(h)
+}
+
+func newGRPCServer(srv mockServiceServer) *grpc.Server {
+	server := grpc.NewServer()
+	server.RegisterService(&mockServiceDesc, srv)
+	return server
+}
+
+// Call handles the generic gRPC method by emulating the Service.
+func (h *Handler) Call(
+	ctx context.Context, _ *wrapperspb.BytesValue) (*wrapperspb.BytesValue, error) {
+	startTime := time.Now()
+
+	defer prometheus.RecordRequestReceived()()
+
+	c := h.config.Load()
+	b := h.behaviourFor(c, http.MethodPost, GRPCCallMethod)
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := metadataToHeader(md)
+	ctx, span := tracing.StartServerSpan(ctx, header, http.MethodPost, GRPCCallMethod)
+	d := newDecider(header, c.service.Seed, &h.requests, requestRecords != nil)
+	forceReplayedDecisions(d, c.service.Name, header)
+	ctx = withDecider(ctx, d)
+	record := func(code int) {
+		recordRequest(c.service.Name, http.MethodPost, GRPCCallMethod,
+			header, b, d, startTime, code)
+	}
+
+	if failure, ok := h.injectedFailure(b, startTime, d); ok {
+		log.Debug("Provoking simulated failure")
+		prometheus.RecordError(prometheus.ErrorSourceInjected)
+		if failure.Connection != "" {
+			// The stream cannot be hijacked, so report the failure the way a
+			// gRPC client observes a broken connection.
+			err := errConnectionFailure(failure.Connection)
+			tracing.EndSpan(span, 0, err)
+			record(0)
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		prometheus.RecordResponseSent(
+			time.Since(startTime), len(b.responsePayload), failure.Code)
+		tracing.EndSpan(span, failure.Code, nil)
+		record(failure.Code)
+		return nil, status.Error(
+			httpStatusToGRPCCode(failure.Code), "simulated failure")
+	}
+
+	code, body := h.executeScript(ctx, b, header)
+
+	stopTime := time.Now()
+	duration := stopTime.Sub(startTime)
+	prometheus.RecordResponseSent(duration, len(b.responsePayload), code)
+	tracing.EndSpan(span, code, nil)
+	record(code)
+
+	if code != http.StatusOK {
+		return nil, status.Error(httpStatusToGRPCCode(code), body)
+	}
+	return &wrapperspb.BytesValue{Value: b.responsePayload}, nil
+}
+
+// httpStatusToGRPCCode maps the HTTP status the script produced to the closest
+// gRPC status code.
+func httpStatusToGRPCCode(code int) codes.Code {
+	switch code {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusInternalServerError:
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
+
+func metadataToHeader(md metadata.MD) http.Header {
+	header := make(http.Header, len(md))
+	for key, values := range md {
+		header[http.CanonicalHeaderKey(key)] = values
+	}
+	return header
+}
+
+var (
+	grpcConns      = map[string]*sharedConn{}
+	grpcConnsMutex sync.Mutex
+)
+
+// sharedConn is a client connection shared by the calls to a destination.
+type sharedConn struct {
+	*grpc.ClientConn
+	// calls is the number of calls using the connection.
+	calls int
+	// retired connections are closed once no call uses them.
+	retired bool
+}
+
+// grpcConn returns a client connection to destName, creating it on first use,
+// to be released once the call using it is done. Connections are shared
+// between requests so that HTTP/2 streams are multiplexed just like a real
+// gRPC client would.
+func grpcConn(destName string) (*sharedConn, error) {
+	grpcConnsMutex.Lock()
+	defer grpcConnsMutex.Unlock()
+
+	conn, ok := grpcConns[destName]
+	if !ok {
+		target := destName
+		if resolve != nil {
+			target = "passthrough:///" + resolve(destName)
+		}
+		clientConn, err := grpc.NewClient(
+			target,
+			grpc.WithTransportCredentials(grpcCredentials),
+			grpc.WithAuthority(destName))
+		if err != nil {
+			return nil, err
+		}
+		conn = &sharedConn{ClientConn: clientConn}
+		grpcConns[destName] = conn
+	}
+	conn.calls++
+	return conn, nil
+}
+
+func (c *sharedConn) release() {
+	grpcConnsMutex.Lock()
+	defer grpcConnsMutex.Unlock()
+
+	c.calls--
+	if c.retired && c.calls == 0 {
+		c.Close()
+	}
+}
+
+// retireGRPCConns closes the connections to the destinations for which keep
+// is false once the calls using them are done. Later calls to those
+// destinations open new connections.
+func retireGRPCConns(keep func(destName string) bool) {
+	grpcConnsMutex.Lock()
+	defer grpcConnsMutex.Unlock()
+
+	for destName, conn := range grpcConns {
+		if keep(destName) {
+			continue
+		}
+		delete(grpcConns, destName)
+		conn.retired = true
+		if conn.calls == 0 {
+			conn.Close()
+		}
+	}
+}
+
+// CloseGRPCConns closes the connections of gRPC calls once the calls using
+// them are done, e.g. when shutting down.
+func CloseGRPCConns() {
+	retireGRPCConns(func(string) bool { return false })
+}
+
+// sendGRPCRequest calls the generic gRPC method of the service at destName
+// with a payload of size bytes.
+func sendGRPCRequest(
+	ctx context.Context,
+	destName string,
+	size size.ByteSize,
+	extraHeader map[string]string,
+	requestHeader http.Header) error {
+	conn, err := grpcConn(destName)
+	if err != nil {
+		return err
+	}
+	defer conn.release()
+	payload, err := deciderFrom(ctx).payload(size)
+	if err != nil {
+		return err
+	}
+
+	md := make(metadata.MD, len(requestHeader)+len(extraHeader))
+	for key, values := range requestHeader {
+		md.Append(strings.ToLower(key), values...)
+	}
+	for key, value := range extraHeader {
+		md.Append(strings.ToLower(key), value)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	log.Debugf("sending gRPC request to %s", destName)
+	response := new(wrapperspb.BytesValue)
+	return conn.Invoke(
+		ctx, GRPCCallMethod, &wrapperspb.BytesValue{Value: payload}, response)
+}
@@ -0,0 +1,223 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// newTestHandler returns a handler emulating the service with name in the
+// service graph in graphYAML.
+func newTestHandler(t *testing.T, graphYAML string, name string) *Handler {
+	t.Helper()
+	service, serviceTypes, err := serviceFromYAML([]byte(graphYAML), "", name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(service, serviceTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// serveTestHandler serves h like a service does, with HTTP/2 without TLS, and
+// returns its address.
+func serveTestHandler(t *testing.T, h *Handler) string {
+	t.Helper()
+	server := httptest.NewServer(NewServeMux(h, NewGRPCServer(h)))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+// callTestService calls the gRPC method of the service at address with md.
+func callTestService(
+	t *testing.T, address string, md metadata.MD) (*wrapperspb.BytesValue, error) {
+	t.Helper()
+	conn, err := grpc.NewClient(
+		"passthrough:///"+address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	response := new(wrapperspb.BytesValue)
+	err = conn.Invoke(
+		metadata.NewOutgoingContext(context.Background(), md),
+		GRPCCallMethod, &wrapperspb.BytesValue{}, response)
+	return response, err
+}
+
+func TestHandler_Call(t *testing.T) {
+	t.Parallel()
+
+	address := serveTestHandler(t, newTestHandler(t, `
+services:
+- name: a
+  type: grpc
+  responseSize: 1KiB
+`, "a"))
+
+	response, err := callTestService(t, address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Value) != 1024 {
+		t.Errorf("expected 1024 bytes; actual %v", len(response.Value))
+	}
+
+	// Plain HTTP requests are still served on the same port.
+	httpResponse, err := http.Get("http://" + address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusOK {
+		t.Errorf("expected %v; actual %v", http.StatusOK, httpResponse.StatusCode)
+	}
+}
+
+func TestHandler_Call_InjectedFailure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		failure string
+		code    codes.Code
+	}{
+		{"code: 429", codes.ResourceExhausted},
+		{"code: 503", codes.Unavailable},
+		{"code: 500", codes.Internal},
+		{"connection: reset", codes.Unavailable},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.failure, func(t *testing.T) {
+			t.Parallel()
+
+			address := serveTestHandler(t, newTestHandler(t, `
+services:
+- name: a
+  type: grpc
+  errorRate: 100%
+  errorInjection:
+    failures:
+    - `+test.failure, "a"))
+
+			_, err := callTestService(t, address, nil)
+			if code := status.Code(err); code != test.code {
+				t.Errorf("expected %v; actual %v (%v)", test.code, code, err)
+			}
+		})
+	}
+}
+
+func TestHandler_Call_Metadata(t *testing.T) {
+	// The transport and the connection cache are global.
+
+	// b only records the metadata it is called with.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan metadata.MD, 1)
+	b := grpc.NewServer(grpc.UnknownServiceHandler(
+		func(_ interface{}, stream grpc.ServerStream) error {
+			md, _ := metadata.FromIncomingContext(stream.Context())
+			received <- md
+			if err := stream.RecvMsg(new(wrapperspb.BytesValue)); err != nil {
+				return err
+			}
+			return stream.SendMsg(&wrapperspb.BytesValue{})
+		}))
+	go b.Serve(listener)
+	defer b.Stop()
+
+	ConfigureTransport(TransportOptions{
+		KeepAlive: true,
+		Resolve: func(address string) string {
+			if address == "b:8080" {
+				return listener.Addr().String()
+			}
+			return address
+		},
+	})
+	defer ConfigureTransport(TransportOptions{KeepAlive: true})
+
+	address := serveTestHandler(t, newTestHandler(t, `
+services:
+- name: a
+  type: grpc
+  script:
+  - call:
+      service: b
+      extra-header:
+        x-extra: extra
+- name: b
+  type: grpc
+`, "a"))
+
+	if _, err := callTestService(
+		t, address, metadata.Pairs("x-request-id", "1234", "x-not-forwarded", "x")); err != nil {
+		t.Fatal(err)
+	}
+
+	md := <-received
+	expected := map[string]string{
+		"x-request-id":    "1234",
+		"x-extra":         "extra",
+		"x-not-forwarded": "",
+		":authority":      "b:8080",
+	}
+	for key, value := range expected {
+		if actual := strings.Join(md.Get(key), ","); actual != value {
+			t.Errorf("expected %s: %q; actual %q", key, value, actual)
+		}
+	}
+
+	// The connection to b is cached until it is retired.
+	grpcConnsMutex.Lock()
+	conn, ok := grpcConns["b:8080"]
+	grpcConnsMutex.Unlock()
+	if !ok || conn.calls != 0 {
+		t.Fatalf("expected an idle connection to b; actual %v", conn)
+	}
+	retireGRPCConns(func(string) bool { return false })
+	if state := conn.GetState(); state != connectivity.Shutdown {
+		t.Errorf("expected %v; actual %v", connectivity.Shutdown, state)
+	}
+}
+
+func TestMetadataToHeader(t *testing.T) {
+	t.Parallel()
+
+	header := metadataToHeader(metadata.Pairs("x-request-id", "1", "traceparent", "2"))
+	if header.Get("X-Request-Id") != "1" || header.Get("Traceparent") != "2" {
+		t.Errorf("expected canonical keys; actual %v", header)
+	}
+}
@@ -15,7 +15,10 @@
 package srv
 
 import (
+	"context"
 	"net/http"
+	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -24,21 +27,101 @@ import (
 	"istio.io/tools/isotope/convert/pkg/graph/svc"
 	"istio.io/tools/isotope/convert/pkg/graph/svctype"
 	"istio.io/tools/isotope/service/pkg/srv/prometheus"
+	"istio.io/tools/isotope/service/pkg/srv/tracing"
 )
 
-// Handler handles the default endpoint by emulating its Service.
+// Handler handles the default endpoint by emulating its Service. The Service
+// can be replaced with Update while requests are being served.
 type Handler struct {
-	Service      svc.Service
-	ServiceTypes map[string]svctype.ServiceType
+	config atomic.Pointer[handlerConfig]
+
+	counter       uint64
+	routeCounters sync.Map
+	// requests counts the requests seeded by their order.
+	requests uint64
+
+	background backgroundLoad
+}
+
+// handlerConfig is everything a Handler derives from its Service. It is
+// replaced as a whole, never modified.
+type handlerConfig struct {
+	service      svc.Service
+	serviceTypes map[string]svctype.ServiceType
 
 	responsePayload []byte
-	counter         uint64
+	routePayloads   map[string][]byte
+}
+
+// NewHandler makes a handler to emulate service, which may call the services in
+// serviceTypes.
+func NewHandler(
+	service svc.Service, serviceTypes map[string]svctype.ServiceType) (*Handler, error) {
+	h := &Handler{}
+	if err := h.Update(service, serviceTypes); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Update atomically replaces the Service emulated by h and the services it may
+// call. Requests already being served finish with the previous Service.
+func (h *Handler) Update(
+	service svc.Service, serviceTypes map[string]svctype.ServiceType) error {
+	responsePayload, err := makeRandomByteArray(
+		service.ResponseSize, payloadRand(service.Seed, ""))
+	if err != nil {
+		return err
+	}
+
+	routePayloads, err := makeRoutePayloads(service)
+	if err != nil {
+		return err
+	}
+
+	h.config.Store(&handlerConfig{
+		service:         service,
+		serviceTypes:    serviceTypes,
+		responsePayload: responsePayload,
+		routePayloads:   routePayloads,
+	})
+	h.background.set(service.BackgroundCPU)
+	return nil
+}
+
+// StartBackgroundCPU keeps the BackgroundCPU cores of the Service of h busy,
+// following its updates, until ctx is done.
+func (h *Handler) StartBackgroundCPU(ctx context.Context) {
+	h.background.start(ctx)
+}
+
+// Service returns the Service currently emulated by h.
+func (h *Handler) Service() svc.Service {
+	return h.config.Load().service
+}
+
+// ServiceTypes returns the type of each service h may currently call.
+func (h *Handler) ServiceTypes() map[string]svctype.ServiceType {
+	return h.config.Load().serviceTypes
 }
 
 func (h *Handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	startTime := time.Now()
 
-	prometheus.RecordRequestReceived()
+	defer prometheus.RecordRequestReceived()()
+
+	c := h.config.Load()
+	b := h.behaviourFor(c, request.Method, request.URL.Path)
+
+	ctx, span := tracing.StartServerSpan(
+		request.Context(), request.Header, request.Method, request.URL.Path)
+	d := newDecider(request.Header, c.service.Seed, &h.requests, requestRecords != nil)
+	forceReplayedDecisions(d, c.service.Name, request.Header)
+	ctx = withDecider(ctx, d)
+	record := func(status int) {
+		recordRequest(c.service.Name, request.Method, request.URL.Path,
+			request.Header, b, d, startTime, status)
+	}
 
 	respond := func(status int, body string) {
 		writer.WriteHeader(status)
@@ -47,34 +130,56 @@ func (h *Handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 				log.Errorf("%s", err)
 			}
 		} else {
-			if _, err := writer.Write(h.responsePayload); err != nil {
+			if _, err := writer.Write(b.responsePayload); err != nil {
 				log.Errorf("%s", err)
 			}
 		}
 
 		stopTime := time.Now()
 		duration := stopTime.Sub(startTime)
-		prometheus.RecordResponseSent(duration, len(h.responsePayload), status)
+		prometheus.RecordResponseSent(duration, len(b.responsePayload), status)
+		tracing.EndSpan(span, status, nil)
+		record(status)
 	}
 
-	// Simulate failure based on the error percentage
-	reqCount := atomic.AddUint64(&h.counter, 1)
-	if h.Service.ErrorRate > 0 && reqCount >= (10000/uint64(h.Service.ErrorRate*10000)) {
-		atomic.StoreUint64(&h.counter, 0) // atomic.CompareAndSwap potentially collides and never resolves to true
+	if failure, ok := h.injectedFailure(b, startTime, d); ok {
 		log.Debug("Provoking simulated failure")
-		respond(http.StatusInternalServerError, "simulated failure")
+		prometheus.RecordError(prometheus.ErrorSourceInjected)
+		if failure.Connection != "" {
+			tracing.EndSpan(span, 0, errConnectionFailure(failure.Connection))
+			record(0)
+			breakConnection(writer, failure.Connection)
+			return
+		}
+		respond(failure.Code, "simulated failure")
 		return
 	}
 
-	for _, step := range h.Service.Script {
-		forwardableHeader := extractForwardableHeader(request.Header)
-		err := execute(step, forwardableHeader, h.ServiceTypes)
+	status, body := h.executeScript(ctx, b, request.Header)
+	respond(status, body)
+}
+
+// executeScript runs the script of b for a request carrying header. It returns
+// the HTTP status to respond with and, on failure, the body describing the
+// failure. An empty body means the response payload should be sent.
+func (h *Handler) executeScript(
+	ctx context.Context, b behaviour, header http.Header) (int, string) {
+	if b.route != "" {
+		log.Debugf("serving route %q", b.route)
+	}
+	ctx, allocations := withAllocations(ctx)
+	defer runtime.KeepAlive(allocations)
+	for i, step := range b.script {
+		forwardableHeader := extractForwardableHeader(header)
+		startTime := time.Now()
+		err := execute(ctx, step, forwardableHeader, b.serviceTypes)
+		prometheus.RecordStep(b.route, i, commandName(step), time.Since(startTime))
 		if err != nil {
 			log.Errorf("%s", err)
-			respond(http.StatusInternalServerError, err.Error()+"\n")
-			return
+			prometheus.RecordError(prometheus.ErrorSourceDownstream)
+			return http.StatusInternalServerError, err.Error() + "\n"
 		}
 	}
 
-	respond(http.StatusOK, "")
+	return http.StatusOK, ""
 }
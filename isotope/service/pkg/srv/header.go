@@ -16,12 +16,14 @@ package srv
 
 import (
 	"net/http"
+
+	"istio.io/tools/isotope/convert/pkg/consts"
 )
 
 var (
 	forwardableHeaders = []string{
 		// Request ID
-		"X-Request-Id",
+		consts.ServiceRequestIDHeader,
 		// B3 multi-header propagation
 		"X-B3-Traceid",
 		"X-B3-Spanid",
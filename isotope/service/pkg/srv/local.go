@@ -0,0 +1,228 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/svc"
+	"istio.io/tools/isotope/convert/pkg/graph/svctype"
+)
+
+// LocalGraph serves every service of a service graph from this process, each
+// on its own loopback listener, e.g. to try a graph without a cluster.
+type LocalGraph struct {
+	// Addresses are the addresses the services listen on, by name.
+	Addresses map[string]string
+
+	// hostAddresses are the addresses of the services by the hostnames they
+	// are called with, e.g. "b.ns.svc.cluster.local:8080".
+	hostAddresses map[string]string
+	handlers      []*localHandler
+	servers       []*http.Server
+	listeners     []net.Listener
+	// stopBackground stops the background CPU load of the services.
+	stopBackground context.CancelFunc
+}
+
+// LocalGraphFromServiceGraphYAML listens for every service of the service
+// graph in the YAML file at path. See NewLocalGraph.
+func LocalGraphFromServiceGraphYAML(path string, basePort int) (*LocalGraph, error) {
+	graphYAML, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	serviceGraph, err := serviceGraphFromYAML(graphYAML, filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	return NewLocalGraph(serviceGraph, basePort)
+}
+
+// NewLocalGraph listens for the i-th service of serviceGraph on port
+// basePort+i of the loopback interface, or on any free port if basePort is 0.
+// Calls between the services only reach them once the transport is configured
+// to resolve hostnames with g.Resolve.
+func NewLocalGraph(serviceGraph graph.ServiceGraph, basePort int) (*LocalGraph, error) {
+	g := &LocalGraph{
+		Addresses:     make(map[string]string, len(serviceGraph.Services)),
+		hostAddresses: map[string]string{},
+	}
+	serviceTypes := extractServiceTypes(serviceGraph)
+	for i, service := range serviceGraph.Services {
+		handler, err := newLocalHandler(service, serviceTypes)
+		if err != nil {
+			g.Close()
+			return nil, err
+		}
+
+		port := 0
+		if basePort > 0 {
+			port = basePort + i
+		}
+		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			g.Close()
+			return nil, err
+		}
+		g.handlers = append(g.handlers, handler)
+		g.listeners = append(g.listeners, listener)
+		g.servers = append(g.servers, &http.Server{
+			Handler: NewServeMux(handler, newGRPCServer(handler)),
+		})
+		g.Addresses[service.Name] = listener.Addr().String()
+	}
+
+	for _, requests := range serviceGraph.Requests() {
+		for _, request := range requests {
+			g.hostAddresses[request.Hostname] = g.Addresses[request.ServiceName]
+		}
+	}
+	return g, nil
+}
+
+// Resolve returns the address of the service called at address, as in
+// RequestCommand.Hostname, or address itself if no service is called at it.
+func (g *LocalGraph) Resolve(address string) string {
+	if local, ok := g.hostAddresses[address]; ok {
+		return local
+	}
+	return address
+}
+
+// StartBackgroundCPU keeps the background CPU cores of every service busy
+// until ctx is done or g is closed. Only one version of a service with
+// versions is loaded, as when it runs with SERVICE_VERSION set to version, or
+// its first version if version is not one of them.
+func (g *LocalGraph) StartBackgroundCPU(ctx context.Context, version string) {
+	ctx, g.stopBackground = context.WithCancel(ctx)
+	for _, l := range g.handlers {
+		i := slices.Index(l.versions, version)
+		if i < 0 {
+			i = 0
+		}
+		l.handlers[i].StartBackgroundCPU(ctx)
+	}
+}
+
+// Serve serves every service until one of them fails or g is closed.
+func (g *LocalGraph) Serve() error {
+	errs := make(chan error, len(g.servers))
+	for i, server := range g.servers {
+		go func(server *http.Server, listener net.Listener) {
+			errs <- server.Serve(listener)
+		}(server, g.listeners[i])
+	}
+	for range g.servers {
+		if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops serving every service and their background CPU load, and closes
+// the connections of their gRPC calls.
+func (g *LocalGraph) Close() error {
+	if g.stopBackground != nil {
+		g.stopBackground()
+	}
+	// The servers first, so that Serve returns http.ErrServerClosed rather
+	// than the error of a closed listener.
+	for _, server := range g.servers {
+		server.Close()
+	}
+	for _, listener := range g.listeners {
+		listener.Close()
+	}
+	CloseGRPCConns()
+	return nil
+}
+
+// localHandler emulates a service, or, if it has versions, one of its
+// versions for each request, chosen by their traffic split or replicas.
+type localHandler struct {
+	handlers []*Handler
+	// versions are the names of the versions of handlers, if any.
+	versions []string
+	weights  []float64
+	total    float64
+}
+
+func newLocalHandler(
+	service svc.Service, serviceTypes map[string]svctype.ServiceType) (*localHandler, error) {
+	if len(service.Versions) == 0 {
+		handler, err := NewHandler(service, serviceTypes)
+		if err != nil {
+			return nil, err
+		}
+		return &localHandler{handlers: []*Handler{handler}, weights: []float64{1}, total: 1}, nil
+	}
+
+	l := &localHandler{}
+	for _, version := range service.Versions {
+		versioned, err := service.WithVersion(version.Name)
+		if err != nil {
+			return nil, err
+		}
+		handler, err := NewHandler(versioned, serviceTypes)
+		if err != nil {
+			return nil, err
+		}
+		weight := float64(version.NumReplicas)
+		if service.Istio != nil && len(service.Istio.TrafficSplit) > 0 {
+			weight = float64(service.Istio.TrafficSplit[version.Name])
+		}
+		l.handlers = append(l.handlers, handler)
+		l.versions = append(l.versions, version.Name)
+		l.weights = append(l.weights, weight)
+		l.total += weight
+	}
+	if l.total <= 0 {
+		return nil, fmt.Errorf("service %s has no replicas", service.Name)
+	}
+	return l, nil
+}
+
+func (l *localHandler) pick() *Handler {
+	x := rand.Float64() * l.total
+	for i, weight := range l.weights {
+		if x < weight {
+			return l.handlers[i]
+		}
+		x -= weight
+	}
+	return l.handlers[len(l.handlers)-1]
+}
+
+func (l *localHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	l.pick().ServeHTTP(writer, request)
+}
+
+func (l *localHandler) Call(
+	ctx context.Context, in *wrapperspb.BytesValue) (*wrapperspb.BytesValue, error) {
+	return l.pick().Call(ctx, in)
+}
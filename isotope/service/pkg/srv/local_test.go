@@ -0,0 +1,126 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+)
+
+func TestLocalGraph(t *testing.T) {
+	// The transport, the connection cache and the request records are
+	// global.
+	defer func() { requestRecords = nil }()
+
+	var serviceGraph graph.ServiceGraph
+	if err := yaml.Unmarshal([]byte(`
+services:
+- name: a
+  isEntrypoint: true
+  script:
+  - call: b
+- name: b
+  type: grpc
+  responseSize: 1KiB
+`), &serviceGraph); err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewLocalGraph(serviceGraph, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- g.Serve() }()
+	defer func() {
+		g.Close()
+		if err := <-served; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	ConfigureTransport(TransportOptions{KeepAlive: true, Resolve: g.Resolve})
+	defer ConfigureTransport(TransportOptions{KeepAlive: true})
+	var recorded bytes.Buffer
+	RecordRequests(&recorded)
+
+	response, err := http.Get("http://" + g.Addresses["a"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("expected %v; actual %v", http.StatusOK, response.StatusCode)
+	}
+
+	// b is recorded before a, which responds once b has.
+	var services []string
+	decoder := json.NewDecoder(&recorded)
+	for decoder.More() {
+		var record RequestRecord
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Code != http.StatusOK {
+			t.Errorf("%s: expected %v; actual %v", record.Service, http.StatusOK, record.Code)
+		}
+		services = append(services, record.Service)
+	}
+	if len(services) != 2 || services[0] != "b" || services[1] != "a" {
+		t.Errorf("expected [b a]; actual %v", services)
+	}
+
+	if actual := g.Resolve("c:8080"); actual != "c:8080" {
+		t.Errorf("expected %v; actual %v", "c:8080", actual)
+	}
+}
+
+func TestLocalGraph_StartBackgroundCPU(t *testing.T) {
+	var serviceGraph graph.ServiceGraph
+	if err := yaml.Unmarshal([]byte(`
+services:
+- name: a
+  backgroundCPU: 0.01
+  versions:
+  - name: v1
+  - name: v2
+`), &serviceGraph); err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewLocalGraph(serviceGraph, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.StartBackgroundCPU(context.Background(), "v2")
+
+	handlers := g.handlers[0].handlers
+	for i, expected := range []bool{false, true} {
+		if actual := handlers[i].background.stop != nil; actual != expected {
+			t.Errorf("expected background CPU of version %d %v; actual %v",
+				i+1, expected, actual)
+		}
+	}
+
+	g.Close()
+	if handlers[1].background.ctx.Err() == nil {
+		t.Error("expected Close to stop the background CPU")
+	}
+}
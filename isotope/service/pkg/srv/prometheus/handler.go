@@ -24,10 +24,14 @@ import (
 )
 
 var (
-	durationBuckets = []float64{
+	// DefaultDurationBuckets are the buckets, in seconds, of the duration
+	// histograms unless SetDurationBuckets is called.
+	DefaultDurationBuckets = []float64{
+		0.001, 0.002, 0.003, 0.004, 0.005, 0.006,
 		0.007, 0.008, 0.009, 0.01, 0.011, 0.012, 0.014, 0.016, 0.018, 0.02, 0.025,
 		0.03, 0.035, 0.04, 0.045, 0.05, 0.06, 0.07, 0.08, 0.09, 0.1, 0.12, 0.14,
-		0.16, 0.18, 0.2, 0.25, 0.3, 0.35, 0.4, 0.45, 0.5,
+		0.16, 0.18, 0.2, 0.25, 0.3, 0.35, 0.4, 0.45, 0.5, 0.6, 0.7, 0.8, 0.9, 1,
+		1.5, 2, 2.5, 5, 10,
 	}
 	sizeBuckets = []float64{
 		// 1, 10, 100, 1,000, ..., 1,000,000,000
@@ -40,12 +44,24 @@ var (
 			Help: "Number of requests sent to this service.",
 		})
 
+	serviceIncomingRequestsInFlight = prom.NewGauge(
+		prom.GaugeOpts{
+			Name: "service_incoming_requests_in_flight",
+			Help: "Number of requests currently being served by this service.",
+		})
+
 	serviceOutgoingRequestsTotal = prom.NewCounterVec(
 		prom.CounterOpts{
 			Name: "service_outgoing_requests_total",
 			Help: "Number of requests sent from this service.",
 		}, []string{"destination_service"})
 
+	serviceOutgoingRequestsInFlight = prom.NewGaugeVec(
+		prom.GaugeOpts{
+			Name: "service_outgoing_requests_in_flight",
+			Help: "Number of requests from this service currently awaiting a response.",
+		}, []string{"destination_service"})
+
 	serviceOutgoingRequestSize = prom.NewHistogramVec(
 		prom.HistogramOpts{
 			Name:    "service_outgoing_request_size",
@@ -53,39 +69,98 @@ var (
 			Buckets: sizeBuckets,
 		}, []string{"destination_service"})
 
-	serviceRequestDurationSeconds = prom.NewHistogramVec(
-		prom.HistogramOpts{
-			Name:    "service_request_duration_seconds",
-			Help:    "Duration in seconds it took to serve requests to this service.",
-			Buckets: durationBuckets,
-		}, []string{"code"})
-
 	serviceResponseSize = prom.NewHistogramVec(
 		prom.HistogramOpts{
 			Name:    "service_response_size",
 			Help:    "Size in bytes of responses sent from this service.",
 			Buckets: sizeBuckets,
 		}, []string{"code"})
+
+	serviceErrorsTotal = prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "service_errors_total",
+			Help: "Number of failed responses sent from this service, by whether " +
+				"the failure was injected or caused by a failed downstream call.",
+		}, []string{"source"})
+
+	serviceConfigReloadsTotal = prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "service_config_reloads_total",
+			Help: "Number of attempts to reload the service graph, by result.",
+		}, []string{"result"})
+
+	serviceRequestDurationSeconds    *prom.HistogramVec
+	serviceOutgoingDurationSeconds   *prom.HistogramVec
+	serviceScriptStepDurationSeconds *prom.HistogramVec
+)
+
+// Sources of the errors counted by RecordError.
+const (
+	ErrorSourceInjected   = "injected"
+	ErrorSourceDownstream = "downstream"
 )
 
+// CodeNoResponse is the code recorded for outgoing requests which got no
+// response, e.g. because the connection failed or the request timed out.
+const CodeNoResponse = "none"
+
+func init() {
+	SetDurationBuckets(DefaultDurationBuckets)
+}
+
+// SetDurationBuckets sets the buckets, in seconds, of the duration histograms.
+// It must be called before Handler and before any duration is recorded.
+func SetDurationBuckets(buckets []float64) {
+	serviceRequestDurationSeconds = prom.NewHistogramVec(
+		prom.HistogramOpts{
+			Name:    "service_request_duration_seconds",
+			Help:    "Duration in seconds it took to serve requests to this service.",
+			Buckets: buckets,
+		}, []string{"code"})
+
+	serviceOutgoingDurationSeconds = prom.NewHistogramVec(
+		prom.HistogramOpts{
+			Name:    "service_outgoing_request_duration_seconds",
+			Help:    "Duration in seconds of requests sent from this service.",
+			Buckets: buckets,
+		}, []string{"destination_service", "code"})
+
+	serviceScriptStepDurationSeconds = prom.NewHistogramVec(
+		prom.HistogramOpts{
+			Name:    "service_script_step_duration_seconds",
+			Help:    "Duration in seconds of each step of the script of this service.",
+			Buckets: buckets,
+		}, []string{"route", "step", "command"})
+}
+
 // Handler returns an http.Handler which should be attached to a "/metrics"
 // endpoint for Prometheus to ingest.
 func Handler() http.Handler {
 	prom.MustRegister(serviceIncomingRequestsTotal)
+	prom.MustRegister(serviceIncomingRequestsInFlight)
 
 	prom.MustRegister(serviceOutgoingRequestsTotal)
+	prom.MustRegister(serviceOutgoingRequestsInFlight)
 	prom.MustRegister(serviceOutgoingRequestSize)
+	prom.MustRegister(serviceOutgoingDurationSeconds)
 
 	prom.MustRegister(serviceRequestDurationSeconds)
 	prom.MustRegister(serviceResponseSize)
+	prom.MustRegister(serviceScriptStepDurationSeconds)
+	prom.MustRegister(serviceErrorsTotal)
+
+	prom.MustRegister(serviceConfigReloadsTotal)
 
 	return promhttp.Handler()
 }
 
 // RecordRequestReceived increments the Prometheus counter for incoming
-// requests.
-func RecordRequestReceived() {
+// requests and the gauge of requests in flight. The returned function must be
+// called once the response is sent.
+func RecordRequestReceived() func() {
 	serviceIncomingRequestsTotal.Inc()
+	serviceIncomingRequestsInFlight.Inc()
+	return serviceIncomingRequestsInFlight.Dec
 }
 
 // RecordRequestSent increments the Prometheus counter for outgoing requests
@@ -96,6 +171,20 @@ func RecordRequestSent(destinationService string, size uint64) {
 		float64(size))
 }
 
+// RecordRequestStarted increments the gauge of requests in flight to
+// destinationService. The returned function must be called with the duration
+// and code (an HTTP status, a gRPC code name or CodeNoResponse) of the
+// response once it is received.
+func RecordRequestStarted(destinationService string) func(time.Duration, string) {
+	gauge := serviceOutgoingRequestsInFlight.WithLabelValues(destinationService)
+	gauge.Inc()
+	return func(duration time.Duration, code string) {
+		gauge.Dec()
+		serviceOutgoingDurationSeconds.WithLabelValues(destinationService, code).Observe(
+			duration.Seconds())
+	}
+}
+
 // RecordResponseSent observes the time-to-response duration and size for the
 // HTTP status code.
 func RecordResponseSent(duration time.Duration, size int, code int) {
@@ -104,3 +193,26 @@ func RecordResponseSent(duration time.Duration, size int, code int) {
 		duration.Seconds())
 	serviceResponseSize.WithLabelValues(strCode).Observe(float64(size))
 }
+
+// RecordStep observes the duration of step index, running command, of the
+// script of route ("" for the service's own script).
+func RecordStep(route string, index int, command string, duration time.Duration) {
+	serviceScriptStepDurationSeconds.WithLabelValues(
+		route, strconv.Itoa(index), command).Observe(duration.Seconds())
+}
+
+// RecordError increments the counter of failed responses from source, either
+// ErrorSourceInjected or ErrorSourceDownstream.
+func RecordError(source string) {
+	serviceErrorsTotal.WithLabelValues(source).Inc()
+}
+
+// RecordConfigReload increments the counter of reloads of the service graph
+// with the result of the reload: "success" if err is nil, else "failure".
+func RecordConfigReload(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	serviceConfigReloadsTotal.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// histogram returns the count and sum of the observations of h.
+func histogram(t *testing.T, h prom.Observer) (uint64, float64) {
+	t.Helper()
+	var m dto.Metric
+	if err := h.(prom.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+}
+
+// expectDelta fails t unless the value of c grew by expected since before.
+func expectDelta(t *testing.T, name string, c prom.Collector, before float64, expected float64) {
+	t.Helper()
+	if actual := testutil.ToFloat64(c) - before; actual != expected {
+		t.Errorf("%s: expected %v; actual %v", name, expected, actual)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	// Metrics are global: the test measures how they change.
+	incoming := testutil.ToFloat64(serviceIncomingRequestsTotal)
+	outgoing := testutil.ToFloat64(serviceOutgoingRequestsTotal.WithLabelValues("b"))
+	injected := testutil.ToFloat64(serviceErrorsTotal.WithLabelValues(ErrorSourceInjected))
+	reloads := testutil.ToFloat64(serviceConfigReloadsTotal.WithLabelValues("failure"))
+	requestSizes, requestBytes := histogram(t, serviceOutgoingRequestSize.WithLabelValues("b"))
+	responseSizes, responseBytes := histogram(t, serviceResponseSize.WithLabelValues("200"))
+	noResponses, _ := histogram(
+		t, serviceOutgoingDurationSeconds.WithLabelValues("b", CodeNoResponse))
+
+	served := RecordRequestReceived()
+	expectDelta(t, "incoming", serviceIncomingRequestsTotal, incoming, 1)
+	expectDelta(t, "incoming in flight", serviceIncomingRequestsInFlight, 0, 1)
+
+	RecordRequestSent("b", 1024)
+	received := RecordRequestStarted("b")
+	expectDelta(t, "outgoing", serviceOutgoingRequestsTotal.WithLabelValues("b"), outgoing, 1)
+	expectDelta(t, "outgoing in flight", serviceOutgoingRequestsInFlight.WithLabelValues("b"), 0, 1)
+	received(time.Millisecond, CodeNoResponse)
+	expectDelta(t, "outgoing in flight", serviceOutgoingRequestsInFlight.WithLabelValues("b"), 0, 0)
+	if count, _ := histogram(
+		t, serviceOutgoingDurationSeconds.WithLabelValues("b", CodeNoResponse)); count != noResponses+1 {
+		t.Errorf("expected %v; actual %v", noResponses+1, count)
+	}
+	if count, sum := histogram(t, serviceOutgoingRequestSize.WithLabelValues("b")); count != requestSizes+1 || sum != requestBytes+1024 {
+		t.Errorf("expected %v bytes in %v requests; actual %v in %v",
+			requestBytes+1024, requestSizes+1, sum, count)
+	}
+
+	RecordError(ErrorSourceInjected)
+	expectDelta(t, "injected", serviceErrorsTotal.WithLabelValues(ErrorSourceInjected), injected, 1)
+	RecordResponseSent(time.Millisecond, 2048, 200)
+	served()
+	expectDelta(t, "incoming in flight", serviceIncomingRequestsInFlight, 0, 0)
+	if count, sum := histogram(t, serviceResponseSize.WithLabelValues("200")); count != responseSizes+1 || sum != responseBytes+2048 {
+		t.Errorf("expected %v bytes in %v responses; actual %v in %v",
+			responseBytes+2048, responseSizes+1, sum, count)
+	}
+
+	RecordConfigReload(errors.New("invalid"))
+	expectDelta(t, "failed reloads", serviceConfigReloadsTotal.WithLabelValues("failure"), reloads, 1)
+}
@@ -0,0 +1,156 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"istio.io/pkg/log"
+
+	"istio.io/tools/isotope/convert/pkg/consts"
+	"istio.io/tools/isotope/convert/pkg/graph/duration"
+)
+
+// RequestRecord describes a request served by a service, as a line of the
+// JSON lines written by RecordRequests.
+type RequestRecord struct {
+	Time    time.Time `json:"time"`
+	Service string    `json:"service"`
+	Method  string    `json:"method"`
+	Path    string    `json:"path"`
+	Route   string    `json:"route,omitempty"`
+	// Seed is the seed the request drew its decisions from, if any.
+	Seed string `json:"seed,omitempty"`
+	// Decisions are the random decisions of the request in script order.
+	Decisions []Decision        `json:"decisions,omitempty"`
+	Duration  duration.Duration `json:"duration"`
+	// Code is the HTTP status of the response, or 0 if the connection was
+	// broken.
+	Code int `json:"code"`
+}
+
+// Decision is a random decision of a request, e.g. "choose 1", "repeat 3",
+// "skip b", "sleep 12ms" or "fail 500", or the decisions of each branch of a
+// step of concurrent branches, which is written as a list of lists.
+type Decision struct {
+	Choice   string
+	Branches [][]Decision
+}
+
+func (d Decision) MarshalJSON() ([]byte, error) {
+	if d.Branches != nil {
+		return json.Marshal(d.Branches)
+	}
+	return json.Marshal(d.Choice)
+}
+
+func (d *Decision) UnmarshalJSON(b []byte) error {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '[' {
+		d.Branches = [][]Decision{}
+		return json.Unmarshal(b, &d.Branches)
+	}
+	return json.Unmarshal(b, &d.Choice)
+}
+
+// requestRecords receives the records of requests, if set.
+var requestRecords *recordWriter
+
+type recordWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// RecordRequests writes a RequestRecord as a JSON line to w for each request
+// served from now on. It must be called before serving requests.
+func RecordRequests(w io.Writer) {
+	requestRecords = &recordWriter{encoder: json.NewEncoder(w)}
+}
+
+// recordRequest records a request to service with header, from startTime
+// until now, if requests are recorded.
+func recordRequest(
+	service string, method string, path string, header http.Header, b behaviour,
+	d *decider, startTime time.Time, code int) {
+	if requestRecords == nil {
+		return
+	}
+	record := RequestRecord{
+		Time:      startTime,
+		Service:   service,
+		Method:    method,
+		Path:      path,
+		Route:     b.route,
+		Seed:      header.Get(consts.ServiceSeedHeader),
+		Decisions: d.decisions,
+		Duration:  duration.Duration(time.Since(startTime)),
+		Code:      code,
+	}
+	requestRecords.mu.Lock()
+	defer requestRecords.mu.Unlock()
+	if err := requestRecords.encoder.Encode(record); err != nil {
+		log.Errorf("%s", err)
+	}
+}
+
+// replayedRequests are the decisions of the recorded requests to force, if
+// set.
+var replayedRequests map[replayedRequest][]Decision
+
+// replayedRequest identifies a recorded request by its service and seed.
+type replayedRequest struct {
+	service string
+	seed    string
+}
+
+// ReplayRequests reads the requests recorded by RecordRequests from r, and
+// forces the requests sent again to the same services with the same seeds to
+// make the same decisions, e.g. with "converter load --replay-decisions". It
+// must be called before serving requests.
+func ReplayRequests(r io.Reader) error {
+	replayed := map[replayedRequest][]Decision{}
+	decoder := json.NewDecoder(r)
+	for {
+		var record RequestRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if record.Seed != "" {
+			replayed[replayedRequest{record.Service, record.Seed}] = record.Decisions
+		}
+	}
+	replayedRequests = replayed
+	return nil
+}
+
+// forceReplayedDecisions forces d to make the decisions recorded for the
+// request to service with header, if any.
+func forceReplayedDecisions(d *decider, service string, header http.Header) {
+	seed := header.Get(consts.ServiceSeedHeader)
+	if seed == "" {
+		return
+	}
+	if decisions, ok := replayedRequests[replayedRequest{service, seed}]; ok {
+		d.force(decisions)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"istio.io/tools/isotope/convert/pkg/consts"
+)
+
+const recordTestGraph = `
+services:
+- name: a
+  errorRate: 50%
+  script:
+  - sleep: {distribution: uniform, min: 0s, max: 1ms}
+  - choose:
+    - weight: 1
+      script:
+      - sleep: 0s
+    - weight: 1
+  - - repeat:
+        distribution: {distribution: uniform, min: 0, max: 3}
+        body:
+        - sleep: 0s
+    - sleep: {distribution: exponential, mean: 100us}
+`
+
+// serveRecorded sends a request with each seed to h, in order, and returns
+// the records of the requests by seed.
+func serveRecorded(t *testing.T, h *Handler, seeds []string) map[string]RequestRecord {
+	t.Helper()
+	var recorded bytes.Buffer
+	RecordRequests(&recorded)
+	for _, seed := range seeds {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set(consts.ServiceSeedHeader, seed)
+		h.ServeHTTP(httptest.NewRecorder(), request)
+	}
+	records := map[string]RequestRecord{}
+	decoder := json.NewDecoder(&recorded)
+	for decoder.More() {
+		var record RequestRecord
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records[record.Seed] = record
+	}
+	if len(records) != len(seeds) {
+		t.Fatalf("expected %v records; actual %v", len(seeds), len(records))
+	}
+	return records
+}
+
+func TestReplayRequests(t *testing.T) {
+	defer func() {
+		requestRecords = nil
+		replayedRequests = nil
+	}()
+
+	seeds := []string{"1", "2", "3", "4"}
+	recorded := serveRecorded(t, newTestHandler(t, recordTestGraph, "a"), seeds)
+	codes := map[int]int{}
+	for _, record := range recorded {
+		codes[record.Code]++
+	}
+	if codes[http.StatusOK] != 2 || codes[http.StatusInternalServerError] != 2 {
+		t.Fatalf("expected 2 failures in 4 requests; actual %v", codes)
+	}
+
+	var recording bytes.Buffer
+	encoder := json.NewEncoder(&recording)
+	for _, seed := range seeds {
+		if err := encoder.Encode(recorded[seed]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ReplayRequests(&recording); err != nil {
+		t.Fatal(err)
+	}
+
+	// In reverse order, the failures injected every other request would fall
+	// on other seeds if they were not replayed.
+	reversed := []string{"4", "3", "2", "1"}
+	replayed := serveRecorded(t, newTestHandler(t, recordTestGraph, "a"), reversed)
+	for _, seed := range seeds {
+		expected, actual := recorded[seed], replayed[seed]
+		if expected.Code != actual.Code {
+			t.Errorf("seed %v: expected %v; actual %v", seed, expected.Code, actual.Code)
+		}
+		if !reflect.DeepEqual(expected.Decisions, actual.Decisions) {
+			t.Errorf("seed %v: expected %v; actual %v", seed, expected.Decisions, actual.Decisions)
+		}
+	}
+}
+
+func TestDecision_JSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []string{
+		`["sleep 1ms","choose -1"]`,
+		`["repeat 2",[["sleep 0s"],[]],"fail 500"]`,
+		`[[[[["skip b"]],[]],["fail reset"]]]`,
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test, func(t *testing.T) {
+			t.Parallel()
+
+			var decisions []Decision
+			if err := json.Unmarshal([]byte(test), &decisions); err != nil {
+				t.Fatal(err)
+			}
+			b, err := json.Marshal(decisions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != test {
+				t.Errorf("expected %v; actual %v", test, string(b))
+			}
+		})
+	}
+}
+
+func TestReplayRequests_Seeds(t *testing.T) {
+	defer func() {
+		requestRecords = nil
+		replayedRequests = nil
+	}()
+
+	// Without a recording, the same seeds make the same decisions.
+	h := newTestHandler(t, `
+services:
+- name: a
+  script:
+  - sleep: {distribution: uniform, min: 0s, max: 1ms}
+  - - repeat:
+        distribution: {distribution: uniform, min: 0, max: 3}
+        body:
+        - sleep: 0s
+    - sleep: 0s
+`, "a")
+	seeds := make([]string, 8)
+	for i := range seeds {
+		seeds[i] = strconv.Itoa(i)
+	}
+	first, second := serveRecorded(t, h, seeds), serveRecorded(t, h, seeds)
+	for _, seed := range seeds {
+		if !reflect.DeepEqual(first[seed].Decisions, second[seed].Decisions) {
+			t.Errorf("seed %v: expected %v; actual %v", seed, first[seed].Decisions, second[seed].Decisions)
+		}
+	}
+}
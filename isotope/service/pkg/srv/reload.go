@@ -0,0 +1,118 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"istio.io/pkg/log"
+
+	"istio.io/tools/isotope/convert/pkg/graph"
+	"istio.io/tools/isotope/convert/pkg/graph/svc"
+	"istio.io/tools/isotope/service/pkg/srv/prometheus"
+)
+
+// reloadDelay is how long WatchServiceGraphYAML waits for a burst of file
+// system events to settle before reloading.
+const reloadDelay = 100 * time.Millisecond
+
+// WatchServiceGraphYAML updates h to emulate the service with name serviceName,
+// as its version serviceVersion if not empty, whenever the service graph in the
+// YAML file at path changes, until ctx is done. If the changed graph is
+// invalid, h keeps emulating the previous one.
+//
+// The directory of path is watched rather than path itself, so files replaced
+// by a rename, like a ConfigMap volume swapping its "..data" symlink, are
+// picked up too.
+func (h *Handler) WatchServiceGraphYAML(
+	ctx context.Context, path string, serviceName string, serviceVersion string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	// An unreadable file is reported as a failed reload on the first event.
+	loaded, _ := os.ReadFile(path)
+
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			log.Debugf("config directory changed: %s", event)
+			timer.Reset(reloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Errorf("watching %s: %s", path, err)
+		case <-timer.C:
+			graphYAML, err := os.ReadFile(path)
+			if err == nil && bytes.Equal(graphYAML, loaded) {
+				continue
+			}
+			if err == nil {
+				err = h.updateFromYAML(
+					graphYAML, filepath.Dir(path), serviceName, serviceVersion)
+			}
+			prometheus.RecordConfigReload(err)
+			if err != nil {
+				log.Errorf("keeping previous config, failed to reload %s: %s", path, err)
+				continue
+			}
+			loaded = graphYAML
+			log.Infof("reloaded config from %s", path)
+		}
+	}
+}
+
+// updateFromYAML updates h to emulate the service with name serviceName, as its
+// version serviceVersion if not empty, in the service graph in graphYAML, whose
+// relative files are in dir.
+func (h *Handler) updateFromYAML(
+	graphYAML []byte, dir string, serviceName string, serviceVersion string) error {
+	service, serviceTypes, err := serviceFromYAML(
+		graphYAML, dir, serviceName, serviceVersion)
+	if err != nil {
+		return err
+	}
+	if err := h.Update(service, serviceTypes); err != nil {
+		return err
+	}
+	// The connections to destinations the service no longer calls would stay
+	// open for good.
+	hostnames := map[string]bool{}
+	requests := graph.ServiceGraph{Services: []svc.Service{service}}.Requests()
+	for _, request := range requests[service.Name] {
+		hostnames[request.Hostname] = true
+	}
+	retireGRPCConns(func(destName string) bool { return hostnames[destName] })
+	return nil
+}
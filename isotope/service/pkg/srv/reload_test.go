@@ -0,0 +1,112 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// configReloads returns the number of reloads with result so far.
+func configReloads(t *testing.T, result string) float64 {
+	t.Helper()
+	families, err := prom.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "service_config_reloads_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "result" && label.GetValue() == result {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// eventually fails t unless condition holds within a few seconds.
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !condition(); {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandler_WatchServiceGraphYAML(t *testing.T) {
+	// Registers the metrics with the default registry.
+	metricsHandler()
+	successes, failures := configReloads(t, "success"), configReloads(t, "failure")
+
+	graphYAML := func(responseSize int) []byte {
+		return []byte(fmt.Sprintf("services:\n- name: a\n  responseSize: %d\n", responseSize))
+	}
+	path := filepath.Join(t.TempDir(), "service-graph.yaml")
+	if err := os.WriteFile(path, graphYAML(1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandler(t, string(graphYAML(1)), "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- h.WatchServiceGraphYAML(ctx, path, "a", "") }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// The watch may start after a write: each write is a new config, until
+	// one is reloaded.
+	responseSize := 1
+	eventually(t, func() bool {
+		responseSize++
+		if err := os.WriteFile(path, graphYAML(responseSize), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * reloadDelay)
+		return h.Service().ResponseSize > 1
+	})
+	if actual := configReloads(t, "success"); actual <= successes {
+		t.Errorf("expected a successful reload; actual %v", actual)
+	}
+
+	// An invalid config is counted as a failed reload and not applied.
+	if actual := configReloads(t, "failure"); actual != failures {
+		t.Fatalf("expected %v; actual %v", failures, actual)
+	}
+	reloaded := h.Service().ResponseSize
+	if err := os.WriteFile(path, []byte("services: [{name: a, responseSize: -1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool { return configReloads(t, "failure") == failures+1 })
+	if h.Service().ResponseSize != reloaded {
+		t.Errorf("expected %v; actual %v", reloaded, h.Service().ResponseSize)
+	}
+}
@@ -16,40 +16,44 @@ package srv
 
 import (
 	"bytes"
-	"fmt"
+	"context"
 	"net/http"
 
 	"istio.io/pkg/log"
 
-	"istio.io/tools/isotope/convert/pkg/graph/size"
+	"istio.io/tools/isotope/convert/pkg/graph/script"
 )
 
 func sendRequest(
-	destName string,
-	size size.ByteSize,
-	extraHeader map[string]string,
+	ctx context.Context,
+	cmd script.RequestCommand,
 	requestHeader http.Header) (*http.Response, error) {
-	url := fmt.Sprintf("http://%s", destName)
-	request, err := buildRequest(url, size, extraHeader, requestHeader)
+	url := cmd.RenderURL(cmd.Hostname, deciderFrom(ctx).Intn)
+	request, err := buildRequest(ctx, cmd, url, requestHeader)
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("sending request to %s (%s)", destName, url)
-	return http.DefaultClient.Do(request)
+	request.URL.Scheme = httpScheme
+	log.Debugf("sending request to %s (%s %s)", cmd.Hostname, request.Method, request.URL)
+	return httpClient.Do(request)
 }
 
 func buildRequest(
-	url string, size size.ByteSize, extraHeader map[string]string, requestHeader http.Header) (
+	ctx context.Context, cmd script.RequestCommand, url string, requestHeader http.Header) (
 	*http.Request, error) {
-	payload, err := makeRandomByteArray(size)
+	payload, err := deciderFrom(ctx).payload(cmd.Size)
 	if err != nil {
 		return nil, err
 	}
-	request, err := http.NewRequest("GET", url, bytes.NewBuffer(payload))
+	request, err := http.NewRequestWithContext(
+		ctx, cmd.HTTPMethod(), url, bytes.NewBuffer(payload))
 	if err != nil {
 		return nil, err
 	}
-	addExtraHeaders(request, extraHeader)
+	if cmd.ContentType != "" {
+		request.Header.Set("Content-Type", cmd.ContentType)
+	}
+	addExtraHeaders(request, cmd.ExtraHeader)
 	copyHeader(request, requestHeader)
 	return request, nil
 }
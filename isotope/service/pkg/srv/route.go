@@ -0,0 +1,79 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"istio.io/tools/isotope/convert/pkg/graph/pct"
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+	"istio.io/tools/isotope/convert/pkg/graph/svc"
+	"istio.io/tools/isotope/convert/pkg/graph/svctype"
+)
+
+// behaviour is how the Handler responds to a request: as the route of the
+// Service matching the request or, if none match, as the Service itself.
+type behaviour struct {
+	// route is the key of the matching route, or "" for the Service itself.
+	route           string
+	script          script.Script
+	errorRate       pct.Percentage
+	errorInjection  *svc.ErrorInjection
+	responsePayload []byte
+	serviceTypes    map[string]svctype.ServiceType
+	// counter counts requests for the deterministic error mode.
+	counter *uint64
+}
+
+// behaviourFor returns the behaviour, in the configuration c of the Handler,
+// for a request to method and path. Requests take c once so that a concurrent
+// Update does not affect them.
+func (h *Handler) behaviourFor(
+	c *handlerConfig, method string, path string) behaviour {
+	key, ok := c.service.MatchRoute(method, path)
+	if !ok {
+		return behaviour{
+			script:          c.service.Script,
+			errorRate:       c.service.ErrorRate,
+			errorInjection:  c.service.ErrorInjection,
+			responsePayload: c.responsePayload,
+			serviceTypes:    c.serviceTypes,
+			counter:         &h.counter,
+		}
+	}
+	route := c.service.Routes[key]
+	counter, _ := h.routeCounters.LoadOrStore(key, new(uint64))
+	return behaviour{
+		route:           key,
+		script:          route.Script,
+		errorRate:       route.ErrorRate,
+		errorInjection:  c.service.ErrorInjection,
+		responsePayload: c.routePayloads[key],
+		serviceTypes:    c.serviceTypes,
+		counter:         counter.(*uint64),
+	}
+}
+
+// makeRoutePayloads makes the response payload of each route of service.
+func makeRoutePayloads(service svc.Service) (map[string][]byte, error) {
+	payloads := make(map[string][]byte, len(service.Routes))
+	for key, route := range service.Routes {
+		payload, err := makeRandomByteArray(
+			route.ResponseSize, payloadRand(service.Seed, key))
+		if err != nil {
+			return nil, err
+		}
+		payloads[key] = payload
+	}
+	return payloads, nil
+}
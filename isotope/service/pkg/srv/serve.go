@@ -0,0 +1,64 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+
+	"istio.io/tools/isotope/convert/pkg/consts"
+	"istio.io/tools/isotope/service/pkg/srv/prometheus"
+)
+
+const (
+	// PrometheusEndpoint is the path metrics are exposed on.
+	PrometheusEndpoint = "/metrics"
+	defaultEndpoint    = "/"
+)
+
+// metricsHandler registers the metrics on first use, which may only happen
+// once.
+var metricsHandler = sync.OnceValue(prometheus.Handler)
+
+// NewServeMux returns the handler of every endpoint of a service: its metrics,
+// its health and, for every other path, handler, or grpcServer for gRPC
+// requests. HTTP/2 is served with and without TLS.
+func NewServeMux(handler http.Handler, grpcServer *grpc.Server) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(PrometheusEndpoint, metricsHandler())
+	mux.HandleFunc(consts.ServiceHealthPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.Handle(defaultEndpoint, handler)
+	return h2c.NewHandler(grpcOrHTTPHandler(grpcServer, mux), &http2.Server{})
+}
+
+// grpcOrHTTPHandler serves gRPC and plain HTTP on the same port by routing
+// HTTP/2 requests with a gRPC content type to grpcServer.
+func grpcOrHTTPHandler(grpcServer *grpc.Server, httpHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(
+			r.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		httpHandler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing emits application spans for the mock service and exports
+// them via OTLP. Until Setup is called, spans are no-ops and trace headers are
+// neither read nor written.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "istio.io/tools/isotope/service"
+
+// Setup exports the spans of serviceName to the OTLP/HTTP collector at
+// endpoint, which is either "host:port" (plain HTTP) or a URL. A fraction
+// samplingRatio of new traces is sampled; incoming requests keep the
+// sampling decision of their caller. The returned function flushes and stops
+// the exporter.
+func Setup(
+	ctx context.Context, endpoint string, serviceName string, samplingRatio float64) (
+	func(context.Context) error, error) {
+	var opt otlptracehttp.Option
+	if strings.Contains(endpoint, "://") {
+		opt = otlptracehttp.WithEndpointURL(endpoint)
+	} else {
+		opt = otlptracehttp.WithEndpoint(endpoint)
+	}
+	exporter, err := otlptracehttp.New(ctx, opt, otlptracehttp.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(
+			sdktrace.TraceIDRatioBased(samplingRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+		b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)),
+	))
+	return provider.Shutdown, nil
+}
+
+// StartServerSpan continues the trace described by header, if any, with a
+// server span for a request to method and path.
+func StartServerSpan(
+	ctx context.Context, header http.Header, method string, path string) (
+	context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+	return otel.Tracer(tracerName).Start(ctx, method+" "+path,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(method),
+			semconv.URLPath(path),
+		))
+}
+
+// StartClientSpan starts a client span for a call to destination and injects
+// its context into header, replacing any trace headers forwarded from the
+// incoming request.
+func StartClientSpan(
+	ctx context.Context, destination string, header http.Header) (
+	context.Context, trace.Span) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "call "+destination,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.ServerAddress(destination)))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+	return ctx, span
+}
+
+// EndSpan records the status code and error of the request of span, then ends
+// it. As for HTTP servers in the OpenTelemetry conventions, the span fails on
+// an error or a code of 500 or more.
+func EndSpan(span trace.Span, code int, err error) {
+	if code != 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", code))
+	}
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case code >= http.StatusInternalServerError:
+		span.SetStatus(codes.Error, http.StatusText(code))
+	}
+	span.End()
+}
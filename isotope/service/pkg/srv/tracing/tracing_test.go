@@ -0,0 +1,171 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector is a stand-in for an OTLP/HTTP collector which keeps every span it
+// receives.
+type collector struct {
+	mu       sync.Mutex
+	services []string
+	spans    []*tracepb.Span
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, attr := range rs.GetResource().GetAttributes() {
+			if attr.Key == "service.name" {
+				c.services = append(c.services, attr.GetValue().GetStringValue())
+			}
+		}
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+
+	out, _ := proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(out)
+}
+
+func TestSetup(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	shutdown, err := Setup(context.Background(), server.URL+"/v1/traces", "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	incoming := http.Header{}
+	incoming.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	ctx, serverSpan := StartServerSpan(context.Background(), incoming, "GET", "/")
+	outgoing := http.Header{}
+	_, clientSpan := StartClientSpan(ctx, "b", outgoing)
+	EndSpan(clientSpan, http.StatusOK, nil)
+	EndSpan(serverSpan, http.StatusOK, nil)
+
+	if outgoing.Get("traceparent") == "" {
+		t.Errorf("outgoing header is missing traceparent: %v", outgoing)
+	}
+	if got := outgoing.Get("X-B3-TraceId"); got != traceID {
+		t.Errorf("outgoing X-B3-TraceId = %q, want %q", got, traceID)
+	}
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.spans) != 2 {
+		t.Fatalf("collector received %d spans, want 2", len(c.spans))
+	}
+	for _, service := range c.services {
+		if service != "a" {
+			t.Errorf("service.name = %q, want %q", service, "a")
+		}
+	}
+
+	byKind := make(map[tracepb.Span_SpanKind]*tracepb.Span)
+	for _, span := range c.spans {
+		if got := hex.EncodeToString(span.TraceId); got != traceID {
+			t.Errorf("span %q has trace ID %s, want %s", span.Name, got, traceID)
+		}
+		byKind[span.Kind] = span
+	}
+	gotServer, ok := byKind[tracepb.Span_SPAN_KIND_SERVER]
+	if !ok {
+		t.Fatal("no server span received")
+	}
+	gotClient, ok := byKind[tracepb.Span_SPAN_KIND_CLIENT]
+	if !ok {
+		t.Fatal("no client span received")
+	}
+	if got := hex.EncodeToString(gotServer.ParentSpanId); got != "00f067aa0ba902b7" {
+		t.Errorf("server span parent = %s, want the incoming span", got)
+	}
+	if string(gotClient.ParentSpanId) != string(gotServer.SpanId) {
+		t.Error("client span is not a child of the server span")
+	}
+}
+
+func TestEndSpan(t *testing.T) {
+	tests := []struct {
+		code     int
+		err      error
+		expected codes.Code
+	}{
+		{http.StatusOK, nil, codes.Unset},
+		{http.StatusNotFound, nil, codes.Unset},
+		{http.StatusInternalServerError, nil, codes.Error},
+		{http.StatusServiceUnavailable, nil, codes.Error},
+		{0, errors.New("connection reset"), codes.Error},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(http.StatusText(test.code), func(t *testing.T) {
+			t.Parallel()
+
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(
+				sdktrace.WithSpanProcessor(recorder))
+			_, span := provider.Tracer(tracerName).Start(context.Background(), "GET /")
+			EndSpan(span, test.code, test.err)
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("expected 1 ended span; actual %d", len(spans))
+			}
+			if actual := spans[0].Status().Code; actual != test.expected {
+				t.Errorf("expected %v; actual %v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// TransportOptions configure the connections of outgoing calls. See
+// svc.Transport for the meaning of each option.
+type TransportOptions struct {
+	HTTP2                     bool
+	KeepAlive                 bool
+	MaxConnectionsPerHost     int
+	MaxIdleConnectionsPerHost int
+	IdleConnectionTimeout     time.Duration
+	TLS                       bool
+	TLSInsecureSkipVerify     bool
+
+	// Resolve, if set, maps the address (host:port) of each destination to
+	// the address to connect to, e.g. a local listener.
+	Resolve func(address string) string
+}
+
+var (
+	httpClient      = http.DefaultClient
+	httpScheme      = "http"
+	grpcCredentials = insecure.NewCredentials()
+	resolve         func(address string) string
+)
+
+// ConfigureTransport applies opts to all outgoing calls. It must be called
+// before any call is made. gRPC calls always use HTTP/2 over a single
+// connection per destination, so only the TLS options apply to them.
+func ConfigureTransport(opts TransportOptions) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = !opts.KeepAlive
+	transport.MaxConnsPerHost = opts.MaxConnectionsPerHost
+	transport.MaxIdleConnsPerHost = opts.MaxIdleConnectionsPerHost
+	if opts.IdleConnectionTimeout > 0 {
+		transport.IdleConnTimeout = opts.IdleConnectionTimeout
+	}
+
+	// Only the chosen protocol is enabled so that comparisons between them are
+	// not skewed by negotiation.
+	protocols := new(http.Protocols)
+	switch {
+	case opts.HTTP2 && opts.TLS:
+		protocols.SetHTTP2(true)
+	case opts.HTTP2:
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		protocols.SetHTTP1(true)
+	}
+	transport.Protocols = protocols
+
+	if opts.Resolve != nil {
+		resolve = opts.Resolve
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		transport.DialContext = func(
+			ctx context.Context, network string, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, resolve(address))
+		}
+	}
+
+	if opts.TLS {
+		tlsConfig := &tls.Config{InsecureSkipVerify: opts.TLSInsecureSkipVerify} // nolint: gosec
+		transport.TLSClientConfig = tlsConfig
+		httpScheme = "https"
+		grpcCredentials = credentials.NewTLS(tlsConfig)
+	} else {
+		httpScheme = "http"
+		grpcCredentials = insecure.NewCredentials()
+	}
+
+	httpClient = &http.Client{Transport: transport}
+}
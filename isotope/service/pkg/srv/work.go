@@ -0,0 +1,156 @@
+// Copyright 2018 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this currentFile except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package srv
+
+import (
+	"context"
+	"crypto/sha256"
+	"runtime"
+	"sync"
+	"time"
+
+	"istio.io/tools/isotope/convert/pkg/graph/script"
+)
+
+// pageSize is the stride at which allocated memory is touched, so that every
+// page is backed by physical memory.
+const pageSize = 4096
+
+// dutyCyclePeriod is the period over which background load alternates
+// between burning CPU and sleeping.
+const dutyCyclePeriod = 10 * time.Millisecond
+
+// executeComputeCommand keeps the current thread busy for cmd.CPU of CPU
+// time.
+func executeComputeCommand(cmd script.ComputeCommand) {
+	burnCPU(time.Duration(cmd.CPU))
+}
+
+// burnCPU hashes until the current thread has used d of CPU time or, where
+// CPU time cannot be measured, until d has elapsed.
+func burnCPU(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	startCPU, measured := threadCPUTime()
+	startTime := time.Now()
+	done := func() bool {
+		if !measured {
+			return time.Since(startTime) >= d
+		}
+		now, _ := threadCPUTime()
+		return now-startCPU >= d
+	}
+
+	var sum [sha256.Size]byte
+	for !done() {
+		for i := 0; i < 100; i++ {
+			sum = sha256.Sum256(sum[:])
+		}
+	}
+}
+
+type allocationsKey struct{}
+
+// allocations holds the memory allocated by a request until it is served.
+type allocations struct {
+	mu     sync.Mutex
+	blocks [][]byte
+}
+
+// withAllocations returns a copy of ctx which holds the memory allocated by
+// AllocateCommands executed with it.
+func withAllocations(ctx context.Context) (context.Context, *allocations) {
+	a := &allocations{}
+	return context.WithValue(ctx, allocationsKey{}, a), a
+}
+
+// executeAllocateCommand allocates cmd.Bytes, touching every page, and holds
+// the memory in ctx.
+func executeAllocateCommand(ctx context.Context, cmd script.AllocateCommand) {
+	block := make([]byte, cmd.Bytes)
+	for i := 0; i < len(block); i += pageSize {
+		block[i] = 1
+	}
+	if a, ok := ctx.Value(allocationsKey{}).(*allocations); ok {
+		a.mu.Lock()
+		a.blocks = append(a.blocks, block)
+		a.mu.Unlock()
+	}
+}
+
+// backgroundLoad keeps a number of CPU cores busy, independently of requests,
+// once started.
+type backgroundLoad struct {
+	mu sync.Mutex
+	// ctx stops the load when done, and is nil until the load is started.
+	ctx   context.Context
+	cores float64
+	stop  context.CancelFunc
+}
+
+// start keeps the cores busy until ctx is done.
+func (l *backgroundLoad) start(ctx context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ctx = ctx
+	l.restart()
+}
+
+// set changes the load to cores, stopping the previous load.
+func (l *backgroundLoad) set(cores float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cores == l.cores {
+		return
+	}
+	l.cores = cores
+	l.restart()
+}
+
+// restart stops the current load and, if started, burns l.cores. l.mu must be
+// held.
+func (l *backgroundLoad) restart() {
+	if l.stop != nil {
+		l.stop()
+		l.stop = nil
+	}
+	if l.ctx == nil || l.cores <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(l.ctx)
+	l.stop = cancel
+	for remaining := l.cores; remaining > 0; remaining-- {
+		go burnDutyCycle(ctx, min(remaining, 1))
+	}
+}
+
+// burnDutyCycle burns fraction of the CPU time of a core until ctx is done.
+func burnDutyCycle(ctx context.Context, fraction float64) {
+	busy := time.Duration(fraction * float64(dutyCyclePeriod))
+	for {
+		start := time.Now()
+		burnCPU(busy)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(dutyCyclePeriod - time.Since(start)):
+		}
+	}
+}